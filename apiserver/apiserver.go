@@ -18,10 +18,14 @@ import (
 
 	argo_workflow "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
 	"github.com/equinor/flowify-workflows-server/auth"
+	"github.com/equinor/flowify-workflows-server/pkg/artifact"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/pkg/scheduler"
 	"github.com/equinor/flowify-workflows-server/pkg/secret"
 	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/equinor/flowify-workflows-server/rest"
 	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/transpiler"
 	gmux "github.com/gorilla/mux"
 )
 
@@ -38,17 +42,23 @@ var BuildTime = "unknown"
 const ApiV1Path string = "/api/v1"
 
 type flowifyServer struct {
-	k8Client      kubernetes.Interface
-	namespace     string
-	wfClient      argo_workflow.Interface
-	nodeStorage   storage.ComponentClient
-	volumeStorage storage.VolumeClient
-	workspace     workspace.WorkspaceClient
-	secrets       secret.SecretClient
-	portnumber    int
-	HttpServer    *http.Server
-	auth          auth.AuthenticationClient
-	authz         auth.AuthorizationClient
+	k8Client        kubernetes.Interface
+	namespace       string
+	wfClient        argo_workflow.Interface
+	nodeStorage     storage.ComponentClient
+	volumeStorage   storage.VolumeClient
+	scheduleStorage storage.ScheduleClient
+	triggerStorage  storage.TriggerClient
+	searchStorage   storage.SearchClient
+	indexStorage    storage.IndexClient
+	workspace       workspace.WorkspaceClient
+	secrets         secret.SecretClient
+	artifacts       artifact.ArtifactClient
+	portnumber      int
+	HttpServer      *http.Server
+	auth            auth.AuthenticationClient
+	authz           auth.AuthorizationClient
+	jobMonitor      jobmonitor.JobMonitor
 }
 
 func (f *flowifyServer) GetKubernetesClient() kubernetes.Interface {
@@ -77,18 +87,87 @@ func NewFlowifyServerFromConfig(cfg Config) (flowifyServer, error) {
 	kubeClient := kubernetes.NewForConfigOrDie(k8sConfig)
 	argoClient := argo_workflow.NewForConfigOrDie(k8sConfig)
 
-	nodeStorage, err := storage.NewMongoStorageClientFromConfig(cfg.DbConfig, nil)
+	if cfg.ComponentConfig.MaxDereferenceDepth <= 0 {
+		return flowifyServer{}, errors.Errorf("invalid max dereference depth %d", cfg.ComponentConfig.MaxDereferenceDepth)
+	}
+	storage.MaxDereferenceDepth = cfg.ComponentConfig.MaxDereferenceDepth
+	if cfg.TranspilerConfig.HelperImage == "" {
+		return flowifyServer{}, errors.New("no transpiler helper image")
+	}
+	transpiler.HelperImage = cfg.TranspilerConfig.HelperImage
+	if err := transpiler.ValidateArtifactRepository(cfg.TranspilerConfig.ArtifactRepository); err != nil {
+		return flowifyServer{}, errors.Wrap(err, "invalid transpiler artifact repository")
+	}
+	transpiler.ArtifactRepository = cfg.TranspilerConfig.ArtifactRepository
+
+	mongoClient, err := storage.NewMongoClientFromConfig(cfg.DbConfig)
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not create new mongo client")
+	}
+
+	nodeStorage, err := storage.NewMongoStorageClientFromConfig(cfg.DbConfig, mongoClient)
 	if err != nil {
 		return flowifyServer{}, errors.Wrap(err, "could not create new node storage")
 	}
 
-	volumeStorage, err := storage.NewMongoVolumeClientFromConfig(cfg.DbConfig, nil)
+	volumeStorage, err := storage.NewMongoVolumeClientFromConfig(cfg.DbConfig, mongoClient)
 	if err != nil {
 		return flowifyServer{}, errors.Wrap(err, "could not create new volume storage")
 	}
 
+	scheduleStorage, err := storage.NewMongoScheduleClientFromConfig(cfg.DbConfig, mongoClient)
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not create new schedule storage")
+	}
+
+	triggerStorage, err := storage.NewMongoTriggerClientFromConfig(cfg.DbConfig, mongoClient)
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not create new trigger storage")
+	}
+
+	searchStorage, err := storage.NewMongoSearchClientFromConfig(cfg.DbConfig, mongoClient)
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not create new search storage")
+	}
+
+	// upgrade the documents stored by earlier versions before jobs are monitored
+	if cfg.DbConfig.Migrate {
+		reports, err := storage.Migrate(context.TODO(), mongoClient, cfg.DbConfig.DbName, storage.MigrationOptions{})
+		if err != nil {
+			return flowifyServer{}, errors.Wrap(err, "could not migrate documents")
+		}
+		for _, r := range reports {
+			if r.Migrated > 0 {
+				log.Infof("Migrated %d documents of %s to schema version %d", r.Migrated, r.Collection, storage.CurrentSchemaVersion)
+			}
+			for uid, reason := range r.Failed {
+				log.Warnf("Cannot migrate document %s of %s: %s", uid, r.Collection, reason)
+			}
+		}
+	} else {
+		outdated, err := storage.CountOutdatedDocuments(context.TODO(), mongoClient, cfg.DbConfig.DbName)
+		if err != nil {
+			return flowifyServer{}, errors.Wrap(err, "could not count outdated documents")
+		}
+		if outdated > 0 {
+			log.Warnf("%d documents are stored with an earlier schema, migrate them with the migrate command or the db.migrate option", outdated)
+		}
+	}
+
+	indexStorage, err := storage.NewMongoIndexClientFromConfig(cfg.DbConfig, mongoClient)
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not create new index storage")
+	}
+	// indexes that cannot be created are reported and left to the admins, the server runs without them
+	indexReport, err := indexStorage.EnsureIndexes(context.TODO())
+	if err != nil {
+		return flowifyServer{}, errors.Wrap(err, "could not ensure indexes")
+	}
+	storage.LogIndexReport(indexReport)
+
 	workspaceClient := workspace.NewWorkspaceClient(kubeClient, cfg.KubernetesKonfig.Namespace)
 	secretClient := secret.NewSecretClient(kubeClient)
+	artifactClient := artifact.NewArtifactClient(kubeClient)
 
 	authClient, err := auth.NewAuthClientFromConfig(cfg.AuthConfig)
 	if err != nil {
@@ -98,16 +177,21 @@ func NewFlowifyServerFromConfig(cfg Config) (flowifyServer, error) {
 	authz := auth.RoleAuthorizer{Workspaces: workspaceClient}
 
 	return flowifyServer{
-		k8Client:      kubeClient,
-		namespace:     cfg.KubernetesKonfig.Namespace,
-		wfClient:      argoClient,
-		nodeStorage:   nodeStorage,
-		volumeStorage: volumeStorage,
-		workspace:     workspaceClient,
-		secrets:       secretClient,
-		portnumber:    cfg.ServerConfig.Port,
-		auth:          authClient,
-		authz:         authz,
+		k8Client:        kubeClient,
+		namespace:       cfg.KubernetesKonfig.Namespace,
+		wfClient:        argoClient,
+		nodeStorage:     nodeStorage,
+		volumeStorage:   volumeStorage,
+		scheduleStorage: scheduleStorage,
+		triggerStorage:  triggerStorage,
+		searchStorage:   searchStorage,
+		indexStorage:    indexStorage,
+		workspace:       workspaceClient,
+		secrets:         secretClient,
+		artifacts:       artifactClient,
+		portnumber:      cfg.ServerConfig.Port,
+		auth:            authClient,
+		authz:           authz,
 	}, nil
 }
 
@@ -116,27 +200,38 @@ func NewFlowifyServer(k8Client kubernetes.Interface,
 	wfclient argo_workflow.Interface,
 	nodeStorage storage.ComponentClient,
 	volumeStorage storage.VolumeClient,
+	scheduleStorage storage.ScheduleClient,
+	triggerStorage storage.TriggerClient,
+	searchStorage storage.SearchClient,
+	indexStorage storage.IndexClient,
 	portnumber int,
 	sec auth.AuthenticationClient) (flowifyServer, error) {
 	workspace := workspace.NewWorkspaceClient(k8Client, namespace)
 	secretClient := secret.NewSecretClient(k8Client)
+	artifactClient := artifact.NewArtifactClient(k8Client)
 	authz := auth.RoleAuthorizer{Workspaces: workspace}
 
 	return flowifyServer{
-		k8Client:      k8Client,
-		namespace:     namespace,
-		wfClient:      wfclient,
-		nodeStorage:   nodeStorage,
-		volumeStorage: volumeStorage,
-		workspace:     workspace,
-		secrets:       secretClient,
-		portnumber:    portnumber,
-		auth:          sec,
-		authz:         authz,
+		k8Client:        k8Client,
+		namespace:       namespace,
+		wfClient:        wfclient,
+		nodeStorage:     nodeStorage,
+		volumeStorage:   volumeStorage,
+		scheduleStorage: scheduleStorage,
+		triggerStorage:  triggerStorage,
+		searchStorage:   searchStorage,
+		indexStorage:    indexStorage,
+		workspace:       workspace,
+		secrets:         secretClient,
+		artifacts:       artifactClient,
+		portnumber:      portnumber,
+		auth:            sec,
+		authz:           authz,
 	}, nil
 }
 
 func (fs *flowifyServer) Run(ctx context.Context, readyNotifier *chan bool) error {
+	fs.jobMonitor = jobmonitor.NewJobMonitor(ctx, fs.wfClient, fs.nodeStorage)
 	fs.HttpServer = fs.newHTTPServer(ctx, fs.portnumber)
 
 	// Start listener
@@ -177,6 +272,20 @@ func (fs *flowifyServer) Run(ctx context.Context, readyNotifier *chan bool) erro
 	}()
 	log.WithFields(log.Fields{"version": CommitSHA, "buildtime": BuildTime, "port": address}).Info("✨ Flowify server started successfully ✨")
 
+	go func() {
+		// keep trying until the jobs running before startup are monitored again, readiness fails meanwhile
+		wait.PollImmediateUntilWithContext(ctx, jobmonitor.ReconnectDelay, func(ctx context.Context) (bool, error) {
+			if err := fs.jobMonitor.Resume(ctx); err != nil {
+				log.Error("Cannot resume job monitoring: ", err)
+				return false, nil
+			}
+			return true, nil
+		})
+	}()
+
+	// the runs of schedules are started as jobs
+	go scheduler.NewScheduler(fs.wfClient, fs.scheduleStorage, fs.jobMonitor).Run(ctx)
+
 	if readyNotifier != nil {
 		log.Info("Notify 'ready' channel")
 
@@ -234,11 +343,21 @@ func SetCustomHeaders(next http.Handler) http.Handler {
 }
 
 func (fs *flowifyServer) registerApplicationRoutes(router *gmux.Router) {
+	// the webhooks are not authenticated, register them before the authenticated routes
+	rest.RegisterWebhookRoutes(router.PathPrefix(ApiV1Path), fs.nodeStorage, fs.triggerStorage, fs.wfClient, fs.workspace, fs.jobMonitor)
+
 	// send a pathprefix that catches all and handle in a subrouter to avoid interference
-	rest.RegisterRoutes(router.PathPrefix(ApiV1Path), fs.nodeStorage, fs.volumeStorage, fs.secrets, fs.wfClient, fs.k8Client, fs.auth, fs.authz, fs.workspace, fs.namespace)
+	rest.RegisterRoutes(router.PathPrefix(ApiV1Path), fs.nodeStorage, fs.volumeStorage, fs.scheduleStorage, fs.triggerStorage, fs.searchStorage, fs.indexStorage, fs.secrets, fs.wfClient, fs.k8Client, fs.auth, fs.authz, fs.workspace, fs.jobMonitor, fs.artifacts, fs.namespace)
 
 	router.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintf(w, "alive") }).Methods(http.MethodGet)
-	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintf(w, "ready") }).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := fs.jobMonitor.Healthy(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "job monitor: %s", err.Error())
+			return
+		}
+		fmt.Fprintf(w, "ready")
+	}).Methods(http.MethodGet)
 	router.HandleFunc("/versionz", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, CommitSHA)
 	}).Methods(http.MethodGet)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"testing"
 
+	argofake "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
 	"github.com/equinor/flowify-workflows-server/auth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,10 +35,14 @@ type testCase struct {
 func Test_ApiServer(t *testing.T) {
 	server, err := NewFlowifyServer(
 		fake.NewSimpleClientset(),
-		"not-used", /* config namespace for k8s */
-		nil,        /* wfclient cs_workflow.Interface */
-		nil,        /* storage  */
-		nil,        /* volumeStorage  */
+		"not-used",                    /* config namespace for k8s */
+		argofake.NewSimpleClientset(), /* wfclient cs_workflow.Interface */
+		nil,                           /* storage  */
+		nil,                           /* volumeStorage  */
+		nil,                           /* scheduleStorage  */
+		nil,                           /* triggerStorage  */
+		nil,                           /* searchStorage  */
+		nil,                           /* indexStorage  */
 		1234,
 		auth.AzureTokenAuthenticator{},
 	)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/equinor/flowify-workflows-server/auth"
 	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/transpiler"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -30,10 +31,24 @@ type ServerConfig struct {
 	Port int `mapstructure:"port"`
 }
 
+type TranspilerConfig struct {
+	// the image of the helper pods added to the generated workflows
+	HelperImage string `mapstructure:"helperimage"`
+	// the kind of the artifact repository of the workflows (s3, gcs, oss or azure)
+	ArtifactRepository string `mapstructure:"artifactrepository"`
+}
+
+type ComponentConfig struct {
+	// the limit on how deep subcomponents are followed when dereferencing
+	MaxDereferenceDepth int `mapstructure:"maxdereferencedepth"`
+}
+
 type Config struct {
 	DbConfig         storage.DbConfig `mapstructure:"db"`
 	KubernetesKonfig KubernetesKonfig `mapstructure:"kubernetes"`
 	AuthConfig       auth.AuthConfig  `mapstructure:"auth"`
+	ComponentConfig  ComponentConfig  `mapstructure:"components"`
+	TranspilerConfig TranspilerConfig `mapstructure:"transpiler"`
 
 	LogConfig    LogConfig    `mapstructure:"logging"`
 	ServerConfig ServerConfig `mapstructure:"server"`
@@ -73,6 +88,12 @@ func viperConfig() {
 
 	// prefix all envs for uniqueness
 	viper.SetEnvPrefix("FLOWIFY")
+
+	// the documents of earlier versions are upgraded unless disabled, jobs without migrated events have no timeline
+	viper.SetDefault("db.migrate", true)
+	viper.SetDefault("components.maxdereferencedepth", storage.DefaultMaxDereferenceDepth)
+	viper.SetDefault("transpiler.helperimage", transpiler.DefaultHelperImage)
+	viper.SetDefault("transpiler.artifactrepository", transpiler.DefaultArtifactRepository)
 }
 
 func viperDecodeHook() viper.DecoderConfigOption {
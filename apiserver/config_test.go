@@ -0,0 +1,27 @@
+package apiserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/transpiler"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LoadConfigDefaults(t *testing.T) {
+	// options missing from the config take their defaults
+	cfg, err := LoadConfigFromReader(strings.NewReader("db:\n  dbname: test\n"))
+	require.NoError(t, err)
+	require.True(t, cfg.DbConfig.Migrate)
+	require.Equal(t, storage.DefaultMaxDereferenceDepth, cfg.ComponentConfig.MaxDereferenceDepth)
+	require.Equal(t, transpiler.DefaultHelperImage, cfg.TranspilerConfig.HelperImage)
+	require.Equal(t, transpiler.DefaultArtifactRepository, cfg.TranspilerConfig.ArtifactRepository)
+
+	cfg, err = LoadConfigFromReader(strings.NewReader("db:\n  dbname: test\n  migrate: false\ncomponents:\n  maxdereferencedepth: 8\ntranspiler:\n  helperimage: busybox:1.35\n  artifactrepository: gcs\n"))
+	require.NoError(t, err)
+	require.False(t, cfg.DbConfig.Migrate)
+	require.Equal(t, 8, cfg.ComponentConfig.MaxDereferenceDepth)
+	require.Equal(t, "busybox:1.35", cfg.TranspilerConfig.HelperImage)
+	require.Equal(t, "gcs", cfg.TranspilerConfig.ArtifactRepository)
+}
@@ -66,8 +66,9 @@ const (
 type Subject string
 
 const (
-	Secrets Subject = "secrets"
-	Volumes Subject = "volumes"
+	Secrets  Subject = "secrets"
+	Volumes  Subject = "volumes"
+	Triggers Subject = "triggers"
 )
 
 type AccessLevel struct {
@@ -134,6 +135,28 @@ func (ra RoleAuthorizer) GetVolumePermissions(usr user.User, data any) (map[Acti
 	return p, nil
 }
 
+func (ra RoleAuthorizer) GetTriggerPermissions(usr user.User, data any) (map[Action]bool, error) {
+	p := make(map[Action]bool)
+
+	workspace, ok := data.(string)
+	if !ok {
+		return map[Action]bool{}, errors.Errorf("could not decode the workspace variable")
+	}
+
+	al, err := ra.GetWorkspacePermissions(workspace, usr)
+	if err != nil {
+		return map[Action]bool{}, errors.Wrap(err, "could not get trigger permissions")
+	}
+
+	// the webhook of a trigger submits jobs in the workspace, only admins manage them
+	p[Read] = al.User || al.Admin
+	p[List] = al.User || al.Admin
+	p[Write] = al.Admin
+	p[Delete] = al.Admin
+
+	return p, nil
+}
+
 func (ra RoleAuthorizer) GetPermissions(subject Subject, action Action, usr user.User, data any) (bool, error) {
 	switch subject {
 	case Secrets:
@@ -154,6 +177,15 @@ func (ra RoleAuthorizer) GetPermissions(subject Subject, action Action, usr user
 			return p, nil
 		}
 		return false, errors.Errorf("Rule %s:%s not found", subject, action)
+	case Triggers:
+		perms, err := ra.GetTriggerPermissions(usr, data)
+		if err != nil {
+			return false, err
+		}
+		if p, ok := perms[action]; ok {
+			return p, nil
+		}
+		return false, errors.Errorf("Rule %s:%s not found", subject, action)
 	default:
 		return false, errors.Errorf("no such subject '%s'", subject)
 	}
@@ -86,6 +86,7 @@ func main() {
 
 	fileName := flag.String("file", "", "Read from file instead of cmd line arg, '-' for stdin")
 	dbName := flag.String("db", "Flowify", "Set the name of the database to use")
+	maxDepth := flag.Int("maxdepth", storage.DefaultMaxDereferenceDepth, "Set the maximum nesting depth of dereferenced components")
 	if isFlagPassed("db") {
 		cfg.DbName = *dbName
 	}
@@ -147,7 +148,7 @@ func main() {
 		panic("unexpected")
 	}
 
-	cmpResolved, err := storage.DereferenceComponent(context.TODO(), cstorage, component)
+	cmpResolved, err := storage.DereferenceComponentWithMaxDepth(context.TODO(), cstorage, component, *maxDepth)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/equinor/flowify-workflows-server/apiserver"
+	"github.com/equinor/flowify-workflows-server/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func myUsage() {
+	fmt.Printf("Usage: %s [OPTIONS]\n", os.Args[0])
+	fmt.Println("Upgrades the documents stored by earlier versions of the server to the current schema version.")
+	fmt.Println("Run it while no server writes to the database, the database is read from the server config.")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetLevel(log.InfoLevel)
+
+	dryRun := flag.Bool("dry-run", false, "Upgrade and check the documents without writing them")
+	batchSize := flag.Int("batch", 100, "The number of documents read at a time")
+	dbName := flag.String("db", "", "Override the name of the database in the config")
+	configPath := flag.String("config", ".", "The directory of the server config file")
+	flag.Usage = myUsage
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := apiserver.LoadConfigFromPath(*configPath)
+	if err != nil {
+		log.Fatal("could not load config, ", err)
+	}
+	if *dbName != "" {
+		cfg.DbConfig.DbName = *dbName
+	}
+
+	client, err := storage.NewMongoClientFromConfig(cfg.DbConfig)
+	if err != nil {
+		log.Fatal("could not connect to the database, ", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	reports, err := storage.Migrate(context.Background(), client, cfg.DbConfig.DbName, storage.MigrationOptions{DryRun: *dryRun, BatchSize: *batchSize})
+	failed := 0
+	for _, r := range reports {
+		verb := "migrated"
+		if *dryRun {
+			verb = "would migrate"
+		}
+		fmt.Printf("%s: %s %d documents to schema version %d, %d failed\n", r.Collection, verb, r.Migrated, storage.CurrentSchemaVersion, len(r.Failed))
+
+		uids := make([]string, 0, len(r.Failed))
+		for uid := range r.Failed {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		for _, uid := range uids {
+			fmt.Printf("  %s: %s\n", uid, r.Failed[uid])
+		}
+		failed += len(r.Failed)
+	}
+	if err != nil {
+		log.Fatal("migration interrupted, ", err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
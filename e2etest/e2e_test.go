@@ -96,6 +96,8 @@ db:
   select: mongo
   # the flowify document database
   dbname: e2e-test
+  # upgrade the documents stored by earlier versions at startup
+  migrate: true
 #  mongo:
   config:
     # Mongo fields
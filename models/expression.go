@@ -0,0 +1,445 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// How the operands of a comparison are compared
+type ComparisonType string
+
+const (
+	StringComparison ComparisonType = "string"
+	NumberComparison ComparisonType = "number"
+)
+
+// The operator matching the left operand against the regular expression on the right
+const MatchesOperator = "matches"
+
+var comparisonOperators = []string{"==", "!=", "<", ">", "<=", ">=", MatchesOperator}
+
+// The phases of a finished node, the values of a status operand
+var NodePhases = []string{"Succeeded", "Failed", "Error", "Skipped", "Omitted"}
+
+// An operand comparing the status of a preceding node in the enclosing graph, e.g. {"statusOf": "node1"}.
+// Only nodes the conditional depends on through an edge or a control edge can be checked
+type NodeStatus struct {
+	Node string `json:"statusOf" bson:"statusOf"`
+}
+
+// An expression is either a comparison of a left and right operand, or one of the combinators and, or and not.
+// The operands are a string or number literal, an input parameter of the conditional (Data) or the status of a node (NodeStatus)
+type Expression struct {
+	Left     interface{} `json:"left,omitempty" bson:"left,omitempty"`
+	Right    interface{} `json:"right,omitempty" bson:"right,omitempty"`
+	Operator string      `json:"operator,omitempty" bson:"operator,omitempty"`
+	// defaults to number for the ordering operators and to string otherwise
+	Compare ComparisonType `json:"compare,omitempty" bson:"compare,omitempty"`
+
+	And []Expression `json:"and,omitempty" bson:"and,omitempty"`
+	Or  []Expression `json:"or,omitempty" bson:"or,omitempty"`
+	Not *Expression  `json:"not,omitempty" bson:"not,omitempty"`
+}
+
+// A malformed expression, the path locates the offending part, e.g. 'and[1].left'
+type ExpressionError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ExpressionError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid expression: %s", e.Reason)
+	}
+	return fmt.Sprintf("invalid expression at '%s': %s", e.Path, e.Reason)
+}
+
+func (e Expression) Validate(doc []byte) error {
+	return ValidateDocument(doc, reflect.TypeOf(Expression{}))
+}
+
+// ComparisonType returns how the operands of the comparison are compared
+func (e Expression) ComparisonType() ComparisonType {
+	if e.Compare != "" {
+		return e.Compare
+	}
+	switch e.Operator {
+	case "<", ">", "<=", ">=":
+		return NumberComparison
+	}
+	return StringComparison
+}
+
+// StatusNodes returns the ids of the nodes whose status the expression checks, each once
+func (e Expression) StatusNodes() []string {
+	nodes := []string{}
+	e.statusNodes(&nodes)
+	return nodes
+}
+
+func (e Expression) statusNodes(nodes *[]string) {
+	for _, operand := range []interface{}{e.Left, e.Right} {
+		s, ok := operand.(NodeStatus)
+		if !ok {
+			continue
+		}
+		found := false
+		for _, n := range *nodes {
+			found = found || n == s.Node
+		}
+		if !found {
+			*nodes = append(*nodes, s.Node)
+		}
+	}
+	for _, subs := range [][]Expression{e.And, e.Or} {
+		for _, sub := range subs {
+			sub.statusNodes(nodes)
+		}
+	}
+	if e.Not != nil {
+		e.Not.statusNodes(nodes)
+	}
+}
+
+// Check validates the expression against the inputs of its conditional, returns an *ExpressionError
+func (e Expression) Check(inputs []Data) error {
+	return e.check(inputs, "")
+}
+
+func subPath(path string, elem string) string {
+	if path == "" {
+		return elem
+	}
+	return path + "." + elem
+}
+
+func (e Expression) check(inputs []Data, path string) error {
+	forms := 0
+	if e.Operator != "" || e.Left != nil || e.Right != nil {
+		forms++
+	}
+	if e.And != nil {
+		forms++
+	}
+	if e.Or != nil {
+		forms++
+	}
+	if e.Not != nil {
+		forms++
+	}
+	if forms != 1 {
+		return &ExpressionError{path, "requires exactly one of a comparison, 'and', 'or' or 'not'"}
+	}
+
+	for _, c := range []struct {
+		name string
+		subs []Expression
+	}{{"and", e.And}, {"or", e.Or}} {
+		if c.subs == nil {
+			continue
+		}
+		if len(c.subs) == 0 {
+			return &ExpressionError{path, fmt.Sprintf("'%s' requires at least one expression", c.name)}
+		}
+		for i, sub := range c.subs {
+			if err := sub.check(inputs, subPath(path, fmt.Sprintf("%s[%d]", c.name, i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if e.Not != nil {
+		return e.Not.check(inputs, subPath(path, "not"))
+	}
+
+	return e.checkComparison(inputs, path)
+}
+
+func (e Expression) checkComparison(inputs []Data, path string) error {
+	known := false
+	for _, op := range comparisonOperators {
+		known = known || op == e.Operator
+	}
+	if !known {
+		return &ExpressionError{path, fmt.Sprintf("unknown operator '%s', expected one of %s", e.Operator, strings.Join(comparisonOperators, ", "))}
+	}
+	compare := e.ComparisonType()
+	if compare != StringComparison && compare != NumberComparison {
+		return &ExpressionError{path, fmt.Sprintf("unknown comparison '%s', expected string or number", compare)}
+	}
+	if e.Operator == MatchesOperator && compare != StringComparison {
+		return &ExpressionError{path, "'matches' requires a string comparison"}
+	}
+
+	for _, operand := range []struct {
+		name  string
+		value interface{}
+		other interface{}
+	}{{"left", e.Left, e.Right}, {"right", e.Right, e.Left}} {
+		opPath := subPath(path, operand.name)
+		switch v := operand.value.(type) {
+		case nil:
+			return &ExpressionError{opPath, "missing operand"}
+		case string:
+			if strings.Contains(v, "{{") || strings.Contains(v, "}}") {
+				return &ExpressionError{opPath, "literals cannot contain template tags"}
+			}
+			if compare == NumberComparison {
+				if number, err := strconv.ParseFloat(v, 64); err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+					return &ExpressionError{opPath, fmt.Sprintf("'%s' is not a number", v)}
+				}
+			}
+			if operand.name == "right" && e.Operator == MatchesOperator {
+				if _, err := regexp.Compile(v); err != nil {
+					return &ExpressionError{opPath, fmt.Sprintf("invalid regular expression: %s", err.Error())}
+				}
+			}
+		case float64:
+			if operand.name == "right" && e.Operator == MatchesOperator {
+				return &ExpressionError{opPath, "the pattern of 'matches' must be a string"}
+			}
+		case Data:
+			found := false
+			for _, in := range inputs {
+				if in.Name == v.Name {
+					if in.Type != FlowifyParameterType {
+						return &ExpressionError{opPath, fmt.Sprintf("input '%s' is a %s, only parameters can be compared", v.Name, in.Type)}
+					}
+					found = true
+				}
+			}
+			if !found {
+				return &ExpressionError{opPath, fmt.Sprintf("unknown input '%s'", v.Name)}
+			}
+		case NodeStatus:
+			if v.Node == "" {
+				return &ExpressionError{opPath, "missing node of status"}
+			}
+			if e.Operator != "==" && e.Operator != "!=" {
+				return &ExpressionError{path, "a status can only be compared with '==' or '!='"}
+			}
+			if e.Compare == NumberComparison {
+				return &ExpressionError{path, "a status cannot be compared as a number"}
+			}
+			phase, ok := operand.other.(string)
+			valid := false
+			for _, p := range NodePhases {
+				valid = valid || (ok && p == phase)
+			}
+			if !valid {
+				return &ExpressionError{path, fmt.Sprintf("a status must be compared to one of %s", strings.Join(NodePhases, ", "))}
+			}
+		default:
+			return &ExpressionError{opPath, fmt.Sprintf("unsupported operand type %T", v)}
+		}
+	}
+	return nil
+}
+
+// ValidateExpressions checks the expressions of the inline conditionals of the component tree.
+// The statuses checked by the component itself are passed in by the graph using it,
+// nested conditionals can only check the nodes they depend on in their enclosing graph
+func (c Component) ValidateExpressions() error {
+	upstream := []string{}
+	if cond, ok := c.Implementation.(Conditional); ok {
+		upstream = cond.Expression.StatusNodes()
+	}
+	return validateExpressions(c, upstream)
+}
+
+// ValidateExpressions checks the expressions of the inline conditionals of the workflow and its exit handler,
+// neither is part of a graph so their own expressions cannot check any statuses
+func (wf Workflow) ValidateExpressions() error {
+	if err := validateExpressions(wf.Component, nil); err != nil {
+		return err
+	}
+	if wf.OnExit != nil {
+		if cmp, ok := wf.OnExit.Node.(Component); ok {
+			return validateExpressions(cmp, nil)
+		}
+	}
+	return nil
+}
+
+// upstream are the nodes a component depends on in its enclosing graph
+func validateExpressions(c Component, upstream []string) error {
+	switch impl := c.Implementation.(type) {
+	case Conditional:
+		if err := impl.Expression.Check(c.Inputs); err != nil {
+			return errors.Wrapf(err, "conditional %s", c.Uid.String())
+		}
+		for _, node := range impl.Expression.StatusNodes() {
+			found := false
+			for _, u := range upstream {
+				found = found || u == node
+			}
+			if !found {
+				return errors.Wrapf(&ExpressionError{Reason: fmt.Sprintf("the status of node '%s' is not available, the conditional has to depend on it in the enclosing graph", node)},
+					"conditional %s", c.Uid.String())
+			}
+		}
+		for _, branch := range []interface{}{impl.NodeTrue, impl.NodeFalse} {
+			if cmp, ok := branch.(Component); ok {
+				if err := validateExpressions(cmp, nil); err != nil {
+					return err
+				}
+			}
+		}
+	case Map:
+		if cmp, ok := impl.Node.(Component); ok {
+			return validateExpressions(cmp, nil)
+		}
+	case Graph:
+		for _, node := range impl.Nodes {
+			cmp, ok := node.Node.(Component)
+			if !ok {
+				continue
+			}
+			deps := []string{}
+			for _, e := range impl.Edges {
+				if e.Target.Node == node.Id && e.Source.Node != "" {
+					deps = append(deps, e.Source.Node)
+				}
+			}
+			for _, e := range impl.ControlEdges {
+				if e.Target == node.Id {
+					deps = append(deps, e.Source)
+				}
+			}
+			if err := validateExpressions(cmp, deps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalOperand unmarshals a literal, an input or a node status from json
+func unmarshalOperand(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str, nil
+	}
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["statusOf"]; ok {
+		var status NodeStatus
+		err := json.Unmarshal(raw, &status)
+		return status, err
+	}
+	var data Data
+	err := json.Unmarshal(raw, &data)
+	return data, err
+}
+
+// implements the json.Unmarshaler (cf. https://pkg.go.dev/encoding/json#Unmarshaler)
+func (e *Expression) UnmarshalJSON(document []byte) error {
+	var partialExpression struct {
+		Operator string          `json:"operator"`
+		Compare  ComparisonType  `json:"compare"`
+		Left     json.RawMessage `json:"left"`
+		Right    json.RawMessage `json:"right"`
+		And      []Expression    `json:"and"`
+		Or       []Expression    `json:"or"`
+		Not      *Expression     `json:"not"`
+	}
+
+	err := json.Unmarshal(document, &partialExpression)
+	if err != nil {
+		return errors.Wrapf(err, "cannot unmarshal partial expression")
+	}
+	*e = Expression{
+		Operator: partialExpression.Operator,
+		Compare:  partialExpression.Compare,
+		And:      partialExpression.And,
+		Or:       partialExpression.Or,
+		Not:      partialExpression.Not,
+	}
+
+	e.Left, err = unmarshalOperand(partialExpression.Left)
+	if err != nil {
+		return errors.Wrap(err, "Cannot unmarshal expression (left value)")
+	}
+	e.Right, err = unmarshalOperand(partialExpression.Right)
+	if err != nil {
+		return errors.Wrap(err, "Cannot unmarshal expression (right value)")
+	}
+	return nil
+}
+
+// unmarshalOperandBSON unmarshals a literal, an input or a node status from bson
+func unmarshalOperandBSON(raw bson.RawValue) (interface{}, error) {
+	switch raw.Type {
+	case 0, bsontype.Null:
+		return nil, nil
+	case bsontype.String:
+		return raw.StringValue(), nil
+	case bsontype.Double:
+		return raw.Double(), nil
+	case bsontype.Int32:
+		return float64(raw.Int32()), nil
+	case bsontype.Int64:
+		return float64(raw.Int64()), nil
+	case bsontype.EmbeddedDocument:
+		if _, err := raw.Document().LookupErr("statusOf"); err == nil {
+			var status NodeStatus
+			err := raw.Unmarshal(&status)
+			return status, err
+		}
+		var data Data
+		err := raw.Unmarshal(&data)
+		return data, err
+	}
+	return nil, errors.Errorf("unsupported operand type %s", raw.Type)
+}
+
+func (e *Expression) UnmarshalBSON(data []byte) error {
+	var partialExpression struct {
+		Operator string         `bson:"operator"`
+		Compare  ComparisonType `bson:"compare"`
+		Left     bson.RawValue  `bson:"left"`
+		Right    bson.RawValue  `bson:"right"`
+		And      []Expression   `bson:"and"`
+		Or       []Expression   `bson:"or"`
+		Not      *Expression    `bson:"not"`
+	}
+	err := bson.Unmarshal(data, &partialExpression)
+	if err != nil {
+		return errors.Wrapf(err, "cannot unmarshal expression")
+	}
+	*e = Expression{
+		Operator: partialExpression.Operator,
+		Compare:  partialExpression.Compare,
+		And:      partialExpression.And,
+		Or:       partialExpression.Or,
+		Not:      partialExpression.Not,
+	}
+
+	e.Left, err = unmarshalOperandBSON(partialExpression.Left)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot unmarshal expression (left value)")
+	}
+	e.Right, err = unmarshalOperandBSON(partialExpression.Right)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot unmarshal expression (right value)")
+	}
+	return nil
+}
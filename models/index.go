@@ -0,0 +1,24 @@
+package models
+
+// The indexes of a collection differing from the indexes required by the storage
+type CollectionIndexReport struct {
+	Collection string `json:"collection"`
+	// required indexes that are absent, or present with other keys or options
+	Missing []string `json:"missing"`
+	// indexes that are not required, they slow down writes and may be left by earlier versions
+	Extra []string `json:"extra"`
+}
+
+type IndexReport struct {
+	Collections []CollectionIndexReport `json:"collections"`
+}
+
+// True when every required index is in place
+func (r IndexReport) Complete() bool {
+	for _, c := range r.Collections {
+		if len(c.Missing) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -2,9 +2,12 @@ package models
 
 import (
 	"encoding/json"
+	"sort"
+	"time"
 
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type Value struct {
@@ -41,7 +44,26 @@ func (v *Value) UnmarshalJSON(document []byte) error {
 	return err
 }
 
-type JobEvent wfv1.Workflow
+// A compact record of a status change of a job or one of its nodes
+type JobEvent struct {
+	JobUid ComponentReference `json:"jobUid" bson:"jobUid"`
+	// the argo node id, empty for events of the job itself
+	NodeId string `json:"nodeId,omitempty" bson:"nodeId,omitempty"`
+	// the display name of the node
+	Name    string `json:"name,omitempty" bson:"name,omitempty"`
+	Phase   string `json:"phase" bson:"phase"`
+	Message string `json:"message,omitempty" bson:"message,omitempty"`
+	// when the change happened, the start or finish time when reported and otherwise when it was observed
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	// the resource version of the workflow the change was observed in
+	ResourceVersion string `json:"resourceVersion,omitempty" bson:"resourceVersion,omitempty"`
+}
+
+// The recorded status changes of a job ordered by time
+type JobTimeline struct {
+	Uid    ComponentReference `json:"uid" bson:"uid"`
+	Events []JobEvent         `json:"events" bson:"events"`
+}
 
 type Job struct {
 	Metadata `json:",inline" bson:",inline"`
@@ -49,12 +71,65 @@ type Job struct {
 	Type        ComponentType `json:"type" bson:"type"`
 	InputValues []Value       `json:"inputValues,omitempty" bson:"inputValues,omitempty"`
 	Workflow    Workflow      `json:"workflow" bson:"workflow"`
-	Events      []JobEvent    `json:"events,omitempty" bson:"events,omitempty"`
+	// the component versions resolved for unversioned references when the job was submitted
+	Lockfile []CRefVersion `json:"lockfile,omitempty" bson:"lockfile,omitempty"`
+	// set when the job was created from another job
+	Origin *JobOrigin `json:"origin,omitempty" bson:"origin,omitempty"`
+}
+
+const (
+	JobOriginResubmit string = "resubmit"
+	JobOriginRetry    string = "retry"
+	// the origin is the schedule the job was run by
+	JobOriginSchedule string = "schedule"
+	// the origin is the trigger whose webhook submitted the job
+	JobOriginTrigger string = "trigger"
+)
+
+// Links a job to the original job it was resubmitted or retried from, or the schedule or trigger that started it
+type JobOrigin struct {
+	Uid  ComponentReference `json:"uid" bson:"uid"`
+	Type string             `json:"type" bson:"type"`
 }
 
 type JobStatus struct {
 	Uid    ComponentReference `json:"uid" bson:"uid"`
 	Status wfv1.WorkflowPhase `json:"status" bson:"status"`
+	// set when the job is paused or waiting in a suspend step
+	Suspended bool `json:"suspended,omitempty" bson:"suspended,omitempty"`
+}
+
+// The status of a single node of a job, mapped back to the flowify component tree
+type JobNodeStatus struct {
+	// the argo node id, unique within the job
+	Id string `json:"id" bson:"id"`
+	// the id of the node in its parent component: a graph node id, 'mapnode', 'nodeTrue' or 'nodeFalse'. Empty for the workflow component
+	NodeId string `json:"nodeId" bson:"nodeId"`
+	// the node ids from the workflow component down to the node joined by '.', map iterations are suffixed by their index, e.g. 'a.mapnode[2].b'
+	Path         string             `json:"path" bson:"path"`
+	ComponentUid ComponentReference `json:"componentUid" bson:"componentUid"`
+	Phase        wfv1.NodePhase     `json:"phase" bson:"phase"`
+	StartedAt    *metav1.Time       `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	FinishedAt   *metav1.Time       `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+	Message      string             `json:"message,omitempty" bson:"message,omitempty"`
+	// the number of times the node was retried
+	Retries int           `json:"retries,omitempty" bson:"retries,omitempty"`
+	Outputs *wfv1.Outputs `json:"outputs,omitempty" bson:"outputs,omitempty"`
+	// set for a single iteration of a map
+	Iteration *int   `json:"iteration,omitempty" bson:"iteration,omitempty"`
+	Item      string `json:"item,omitempty" bson:"item,omitempty"`
+	// set for conditionals to the branch taken, 'nodeTrue' or 'nodeFalse'
+	Branch string `json:"branch,omitempty" bson:"branch,omitempty"`
+}
+
+// A declared output of the workflow of a job with the produced value or artifact
+type JobOutput struct {
+	Name string `json:"name" bson:"name"`
+	Type string `json:"type" bson:"type"`
+	// set for parameter outputs once produced
+	Value *string `json:"value,omitempty" bson:"value,omitempty"`
+	// set for artifact outputs once produced, resolved against the artifact repository of the job
+	Artifact *wfv1.ArtifactLocation `json:"artifact,omitempty" bson:"artifact,omitempty"`
 }
 
 type JobPostRequest struct {
@@ -66,3 +141,61 @@ type JobPostOptions struct {
 	Constants []interface{} `json:"constants"`
 	Tags      []string      `json:"tags"`
 }
+
+// LatestJobEvents adds the events to the latest event of each node, the job itself is keyed by the empty node id
+func LatestJobEvents(latest map[string]JobEvent, events []JobEvent) map[string]JobEvent {
+	if latest == nil {
+		latest = make(map[string]JobEvent)
+	}
+	for _, e := range events {
+		latest[e.NodeId] = e
+	}
+	return latest
+}
+
+// JobEventsFromWorkflow returns the status changes of the workflow and its nodes since the latest events.
+// Changes without a reported start or finish time are timestamped now
+func JobEventsFromWorkflow(id ComponentReference, wf *wfv1.Workflow, latest map[string]JobEvent, now time.Time) []JobEvent {
+	events := []JobEvent{}
+	add := func(nodeId string, name string, phase string, message string, startedAt metav1.Time, finishedAt metav1.Time) {
+		prev, seen := latest[nodeId]
+		if phase == "" || seen && prev.Phase == phase && prev.Message == message {
+			return
+		}
+
+		timestamp := now
+		switch {
+		case !finishedAt.IsZero():
+			timestamp = finishedAt.Time
+		case phase == string(wfv1.NodeRunning) && !startedAt.IsZero():
+			timestamp = startedAt.Time
+		}
+		// keep the events of a node in order when the reported times are earlier than the observed ones
+		if seen && timestamp.Before(prev.Timestamp) {
+			timestamp = prev.Timestamp
+		}
+
+		events = append(events, JobEvent{
+			JobUid:          id,
+			NodeId:          nodeId,
+			Name:            name,
+			Phase:           phase,
+			Message:         message,
+			Timestamp:       timestamp.UTC().Truncate(time.Millisecond),
+			ResourceVersion: wf.ResourceVersion,
+		})
+	}
+
+	add("", "", string(wf.Status.Phase), wf.Status.Message, wf.Status.StartedAt, wf.Status.FinishedAt)
+	for _, node := range wf.Status.Nodes {
+		add(node.ID, node.DisplayName, string(node.Phase), node.Message, node.StartedAt, node.FinishedAt)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].NodeId < events[j].NodeId
+		}
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events
+}
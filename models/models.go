@@ -110,6 +110,10 @@ func init() {
 		{Type: reflect.TypeOf(MetadataWorkspaceList{}), Filename: "metadataworkspacelist.schema.json"},
 		{Type: reflect.TypeOf(FlowifyVolume{}), Filename: "volume.schema.json"},
 		{Type: reflect.TypeOf(FlowifyVolumeList{}), Filename: "volumelist.schema.json"},
+		{Type: reflect.TypeOf(Schedule{}), Filename: "schedule.schema.json"},
+		{Type: reflect.TypeOf(ScheduleList{}), Filename: "schedulelist.schema.json"},
+		{Type: reflect.TypeOf(Trigger{}), Filename: "trigger.schema.json"},
+		{Type: reflect.TypeOf(TriggerList{}), Filename: "triggerlist.schema.json"},
 	}
 
 	for _, s := range schemas {
@@ -315,11 +319,62 @@ type MetadataWorkspace struct {
 	Workspace string `json:"workspace" bson:"workspace"`
 }
 
+// The inputs of an exit handler set from the outcome of the job
+const (
+	// the phase of the job, e.g. Succeeded or Failed
+	ExitStatusInput = "status"
+	// a json list of the failed nodes of the job with their messages
+	ExitFailuresInput = "failures"
+)
+
 type Workflow struct {
 	Metadata  `json:",inline" bson:",inline"`
-	Component Component     `json:"component" bson:"component"`
-	Type      ComponentType `json:"type" bson:"type"`
-	Workspace string        `json:"workspace" bson:"workspace"`
+	Component Component       `json:"component" bson:"component"`
+	Type      ComponentType   `json:"type" bson:"type"`
+	Workspace string          `json:"workspace" bson:"workspace"`
+	Policy    *WorkflowPolicy `json:"policy,omitempty" bson:"policy,omitempty"`
+	// runs when the job ends, whatever its outcome
+	OnExit *Node `json:"onExit,omitempty" bson:"onExit,omitempty"`
+}
+
+// ValidateOnExit checks that the inputs of an inline exit handler can be satisfied. The status and failures inputs
+// are set from the outcome of the job, all other inputs take the workflow input of the same name and type.
+// Referenced exit handlers are checked once dereferenced
+func (wf Workflow) ValidateOnExit() error {
+	if wf.OnExit == nil {
+		return nil
+	}
+	cmp, ok := wf.OnExit.Node.(Component)
+	if !ok {
+		return nil
+	}
+
+	for _, in := range cmp.Inputs {
+		switch in.Name {
+		case ExitStatusInput, ExitFailuresInput:
+			if in.Type != FlowifyParameterType {
+				return fmt.Errorf("exit handler input '%s' has to be a parameter", in.Name)
+			}
+			continue
+		}
+
+		switch in.Type {
+		case FlowifyParameterType, FlowifySecretType, FlowifyVolumeType:
+		default:
+			return fmt.Errorf("exit handler input '%s' of type %s cannot be passed to the exit handler", in.Name, in.Type)
+		}
+		found := false
+		for _, wfIn := range wf.Component.Inputs {
+			if wfIn.Name == in.Name && wfIn.Type == in.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("exit handler input '%s' has no workflow input of the same name and type", in.Name)
+		}
+	}
+	return nil
 }
 
 type ComponentBase struct {
@@ -352,101 +407,6 @@ type Edge struct {
 	Target PortAddress `json:"target"`
 }
 
-type Expression struct {
-	Left     interface{} `json:"left" bson:"left"`
-	Right    interface{} `json:"right" bson:"right"`
-	Operator string      `json:"operator" bson:"operator"`
-}
-
-func (e Expression) Validate(doc []byte) error {
-	return ValidateDocument(doc, reflect.TypeOf(Expression{}))
-}
-
-func (e *Expression) UnmarshalJSON(document []byte) error {
-	var partialExpression struct {
-		Operator string          `json:"operator"`
-		Left     json.RawMessage `json:"left"`
-		Right    json.RawMessage `json:"right"`
-	}
-
-	err := json.Unmarshal(document, &partialExpression)
-	if err != nil {
-		return errors.Wrapf(err, "cannot unmarshal partial expression")
-	}
-	e.Operator = partialExpression.Operator
-
-	var strValue string
-	var dataValue Data
-	err = json.Unmarshal(partialExpression.Left, &strValue)
-	if err == nil {
-		e.Left = strValue
-	} else {
-		err = json.Unmarshal(partialExpression.Left, &dataValue)
-		if err != nil {
-			return errors.Wrap(err, "Cannot unmarshal expression (left value)")
-		}
-		e.Left = dataValue
-	}
-
-	err = json.Unmarshal(partialExpression.Right, &strValue)
-	if err == nil {
-		e.Right = strValue
-	} else {
-		err = json.Unmarshal(partialExpression.Right, &dataValue)
-		if err != nil {
-			return errors.Wrap(err, "Cannot unmarshal expression (right value)")
-		}
-		e.Right = dataValue
-	}
-
-	return nil
-}
-
-func (e *Expression) UnmarshalBSON(data []byte) error {
-	var rawData bson.Raw
-	err := bson.Unmarshal(data, &rawData)
-	if err != nil {
-		return errors.Wrapf(err, "cannot unmarshal expression")
-	}
-
-	var partialExpression struct {
-		Operator string   `bson:"operator"`
-		Left     bson.Raw `bson:"left"`
-		Right    bson.Raw `bson:"right"`
-	}
-	err = bson.Unmarshal(rawData, &partialExpression)
-	if err != nil {
-		return errors.Wrapf(err, "cannot unmarshal expression")
-	}
-	e.Operator = partialExpression.Operator
-
-	var strValue SourceTxt
-	var dataValue Data
-	err = strValue.UnmarshalBSONValue(bsontype.String, partialExpression.Left)
-	if err == nil {
-		e.Left = string(strValue)
-	} else {
-		err = bson.Unmarshal(partialExpression.Left, &dataValue)
-		if err != nil {
-			return errors.Wrapf(err, "Cannot unmarshal expression (left value)")
-		}
-		e.Left = dataValue
-	}
-
-	err = strValue.UnmarshalBSONValue(bsontype.String, partialExpression.Right)
-	if err == nil {
-		e.Right = string(strValue)
-	} else {
-		err = bson.Unmarshal(partialExpression.Right, &dataValue)
-		if err != nil {
-			return errors.Wrapf(err, "Cannot unmarshal expression (right value)")
-		}
-		e.Right = dataValue
-	}
-
-	return nil
-}
-
 type Node struct {
 	Id   string      `json:"id" bson:"id"`
 	Node interface{} `json:"node" bson:"node"`
@@ -541,12 +501,33 @@ func (n *Node) UnmarshalBSON(data []byte) error {
 	return errors.Errorf("cannot unmarshal node, unrecognized type of node.Node, node Id: %s", partial.Id)
 }
 
+// The outcome of a node a control edge waits for
+type ControlCondition string
+
+const (
+	// the source succeeded, or was skipped by a conditional, as for data edges
+	ControlConditionSucceeded ControlCondition = "succeeded"
+	// the source failed or errored
+	ControlConditionFailed ControlCondition = "failed"
+	// the source completed in any way
+	ControlConditionAny ControlCondition = "any"
+)
+
+// Orders two nodes of a graph without passing data between them
+type ControlEdge struct {
+	Source string `json:"source" bson:"source"`
+	Target string `json:"target" bson:"target"`
+	// defaults to succeeded
+	Condition ControlCondition `json:"condition,omitempty" bson:"condition,omitempty"`
+}
+
 type Graph struct {
 	ImplementationBase `json:",inline" bson:",inline"`
-	Nodes              []Node `json:"nodes,omitempty" bson:"nodes"`
-	Edges              []Edge `json:"edges,omitempty" bson:"edges"`
-	InputMappings      []Edge `json:"inputMappings,omitempty" bson:"inputMappings,omitempty"`
-	OutputMappings     []Edge `json:"outputMappings,omitempty" bson:"outputMappings,omitempty"`
+	Nodes              []Node        `json:"nodes,omitempty" bson:"nodes"`
+	Edges              []Edge        `json:"edges,omitempty" bson:"edges"`
+	ControlEdges       []ControlEdge `json:"controlEdges,omitempty" bson:"controlEdges,omitempty"`
+	InputMappings      []Edge        `json:"inputMappings,omitempty" bson:"inputMappings,omitempty"`
+	OutputMappings     []Edge        `json:"outputMappings,omitempty" bson:"outputMappings,omitempty"`
 }
 
 // implements the json.Unmarshaler (cf. https://pkg.go.dev/encoding/json#Unmarshaler)
@@ -726,9 +707,12 @@ func (c *Component) UnmarshalBSON(data []byte) error {
 }
 
 type PageInfo struct {
+	// the number of items before pagination, not counted when listing with a cursor
 	TotalNumber int `json:"totalNumber"`
 	Limit       int `json:"limit"`
 	Skip        int `json:"skip"`
+	// opaque cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type MetadataList struct {
@@ -955,6 +939,7 @@ type Brick struct {
 	Container          *corev1.Container `json:"container"`
 	Args               []Argument        `json:"args,omitempty" bson:"args"`
 	Results            []Result          `json:"results,omitempty" bson:"results"`
+	Policy             *ExecutionPolicy  `json:"policy,omitempty" bson:"policy,omitempty"`
 }
 
 type Data struct {
@@ -965,11 +950,36 @@ type Data struct {
 	Userdata json.RawMessage `json:"userdata,omitempty"`
 }
 
+// How a map iterates over its inputs
+type IterationMode string
+
+const (
+	// over the elements of a single parameter array
+	IterationModeSingle IterationMode = "single"
+	// element-wise over several parameter arrays, as many times as the shortest is long
+	IterationModeZip IterationMode = "zip"
+	// over all combinations of the elements of several parameter arrays
+	IterationModeProduct IterationMode = "product"
+	// over the files in a directory artifact, each iteration receives one file
+	IterationModeDirectory IterationMode = "directory"
+)
+
 type Map struct {
 	ImplementationBase `json:",inline" bson:",inline"`
 	Node               interface{} `json:"node" bson:"node"`
 	InputMappings      []Edge      `json:"inputMappings,omitempty" bson:"inputMappings,omitempty"`
-	OutputMappings     []Edge      `json:"outputMappings,omitempty" bson:"outputMappings,omitempty"`
+	// the outputs of the iterations are collected, parameters and parameter arrays into parameter arrays in iteration order,
+	// artifacts into a directory artifact with one entry per iteration named by its index: 0, 1, 2, ...
+	// The index of an iteration is its position in the iterated array, the combinations in product mode
+	// or the sorted files in directory mode
+	OutputMappings []Edge `json:"outputMappings,omitempty" bson:"outputMappings,omitempty"`
+	// defaults to single
+	Mode IterationMode `json:"mode,omitempty" bson:"mode,omitempty"`
+	// the inputs of the map iterated over, by default all parameter arrays mapped onto parameters of the node,
+	// or the artifacts mapped onto artifacts when iterating over a directory
+	Over []string `json:"over,omitempty" bson:"over,omitempty"`
+	// the maximum number of iterations running at the same time, unlimited by default
+	Parallelism *int64 `json:"parallelism,omitempty" bson:"parallelism,omitempty"`
 }
 
 func (m *Map) UnmarshalJSON(document []byte) error {
@@ -978,6 +988,9 @@ func (m *Map) UnmarshalJSON(document []byte) error {
 		Node               json.RawMessage `json:"node"`
 		InputMappings      []Edge          `json:"inputMappings,omitempty"`
 		OutputMappings     []Edge          `json:"outputMappings,omitempty"`
+		Mode               IterationMode   `json:"mode,omitempty"`
+		Over               []string        `json:"over,omitempty"`
+		Parallelism        *int64          `json:"parallelism,omitempty"`
 	}
 
 	err := json.Unmarshal(document, &partialMapCmp)
@@ -987,6 +1000,9 @@ func (m *Map) UnmarshalJSON(document []byte) error {
 	m.ImplementationBase = partialMapCmp.ImplementationBase
 	m.InputMappings = partialMapCmp.InputMappings
 	m.OutputMappings = partialMapCmp.OutputMappings
+	m.Mode = partialMapCmp.Mode
+	m.Over = partialMapCmp.Over
+	m.Parallelism = partialMapCmp.Parallelism
 
 	var cref ComponentReference
 	err = json.Unmarshal(partialMapCmp.Node, &cref)
@@ -1020,9 +1036,12 @@ func (m *Map) UnmarshalBSON(data []byte) error {
 	}
 	var partialMapCmp struct {
 		ImplementationBase `bson:",inline"`
-		Node               bson.Raw `bson:"node"`
-		InputMappings      []Edge   `bson:"inputMappings,omitempty"`
-		OutputMappings     []Edge   `bson:"outputMappings,omitempty"`
+		Node               bson.Raw      `bson:"node"`
+		InputMappings      []Edge        `bson:"inputMappings,omitempty"`
+		OutputMappings     []Edge        `bson:"outputMappings,omitempty"`
+		Mode               IterationMode `bson:"mode,omitempty"`
+		Over               []string      `bson:"over,omitempty"`
+		Parallelism        *int64        `bson:"parallelism,omitempty"`
 	}
 
 	err = bson.Unmarshal(rawData, &partialMapCmp)
@@ -1032,6 +1051,9 @@ func (m *Map) UnmarshalBSON(data []byte) error {
 	m.ImplementationBase = partialMapCmp.ImplementationBase
 	m.InputMappings = partialMapCmp.InputMappings
 	m.OutputMappings = partialMapCmp.OutputMappings
+	m.Mode = partialMapCmp.Mode
+	m.Over = partialMapCmp.Over
+	m.Parallelism = partialMapCmp.Parallelism
 
 	var cref ComponentReference
 	err = cref.UnmarshalBSONValue(bsontype.String, partialMapCmp.Node)
@@ -4,12 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func init() {
@@ -139,6 +143,15 @@ func Test_ExprRoundtrip(t *testing.T) {
 				"right": "5"
 			}`,
 		},
+		{
+			name: "Tree",
+			expr: `{
+				"or": [
+					{ "left": { "statusOf": "loader" }, "operator": "==", "right": "Failed" },
+					{ "not": { "left": { "name": "count", "type": "parameter" }, "operator": "<", "right": 2.5 } }
+				]
+			}`,
+		},
 	}
 	for _, test := range exprTests {
 		t.Run(test.name, func(t *testing.T) {
@@ -222,6 +235,8 @@ func Test_WorkflowRoundtrip(t *testing.T) {
 	}{
 		{"examples/minimal-any-workflow.json"},
 		{"examples/hello-world-workflow.json"},
+		{"examples/policy-workflow.json"},
+		{"examples/exit-handler-workflow.json"},
 	}
 	for _, test := range wfTests {
 		t.Run(test.filename, func(t *testing.T) {
@@ -245,6 +260,10 @@ func Test_ComponentRoundtrip(t *testing.T) {
 		{"examples/two-node-graph-component.json", Graph{}},
 		{"examples/two-node-graph-component-with-cref.json", Graph{}},
 		{"examples/brick-parameter-component.json", Brick{}},
+		{"examples/control-edges-graph-component.json", Graph{}},
+		{"examples/status-conditional-graph-component.json", Graph{}},
+		{"examples/zip-map-component.json", Map{}},
+		{"examples/collect-map-component.json", Map{}},
 	}
 	for _, test := range cmpTests {
 		t.Run(test.filename, func(t *testing.T) {
@@ -275,9 +294,15 @@ func Test_ComponentSpec(t *testing.T) {
 		{"examples/minimal-brick-component.json", "spec/component.schema.json"},
 		{"examples/brick-parameter-component.json", "spec/component.schema.json"},
 		{"examples/multi-level-secrets.json", "spec/component.schema.json"},
+		{"examples/control-edges-graph-component.json", "spec/component.schema.json"},
+		{"examples/status-conditional-graph-component.json", "spec/component.schema.json"},
+		{"examples/zip-map-component.json", "spec/component.schema.json"},
+		{"examples/collect-map-component.json", "spec/component.schema.json"},
 		// wfs
 		{"examples/minimal-any-workflow.json", "spec/workflow.schema.json"},
 		{"examples/hello-world-workflow.json", "spec/workflow.schema.json"},
+		{"examples/policy-workflow.json", "spec/workflow.schema.json"},
+		{"examples/exit-handler-workflow.json", "spec/workflow.schema.json"},
 		// jobs
 		{"examples/job-example.json", "spec/job.schema.json"},
 		{"examples/job-map-example.json", "spec/job.schema.json"},
@@ -362,3 +387,235 @@ func Test_Version(t *testing.T) {
 		assert.Equal(t, test.expected, test.actual)
 	}
 }
+
+func Test_JobEventsFromWorkflow(t *testing.T) {
+	id := NewComponentReference()
+	observed := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	started := metav1.NewTime(observed.Add(-time.Minute))
+	finished := metav1.NewTime(observed.Add(time.Minute))
+
+	wf := &wfv1.Workflow{}
+	wf.ResourceVersion = "1"
+	wf.Status.Phase = wfv1.WorkflowRunning
+	wf.Status.StartedAt = started
+	wf.Status.Nodes = wfv1.Nodes{
+		"a": {ID: "a", DisplayName: "A", Phase: wfv1.NodePending},
+		"b": {ID: "b", DisplayName: "B", Phase: ""},
+	}
+
+	events := JobEventsFromWorkflow(id, wf, nil, observed)
+	assert.Equal(t, []JobEvent{
+		{JobUid: id, Phase: "Running", Timestamp: started.Time, ResourceVersion: "1"},
+		{JobUid: id, NodeId: "a", Name: "A", Phase: "Pending", Timestamp: observed, ResourceVersion: "1"},
+	}, events, "running reported at its start, unknown phases skipped")
+	latest := LatestJobEvents(nil, events)
+
+	// unchanged state
+	assert.Empty(t, JobEventsFromWorkflow(id, wf, latest, observed.Add(time.Second)))
+
+	wf.ResourceVersion = "2"
+	wf.Status.Nodes["a"] = wfv1.NodeStatus{ID: "a", DisplayName: "A", Phase: wfv1.NodeRunning, StartedAt: started}
+	wf.Status.Nodes["b"] = wfv1.NodeStatus{ID: "b", DisplayName: "B", Phase: wfv1.NodeFailed, Message: "boom", FinishedAt: finished}
+	events = JobEventsFromWorkflow(id, wf, latest, observed.Add(time.Second))
+	assert.Equal(t, []JobEvent{
+		{JobUid: id, NodeId: "a", Name: "A", Phase: "Running", Timestamp: observed, ResourceVersion: "2"},
+		{JobUid: id, NodeId: "b", Name: "B", Phase: "Failed", Message: "boom", Timestamp: finished.Time, ResourceVersion: "2"},
+	}, events, "node events kept in order when started before observed pending")
+
+	latest = LatestJobEvents(latest, events)
+	assert.Len(t, latest, 3)
+	assert.Equal(t, "Failed", latest["b"].Phase)
+}
+
+func Test_TriggerJobInputValues(t *testing.T) {
+	trigger := Trigger{
+		InputValues: []Value{{Target: "container", Value: "landing"}, {Target: "blob", Value: "default.csv"}},
+		Mappings: []TriggerMapping{
+			{Target: "blob", Path: "data.blob.name"},
+			{Target: "size", Path: "data.blob.size"},
+			{Target: "tags", Path: "data.tags"},
+			{Target: "first", Path: "data.tags.0"},
+			{Target: "meta", Path: "data.meta"},
+		},
+	}
+	payload := []byte(`{"data": {"blob": {"name": "2022/10/01.csv", "size": 1024}, "tags": ["raw", "daily"], "meta": {"a": 1}}}`)
+
+	values, err := trigger.JobInputValues(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, []Value{
+		{Target: "container", Value: "landing"},
+		{Target: "blob", Value: "2022/10/01.csv"},
+		{Target: "size", Value: "1024"},
+		{Target: "tags", Value: []string{"raw", "daily"}},
+		{Target: "first", Value: "raw"},
+		{Target: "meta", Value: `{"a":1}`},
+	}, values, "mapped values override static values")
+
+	_, err = trigger.JobInputValues([]byte(`{"data": {}}`))
+	assert.ErrorContains(t, err, "no value at 'data.blob.name'")
+	_, err = trigger.JobInputValues([]byte(`not json`))
+	assert.Error(t, err)
+
+	// without mappings the payload is ignored
+	values, err = Trigger{InputValues: trigger.InputValues}.JobInputValues(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, trigger.InputValues, values)
+}
+
+func Test_WorkflowValidateOnExit(t *testing.T) {
+	raw, err := os.ReadFile("examples/exit-handler-workflow.json")
+	assert.Nil(t, err)
+	var wf Workflow
+	assert.Nil(t, json.Unmarshal(raw, &wf))
+	assert.Nil(t, wf.ValidateOnExit())
+
+	withExitInput := func(in Data) Workflow {
+		exit := wf.OnExit.Node.(Component)
+		exit.Inputs = append([]Data{in}, exit.Inputs...)
+		out := wf
+		out.OnExit = &Node{Id: wf.OnExit.Id, Node: exit}
+		return out
+	}
+
+	testCases := []struct {
+		Name     string
+		Workflow Workflow
+		Valid    bool
+	}{
+		{"no exit handler", Workflow{Component: wf.Component}, true},
+		{"referenced exit handler", Workflow{Component: wf.Component, OnExit: &Node{Id: "notify", Node: NewComponentReference()}}, true},
+		{"status as secret", withExitInput(Data{Name: ExitStatusInput, Type: FlowifySecretType}), false},
+		{"unknown input", withExitInput(Data{Name: "recipient", Type: FlowifyParameterType}), false},
+		{"input of other type", withExitInput(Data{Name: "channel", Type: FlowifyVolumeType}), false},
+		{"artifact input", withExitInput(Data{Name: "report", Type: FlowifyArtifactType}), false},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			err := test.Workflow.ValidateOnExit()
+			assert.Equal(t, test.Valid, err == nil, err)
+		})
+	}
+
+	// the schema rejects exit handler inputs that can never be passed
+	var doc map[string]any
+	assert.Nil(t, json.Unmarshal(raw, &doc))
+	inputs := doc["onExit"].(map[string]any)["node"].(map[string]any)["inputs"].([]any)
+	inputs[0].(map[string]any)["type"] = "artifact"
+	invalid, err := json.Marshal(doc)
+	assert.Nil(t, err)
+	assert.NotNil(t, Validate(invalid, "spec/workflow.schema.json"))
+}
+
+func Test_ExpressionUnmarshal(t *testing.T) {
+	raw := []byte(`{
+		"and": [
+			{ "left": { "statusOf": "loader" }, "operator": "!=", "right": "Succeeded" },
+			{ "not": { "left": { "name": "count", "type": "parameter" }, "operator": "<", "right": 3, "compare": "number" } }
+		]
+	}`)
+	expected := Expression{And: []Expression{
+		{Left: NodeStatus{Node: "loader"}, Operator: "!=", Right: "Succeeded"},
+		{Not: &Expression{Left: Data{Name: "count", Type: FlowifyParameterType}, Operator: "<", Right: float64(3), Compare: NumberComparison}},
+	}}
+
+	var expr Expression
+	assert.Nil(t, json.Unmarshal(raw, &expr))
+	assert.Equal(t, expected, expr)
+	assert.Equal(t, []string{"loader"}, expr.StatusNodes())
+
+	doc, err := bson.Marshal(expr)
+	assert.Nil(t, err)
+	var expr2 Expression
+	assert.Nil(t, bson.Unmarshal(doc, &expr2))
+	assert.Equal(t, expected, expr2)
+}
+
+func Test_ExpressionCheck(t *testing.T) {
+	inputs := []Data{
+		{Name: "count", Type: FlowifyParameterType},
+		{Name: "name", Type: FlowifyParameterType},
+		{Name: "data", Type: FlowifyArtifactType},
+	}
+	count := Data{Name: "count", Type: FlowifyParameterType}
+	name := Data{Name: "name", Type: FlowifyParameterType}
+	loader := NodeStatus{Node: "loader"}
+
+	testCases := []struct {
+		Name       string
+		Expression Expression
+		Path       string // of the error, "-" if valid
+	}{
+		{"number comparison", Expression{Left: count, Operator: ">=", Right: "5"}, "-"},
+		{"number literal", Expression{Left: count, Operator: "<", Right: 2.5}, "-"},
+		{"string comparison", Expression{Left: name, Operator: "==", Right: "a b"}, "-"},
+		{"string ordering", Expression{Left: name, Operator: "<", Right: "m", Compare: StringComparison}, "-"},
+		{"regex", Expression{Left: name, Operator: "matches", Right: "^a+$"}, "-"},
+		{"status", Expression{Left: loader, Operator: "!=", Right: "Succeeded"}, "-"},
+		{"combinators", Expression{Or: []Expression{
+			{Left: loader, Operator: "==", Right: "Failed"},
+			{And: []Expression{{Left: name, Operator: "==", Right: "x"}, {Not: &Expression{Left: count, Operator: ">", Right: "1"}}}},
+		}}, "-"},
+		{"unknown operator", Expression{Left: count, Operator: "=~", Right: "5"}, ""},
+		{"missing operator", Expression{Left: count, Right: "5"}, ""},
+		{"missing operand", Expression{Left: count, Operator: "=="}, "right"},
+		{"empty", Expression{}, ""},
+		{"two forms", Expression{Left: count, Operator: "==", Right: "5", Not: &Expression{Left: count, Operator: "==", Right: "5"}}, ""},
+		{"empty and", Expression{And: []Expression{}}, ""},
+		{"unknown comparison", Expression{Left: count, Operator: "==", Right: "5", Compare: "date"}, ""},
+		{"not a number", Expression{Left: count, Operator: ">", Right: "five"}, "right"},
+		{"nan", Expression{Left: count, Operator: ">", Right: "NaN"}, "right"},
+		{"unknown input", Expression{Left: Data{Name: "size", Type: FlowifyParameterType}, Operator: "==", Right: "5"}, "left"},
+		{"artifact input", Expression{Left: Data{Name: "data", Type: FlowifyArtifactType}, Operator: "==", Right: "5"}, "left"},
+		{"invalid regex", Expression{Left: name, Operator: "matches", Right: "a(b"}, "right"},
+		{"number regex", Expression{Left: name, Operator: "matches", Right: 5.0}, "right"},
+		{"number matches", Expression{Left: name, Operator: "matches", Right: "a", Compare: NumberComparison}, ""},
+		{"template literal", Expression{Left: name, Operator: "==", Right: "{{workflow.name}}"}, "right"},
+		{"status ordering", Expression{Left: loader, Operator: "<", Right: "Failed", Compare: StringComparison}, ""},
+		{"unknown phase", Expression{Left: loader, Operator: "==", Right: "Running"}, ""},
+		{"status against input", Expression{Left: loader, Operator: "==", Right: name}, ""},
+		{"nested", Expression{Or: []Expression{
+			{Left: name, Operator: "==", Right: "x"},
+			{Not: &Expression{Left: count, Operator: ">", Right: "x"}},
+		}}, "or[1].not.right"},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			err := test.Expression.Check(inputs)
+			if test.Path == "-" {
+				assert.Nil(t, err)
+				return
+			}
+			var exprErr *ExpressionError
+			if assert.ErrorAs(t, err, &exprErr) {
+				assert.Equal(t, test.Path, exprErr.Path, err.Error())
+			}
+		})
+	}
+}
+
+func Test_ValidateExpressions(t *testing.T) {
+	raw, err := os.ReadFile("examples/status-conditional-graph-component.json")
+	assert.Nil(t, err)
+	var cmp Component
+	assert.Nil(t, json.Unmarshal(raw, &cmp))
+	assert.Nil(t, cmp.ValidateExpressions())
+	assert.Nil(t, Workflow{Component: cmp}.ValidateExpressions())
+
+	// without the control edge the status of the loader is not available to the check
+	graph := cmp.Implementation.(Graph)
+	graph.ControlEdges = nil
+	detached := cmp
+	detached.Implementation = graph
+	err = detached.ValidateExpressions()
+	var exprErr *ExpressionError
+	assert.ErrorAs(t, err, &exprErr)
+
+	// a saved conditional checks the statuses passed in by the graph using it, unless it is run as a workflow
+	check := cmp.Implementation.(Graph).Nodes[1].Node.(Component)
+	assert.Nil(t, check.ValidateExpressions())
+	assert.ErrorAs(t, Workflow{Component: check}.ValidateExpressions(), &exprErr)
+
+	// the schema rejects unknown operators
+	invalid := []byte(strings.Replace(string(raw), `"operator": "matches"`, `"operator": "like"`, 1))
+	assert.NotNil(t, Validate(invalid, "spec/component.schema.json"))
+}
@@ -0,0 +1,46 @@
+package models
+
+// How often a failed node is retried
+type RetryPolicy struct {
+	// the number of retries, not counting the first attempt
+	Limit int32 `json:"limit" bson:"limit"`
+	// the failures retried: Always, OnFailure, OnError or OnTransientError, defaults to OnFailure
+	RetryPolicy string `json:"retryPolicy,omitempty" bson:"retryPolicy,omitempty"`
+	// the delay between attempts, by default a failed node is retried immediately
+	Backoff *BackoffPolicy `json:"backoff,omitempty" bson:"backoff,omitempty"`
+}
+
+type BackoffPolicy struct {
+	// the delay before the first retry, in seconds or as a duration, e.g. '30s' or '2m'
+	Duration string `json:"duration" bson:"duration"`
+	// multiplies the delay after each retry
+	Factor *int32 `json:"factor,omitempty" bson:"factor,omitempty"`
+	// the total time allowed for retrying
+	MaxDuration string `json:"maxDuration,omitempty" bson:"maxDuration,omitempty"`
+}
+
+// The execution policy of a brick, at workflow level the retry and timeout apply to all bricks without their own
+type ExecutionPolicy struct {
+	Retry *RetryPolicy `json:"retry,omitempty" bson:"retry,omitempty"`
+	// the maximum run time, for a brick the time the container may run, for a workflow the time of the whole job
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty" bson:"activeDeadlineSeconds,omitempty"`
+	// the maximum time of a node including the time it is pending, e.g. '10m'
+	Timeout string `json:"timeout,omitempty" bson:"timeout,omitempty"`
+	// the k8s priority class of the pods
+	PriorityClassName string `json:"priorityClassName,omitempty" bson:"priorityClassName,omitempty"`
+}
+
+// How long a finished job is kept in the cluster, in seconds
+type TTLPolicy struct {
+	SecondsAfterCompletion *int32 `json:"secondsAfterCompletion,omitempty" bson:"secondsAfterCompletion,omitempty"`
+	SecondsAfterSuccess    *int32 `json:"secondsAfterSuccess,omitempty" bson:"secondsAfterSuccess,omitempty"`
+	SecondsAfterFailure    *int32 `json:"secondsAfterFailure,omitempty" bson:"secondsAfterFailure,omitempty"`
+}
+
+// The execution policy of a workflow
+type WorkflowPolicy struct {
+	ExecutionPolicy `json:",inline" bson:",inline"`
+	TTL             *TTLPolicy `json:"ttl,omitempty" bson:"ttl,omitempty"`
+	// when the pods of the job are deleted: OnPodCompletion, OnPodSuccess, OnWorkflowCompletion or OnWorkflowSuccess
+	PodGC string `json:"podGC,omitempty" bson:"podGC,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// A stored workflow submitted as a job on a cron schedule
+type Schedule struct {
+	Metadata `json:",inline" bson:",inline"`
+	Type     ComponentType `json:"type" bson:"type"`
+	// the stored workflow to submit, pinned to the latest version on creation when no version is given
+	Workflow CRefVersion `json:"workflow" bson:"workflow"`
+	// the workspace of the workflow, client read-only
+	Workspace   string  `json:"workspace" bson:"workspace"`
+	InputValues []Value `json:"inputValues,omitempty" bson:"inputValues,omitempty"`
+	// a standard cron expression, e.g. '0 2 * * *', or a descriptor like '@daily'
+	Cron string `json:"cron" bson:"cron"`
+	// the IANA time zone the cron expression is evaluated in, defaults to the cluster time zone
+	Timezone  string `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	Suspended bool   `json:"suspended" bson:"suspended"`
+	// the job submitted on each run, rendered when the schedule is created
+	Job Job `json:"-" bson:"job"`
+}
+
+type ScheduleList struct {
+	Items    []Schedule `json:"items"`
+	PageInfo PageInfo   `json:"pageInfo"`
+}
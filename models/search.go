@@ -0,0 +1,27 @@
+package models
+
+const (
+	SearchKindComponent = "component"
+	SearchKindWorkflow  = "workflow"
+)
+
+// A part of a matched field, the matched terms are enclosed in <em> tags and the rest of the text is html escaped
+type SearchHighlight struct {
+	// the dot separated path of the field, e.g. 'inputs.name'
+	Field    string `json:"field"`
+	Fragment string `json:"fragment"`
+}
+
+// A component or workflow matching a search, the workspace is only set for workflows
+type SearchResult struct {
+	MetadataWorkspace `json:",inline"`
+	Kind              string `json:"kind"`
+	// the relevance of the match, results are ordered by descending score
+	Score      float64           `json:"score"`
+	Highlights []SearchHighlight `json:"highlights,omitempty"`
+}
+
+type SearchResultList struct {
+	Items    []SearchResult `json:"items"`
+	PageInfo PageInfo       `json:"pageInfo"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Maps a value of a webhook payload to a workflow input
+type TriggerMapping struct {
+	Target string `json:"target" bson:"target"`
+	// the dot separated path of the value in the json payload, e.g. 'data.url' or 'files.0'
+	Path string `json:"path" bson:"path"`
+}
+
+// A stored workflow submitted as a job when the webhook of the trigger is called
+type Trigger struct {
+	Metadata  `json:",inline" bson:",inline"`
+	Type      ComponentType `json:"type" bson:"type"`
+	Workspace string        `json:"workspace" bson:"workspace"`
+	// the stored workflow to submit, the latest version on each call when no version is given
+	Workflow CRefVersion `json:"workflow" bson:"workflow"`
+	// values for the inputs not mapped from the payload
+	InputValues []Value          `json:"inputValues,omitempty" bson:"inputValues,omitempty"`
+	Mappings    []TriggerMapping `json:"mappings,omitempty" bson:"mappings,omitempty"`
+	// the key signing the webhook url, never sent to clients
+	Secret string `json:"-" bson:"secret"`
+	// the signed webhook url, client read-only and only sent to workspace admins
+	Webhook string `json:"webhook,omitempty" bson:"-"`
+}
+
+type TriggerList struct {
+	Items    []Trigger `json:"items"`
+	PageInfo PageInfo  `json:"pageInfo"`
+}
+
+// JobInputValues returns the input values of a job started by a webhook call, the static values are overridden by
+// the values mapped from the json payload
+func (t Trigger) JobInputValues(payload []byte) ([]Value, error) {
+	values := []Value{}
+	mapped := map[string]bool{}
+	for _, m := range t.Mappings {
+		mapped[m.Target] = true
+	}
+	for _, v := range t.InputValues {
+		if !mapped[v.Target] {
+			values = append(values, v)
+		}
+	}
+	if len(t.Mappings) == 0 {
+		return values, nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return nil, errors.Wrap(err, "cannot read webhook payload")
+	}
+	for _, m := range t.Mappings {
+		v, err := lookupPath(document, m.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot map input %s", m.Target)
+		}
+		value, err := inputValue(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot map input %s from '%s'", m.Target, m.Path)
+		}
+		values = append(values, Value{Target: m.Target, Value: value})
+	}
+	return values, nil
+}
+
+func lookupPath(document interface{}, path string) (interface{}, error) {
+	current := document
+	if path == "" {
+		return current, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[key]
+			if !ok {
+				return nil, fmt.Errorf("no value at '%s'", path)
+			}
+			current = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("no value at '%s'", path)
+			}
+			current = c[i]
+		default:
+			return nil, fmt.Errorf("no value at '%s'", path)
+		}
+	}
+	return current, nil
+}
+
+// inputValue converts a json value to a parameter value, a string, or a parameter array value, a list of strings
+func inputValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, item := range value {
+			s, err := scalarString(item)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		return values, nil
+	default:
+		return scalarString(value)
+	}
+}
+
+func scalarString(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case nil:
+		return "", fmt.Errorf("value is null")
+	case float64, bool:
+		return fmt.Sprint(value), nil
+	default:
+		// objects are passed on as json
+		bytes, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	}
+}
@@ -0,0 +1,163 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	argoerrors "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	s3driver "github.com/argoproj/argo-workflows/v3/workflow/artifacts/s3"
+	"github.com/pkg/errors"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	ErrNotFound    = fmt.Errorf("artifact not found")
+	ErrUnsupported = fmt.Errorf("artifact location not supported")
+)
+
+const defaultS3Endpoint = "s3.amazonaws.com"
+
+type ArtifactClient interface {
+	// OpenArtifact streams an artifact produced by the workflow as stored, archived artifacts are not extracted.
+	// Artifacts with a key only are resolved against the artifact repository of the workflow
+	OpenArtifact(ctx context.Context, wf *wfv1.Workflow, art wfv1.Artifact) (io.ReadCloser, error)
+}
+
+// Implements artifact.ArtifactClient, supports raw, http and s3 compatible locations
+type ArtifactClientImpl struct {
+	clientSet  kubernetes.Interface
+	httpClient *http.Client
+}
+
+func NewArtifactClient(clientSet kubernetes.Interface) ArtifactClient {
+	return &ArtifactClientImpl{clientSet: clientSet, httpClient: http.DefaultClient}
+}
+
+// ResolveLocation returns the full location of an artifact of the workflow
+func ResolveLocation(wf *wfv1.Workflow, art wfv1.Artifact) (*wfv1.ArtifactLocation, error) {
+	location := art.ArtifactLocation.DeepCopy()
+	if location.HasLocation() {
+		return location, nil
+	}
+
+	var repository *wfv1.ArtifactRepository
+	if ref := wf.Status.ArtifactRepositoryRef; ref != nil {
+		repository = ref.ArtifactRepository
+	}
+	if err := location.Relocate(repository.ToArtifactLocation()); err != nil {
+		return nil, errors.Wrapf(err, "cannot resolve location of artifact %s", art.Name)
+	}
+	return location, nil
+}
+
+func (c *ArtifactClientImpl) OpenArtifact(ctx context.Context, wf *wfv1.Workflow, art wfv1.Artifact) (io.ReadCloser, error) {
+	location, err := ResolveLocation(wf, art)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case location.Raw != nil:
+		return io.NopCloser(strings.NewReader(location.Raw.Data)), nil
+	case location.HTTP != nil:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.HTTP.URL, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create artifact request")
+		}
+		for _, h := range location.HTTP.Headers {
+			req.Header.Add(h.Name, h.Value)
+		}
+		return c.get(req)
+	case location.S3 != nil:
+		return c.openS3(ctx, wf.Namespace, location)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+func (c *ArtifactClientImpl) get(req *http.Request) (io.ReadCloser, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get artifact")
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, errors.Errorf("cannot get artifact: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (c *ArtifactClientImpl) openS3(ctx context.Context, namespace string, location *wfv1.ArtifactLocation) (io.ReadCloser, error) {
+	s3 := location.S3
+	// the argo driver picks the credentials like the workflows do, static keys before roles, sdk and iam credentials
+	driver := &s3driver.ArtifactDriver{
+		Endpoint:    s3.Endpoint,
+		Region:      s3.Region,
+		Secure:      s3.Insecure == nil || !*s3.Insecure,
+		RoleARN:     s3.RoleARN,
+		UseSDKCreds: s3.UseSDKCreds,
+		Context:     ctx,
+	}
+	if driver.Endpoint == "" {
+		driver.Endpoint = defaultS3Endpoint
+	}
+	if s3.AccessKeySecret != nil && s3.SecretKeySecret != nil {
+		accessKey, err := c.secretValue(ctx, namespace, s3.AccessKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		secretKey, err := c.secretValue(ctx, namespace, s3.SecretKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		driver.AccessKey, driver.SecretKey = accessKey, secretKey
+	}
+	if enc := s3.EncryptionOptions; enc != nil {
+		driver.KmsKeyId = enc.KmsKeyId
+		driver.KmsEncryptionContext = enc.KmsEncryptionContext
+		driver.EnableEncryption = enc.EnableEncryption
+		if enc.ServerSideCustomerKeySecret != nil {
+			key, err := c.secretValue(ctx, namespace, enc.ServerSideCustomerKeySecret)
+			if err != nil {
+				return nil, err
+			}
+			driver.ServerSideCustomerKey = key
+		}
+	}
+
+	stream, err := driver.OpenStream(&wfv1.Artifact{ArtifactLocation: *location})
+	if err != nil {
+		switch {
+		case argoerrors.IsCode(argoerrors.CodeNotFound, err):
+			return nil, ErrNotFound
+		case argoerrors.IsCode(argoerrors.CodeNotImplemented, err):
+			// keys of directories are not streamed by the driver
+			return nil, ErrUnsupported
+		}
+		return nil, errors.Wrap(err, "cannot get artifact")
+	}
+	return stream, nil
+}
+
+func (c *ArtifactClientImpl) secretValue(ctx context.Context, namespace string, selector *core.SecretKeySelector) (string, error) {
+	secret, err := c.clientSet.CoreV1().Secrets(namespace).Get(ctx, selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot get artifact repository secret %s", selector.Name)
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", errors.Errorf("no key %s in artifact repository secret %s", selector.Key, selector.Name)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
@@ -0,0 +1,91 @@
+package artifact
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_OpenArtifact(t *testing.T) {
+	// stands in for an s3 compatible store, e.g. minio
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.URL.Path == "/artifacts/" && r.URL.Query().Has("location"):
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+		case r.URL.Path == "/artifacts/" && r.URL.Query().Get("list-type") == "2":
+			// no directory of the missing key
+			w.Write([]byte(`<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>artifacts</Name><KeyCount>0</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated></ListBucketResult>`))
+		case r.URL.Path == "/artifacts/job/pod/out.tgz":
+			w.Header().Set("Content-Length", "10")
+			w.Header().Set("Last-Modified", "Sat, 01 Oct 2022 12:00:00 GMT")
+			w.Header().Set("ETag", `"etag"`)
+			if r.Method != http.MethodHead {
+				w.Write([]byte("s3 content"))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	endpoint, _ := url.Parse(server.URL)
+
+	insecure := true
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "test"}}
+	wf.Status.ArtifactRepositoryRef = &wfv1.ArtifactRepositoryRefStatus{
+		ArtifactRepository: &wfv1.ArtifactRepository{
+			S3: &wfv1.S3ArtifactRepository{S3Bucket: wfv1.S3Bucket{
+				Endpoint:        endpoint.Host,
+				Bucket:          "artifacts",
+				Insecure:        &insecure,
+				AccessKeySecret: &core.SecretKeySelector{LocalObjectReference: core.LocalObjectReference{Name: "s3-cred"}, Key: "accessKey"},
+				SecretKeySecret: &core.SecretKeySelector{LocalObjectReference: core.LocalObjectReference{Name: "s3-cred"}, Key: "secretKey"},
+			}},
+		},
+	}
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "s3-cred", Namespace: "test"},
+		Data:       map[string][]byte{"accessKey": []byte("access"), "secretKey": []byte("secret")},
+	}
+	client := NewArtifactClient(fake.NewSimpleClientset(secret))
+
+	testCases := []struct {
+		Name            string
+		Artifact        wfv1.Artifact
+		ExpectedContent string
+		ExpectedError   error
+	}{
+		{"raw", wfv1.Artifact{Name: "raw", ArtifactLocation: wfv1.ArtifactLocation{Raw: &wfv1.RawArtifact{Data: "raw content"}}}, "raw content", nil},
+		{"s3 key only", wfv1.Artifact{Name: "out", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "job/pod/out.tgz"}}}, "s3 content", nil},
+		{"s3 missing", wfv1.Artifact{Name: "out", ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: "job/pod/missing.tgz"}}}, "", ErrNotFound},
+		{"unsupported", wfv1.Artifact{Name: "git", ArtifactLocation: wfv1.ArtifactLocation{Git: &wfv1.GitArtifact{Repo: "https://github.com/equinor/flowify-workflows-server"}}}, "", ErrUnsupported},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			r, err := client.OpenArtifact(context.TODO(), wf, test.Artifact)
+			require.Equal(t, test.ExpectedError, err)
+			if err != nil {
+				return
+			}
+			defer r.Close()
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, test.ExpectedContent, string(content))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package artifact
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/stretchr/testify/mock"
+)
+
+type ArtifactClientMock struct {
+	mock.Mock
+}
+
+func NewDefaultArtifactClientMock() *ArtifactClientMock {
+	obj := &ArtifactClientMock{}
+	obj.On("OpenArtifact", mock.Anything, mock.Anything, mock.Anything).Return(io.NopCloser(strings.NewReader("artifact")), nil)
+
+	return obj
+}
+
+func (m *ArtifactClientMock) OpenArtifact(ctx context.Context, wf *wfv1.Workflow, art wfv1.Artifact) (io.ReadCloser, error) {
+	args := m.Called(ctx, wf, art)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
@@ -0,0 +1,35 @@
+package jobmonitor
+
+import (
+	"context"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type JobMonitorMock struct {
+	mock.Mock
+}
+
+func NewDefaultJobMonitorMock() *JobMonitorMock {
+	obj := &JobMonitorMock{}
+	obj.On("Resume", mock.Anything).Return(nil)
+	obj.On("Watch", mock.Anything, mock.Anything).Return()
+	obj.On("Healthy").Return(nil)
+
+	return obj
+}
+
+func (m *JobMonitorMock) Resume(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *JobMonitorMock) Watch(id models.ComponentReference, workspace string) {
+	m.Called(id, workspace)
+}
+
+func (m *JobMonitorMock) Healthy() error {
+	args := m.Called()
+	return args.Error(0)
+}
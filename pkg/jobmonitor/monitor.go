@@ -0,0 +1,283 @@
+package jobmonitor
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// The delay before a failed or closed watch is reestablished
+var ReconnectDelay = 5 * time.Second
+
+type JobMonitor interface {
+	// Resume starts monitoring all jobs that have not completed and stores the final events of jobs
+	// that completed while they were not monitored, typically called on startup
+	Resume(ctx context.Context) error
+	// Watch starts monitoring a submitted job, unless it is already monitored
+	Watch(id models.ComponentReference, workspace string)
+	// Healthy returns the last error if the monitor currently fails to watch jobs or persist their events
+	Healthy() error
+}
+
+// Implements jobmonitor.JobMonitor
+type JobMonitorImpl struct {
+	ctx           context.Context
+	argoClient    argoclient.Interface
+	storageClient storage.ComponentClient
+
+	mu      sync.Mutex
+	watched map[models.ComponentReference]bool
+	// the last error of each job, removed when the job recovers
+	errs map[models.ComponentReference]error
+	// errors not tied to a single job, e.g. when resuming
+	resumeErr error
+}
+
+// NewJobMonitor returns a monitor persisting the events of jobs through the storage client.
+// All watches are stopped when the context is done
+func NewJobMonitor(ctx context.Context, argoClient argoclient.Interface, storageClient storage.ComponentClient) JobMonitor {
+	return &JobMonitorImpl{
+		ctx:           ctx,
+		argoClient:    argoClient,
+		storageClient: storageClient,
+		watched:       make(map[models.ComponentReference]bool),
+		errs:          make(map[models.ComponentReference]error),
+	}
+}
+
+func (m *JobMonitorImpl) Resume(ctx context.Context) error {
+	// completed workflows are listed too, they may have finished while the server was down
+	wfs, err := m.argoClient.ArgoprojV1alpha1().Workflows("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = errors.Wrap(err, "cannot list jobs")
+		m.setResumeError(err)
+		return err
+	}
+	m.setResumeError(nil)
+
+	saved := 0
+	for i := range wfs.Items {
+		wf := &wfs.Items[i]
+		// jobs are named by their uid, skip workflows not submitted through flowify
+		id, err := uuid.Parse(wf.Name)
+		if err != nil {
+			continue
+		}
+		if !wf.Status.Phase.Completed() && wf.Labels[common.LabelKeyCompleted] != "true" {
+			m.Watch(models.ComponentReference(id), wf.Namespace)
+			continue
+		}
+
+		ok, err := m.saveFinalEvents(ctx, models.ComponentReference(id), wf)
+		if err != nil {
+			// the watch receives the current state and retries until it is stored
+			log.Errorf("cannot save the final events of job %s: %s", id.String(), err.Error())
+			m.Watch(models.ComponentReference(id), wf.Namespace)
+			continue
+		}
+		if ok {
+			saved++
+		}
+	}
+	log.Infof("Job monitor resumed watching %d jobs, saved the final events of %d jobs", m.Watching(), saved)
+	return nil
+}
+
+// saveFinalEvents stores the final state of a completed job unless its terminal event is already stored.
+// Returns true when events were stored
+func (m *JobMonitorImpl) saveFinalEvents(ctx context.Context, id models.ComponentReference, wf *wfv1.Workflow) (bool, error) {
+	events, err := m.storageClient.ListJobEvents(ctx, id)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot get job events")
+	}
+	latest := models.LatestJobEvents(nil, events)
+	if job, ok := latest[""]; ok && wfv1.WorkflowPhase(job.Phase).Completed() {
+		return false, nil
+	}
+
+	events = models.JobEventsFromWorkflow(id, wf, latest, time.Now())
+	if len(events) == 0 {
+		return false, nil
+	}
+	if err := m.storageClient.AddJobEvents(ctx, id, events); err != nil {
+		return false, errors.Wrap(err, "cannot save job events")
+	}
+	return true, nil
+}
+
+func (m *JobMonitorImpl) Watch(id models.ComponentReference, workspace string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.watched[id] {
+		return
+	}
+	m.watched[id] = true
+	go m.watchJob(id, workspace)
+}
+
+// Watching returns the number of jobs currently monitored
+func (m *JobMonitorImpl) Watching() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.watched)
+}
+
+func (m *JobMonitorImpl) Healthy() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.resumeErr != nil {
+		return m.resumeErr
+	}
+	for id, err := range m.errs {
+		return errors.Wrapf(err, "job %s", id.String())
+	}
+	return nil
+}
+
+func (m *JobMonitorImpl) setResumeError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resumeErr = err
+}
+
+func (m *JobMonitorImpl) setError(id models.ComponentReference, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		delete(m.errs, id)
+		return
+	}
+	m.errs[id] = err
+}
+
+func (m *JobMonitorImpl) done(id models.ComponentReference) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.watched, id)
+	delete(m.errs, id)
+}
+
+// watchJob persists the status changes of a job until it completes, is deleted or the monitor is stopped.
+// Closed watches are reestablished from the last seen resource version so no changes are lost
+func (m *JobMonitorImpl) watchJob(id models.ComponentReference, workspace string) {
+	defer m.done(id)
+
+	logger := log.WithFields(log.Fields{"job": id.String(), "workspace": workspace})
+	wfi := m.argoClient.ArgoprojV1alpha1().Workflows(workspace)
+	resourceVersion := ""
+	// the latest event of the job and each node, changes are stored relative to these
+	var latest map[string]models.JobEvent
+
+	for {
+		if latest == nil {
+			events, err := m.storageClient.ListJobEvents(m.ctx, id)
+			if err != nil {
+				logger.Errorf("cannot get job events: %s", err.Error())
+				m.setError(id, errors.Wrap(err, "cannot get job events"))
+			} else {
+				latest = models.LatestJobEvents(nil, events)
+			}
+		}
+
+		if latest != nil {
+			w, err := wfi.Watch(m.ctx, metav1.ListOptions{
+				FieldSelector:   fields.OneTermEqualSelector("metadata.name", id.String()).String(),
+				ResourceVersion: resourceVersion,
+			})
+			if err != nil {
+				logger.Errorf("cannot watch job: %s", err.Error())
+				m.setError(id, errors.Wrap(err, "cannot watch job"))
+			} else {
+				var completed bool
+				resourceVersion, completed = m.handleEvents(id, w, resourceVersion, latest, logger)
+				w.Stop()
+				if completed {
+					logger.Info("Job events saved")
+					return
+				}
+				if m.ctx.Err() != nil {
+					return
+				}
+			}
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(ReconnectDelay):
+			logger.Debugf("Reconnecting job watch from resource version '%s'", resourceVersion)
+		}
+	}
+}
+
+// handleEvents persists the status changes of a watch until it is closed, updating the latest events.
+// Returns the resource version to resume from, and true when the job has completed or was deleted
+func (m *JobMonitorImpl) handleEvents(id models.ComponentReference, w watch.Interface, resourceVersion string, latest map[string]models.JobEvent, logger *log.Entry) (string, bool) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return resourceVersion, false
+		case event, open := <-w.ResultChan():
+			if !open {
+				logger.Info("Job watch closed")
+				return resourceVersion, false
+			}
+
+			if event.Type == watch.Error {
+				err := apierr.FromObject(event.Object)
+				if status, ok := err.(apierr.APIStatus); ok && status.Status().Code == http.StatusGone {
+					// the resource version is too old, start over from the current state
+					logger.Warn("Job watch expired, restarting from the current state")
+					return "", false
+				}
+				logger.Errorf("job watch error: %s", err.Error())
+				m.setError(id, errors.Wrap(err, "job watch error"))
+				return resourceVersion, false
+			}
+
+			wf, ok := event.Object.(*wfv1.Workflow)
+			if !ok {
+				logger.Warnf("unexpected job watch object: %T", event.Object)
+				continue
+			}
+
+			// the current state is sent again after a restart, only changes are stored
+			events := models.JobEventsFromWorkflow(id, wf, latest, time.Now())
+			if len(events) > 0 {
+				if err := m.storageClient.AddJobEvents(m.ctx, id, events); err != nil {
+					// try again from the previous version after reconnecting
+					logger.Errorf("cannot save job events: %s", err.Error())
+					m.setError(id, errors.Wrap(err, "cannot save job events"))
+					return resourceVersion, false
+				}
+				models.LatestJobEvents(latest, events)
+			}
+			m.setError(id, nil)
+			resourceVersion = wf.ResourceVersion
+
+			if event.Type == watch.Deleted || wf.Status.Phase.Completed() {
+				return resourceVersion, true
+			}
+		}
+	}
+}
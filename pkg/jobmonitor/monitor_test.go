@@ -0,0 +1,189 @@
+package jobmonitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// records the job events, fails the first calls if requested
+type eventStorage struct {
+	storage.ComponentClient
+
+	mu     sync.Mutex
+	events map[models.ComponentReference][]models.JobEvent
+	fail   int
+}
+
+func newEventStorage() *eventStorage {
+	return &eventStorage{events: make(map[models.ComponentReference][]models.JobEvent)}
+}
+
+func (s *eventStorage) AddJobEvents(ctx context.Context, id models.ComponentReference, events []models.JobEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail > 0 {
+		s.fail--
+		return fmt.Errorf("storage unavailable")
+	}
+	s.events[id] = append(s.events[id], events...)
+	return nil
+}
+
+func (s *eventStorage) ListJobEvents(ctx context.Context, id models.ComponentReference) ([]models.JobEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.JobEvent{}, s.events[id]...), nil
+}
+
+// the phases of the job itself
+func (s *eventStorage) phases(id models.ComponentReference) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	phases := []string{}
+	for _, e := range s.events[id] {
+		if e.NodeId == "" {
+			phases = append(phases, e.Phase)
+		}
+	}
+	return phases
+}
+
+func makeWorkflow(name string, workspace string, phase wfv1.WorkflowPhase, resourceVersion string) *wfv1.Workflow {
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: workspace, ResourceVersion: resourceVersion}}
+	wf.Status.Phase = phase
+	return wf
+}
+
+func init() {
+	ReconnectDelay = 10 * time.Millisecond
+}
+
+func Test_ResumeJobMonitor(t *testing.T) {
+	running := makeWorkflow("9b75d74e-681c-496a-bc43-496a798b9f01", "test", wfv1.WorkflowRunning, "")
+	finished := makeWorkflow("9b75d74e-681c-496a-bc43-496a798b9f02", "test", wfv1.WorkflowSucceeded, "")
+	stored := makeWorkflow("9b75d74e-681c-496a-bc43-496a798b9f05", "test", wfv1.WorkflowFailed, "")
+	other := makeWorkflow("not-a-job", "test", wfv1.WorkflowRunning, "")
+
+	argoClient := fake.NewSimpleClientset(running, finished, stored, other)
+	storageClient := newEventStorage()
+	// stored before a restart, the finished job completed while the server was down
+	storageClient.events[models.NewReference(running.Name)] = []models.JobEvent{{Phase: string(wfv1.WorkflowRunning), Timestamp: time.Now()}}
+	storageClient.events[models.NewReference(finished.Name)] = []models.JobEvent{{Phase: string(wfv1.WorkflowRunning), Timestamp: time.Now()}}
+	storageClient.events[models.NewReference(stored.Name)] = []models.JobEvent{{Phase: string(wfv1.WorkflowFailed), Timestamp: time.Now()}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	monitor := NewJobMonitor(ctx, argoClient, storageClient).(*JobMonitorImpl)
+	require.NoError(t, monitor.Resume(ctx))
+	require.Equal(t, 1, monitor.Watching(), "only running flowify jobs are resumed")
+	require.NoError(t, monitor.Healthy())
+	require.Equal(t, []string{"Running", "Succeeded"}, storageClient.phases(models.NewReference(finished.Name)), "the final state of a job completed while not monitored is stored")
+	require.Equal(t, []string{"Failed"}, storageClient.phases(models.NewReference(stored.Name)), "stored terminal events are kept")
+
+	// the watch is started asynchronously
+	require.Eventually(t, func() bool {
+		for _, a := range argoClient.Actions() {
+			if a.GetVerb() == "watch" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	// watching the same job twice is a noop
+	monitor.Watch(models.NewReference(running.Name), "test")
+	require.Equal(t, 1, monitor.Watching())
+
+	_, err := argoClient.ArgoprojV1alpha1().Workflows("test").Update(ctx, running, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	running.Status.Phase = wfv1.WorkflowSucceeded
+	_, err = argoClient.ArgoprojV1alpha1().Workflows("test").Update(ctx, running, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// the monitor stops when the job completes
+	require.Eventually(t, func() bool { return monitor.Watching() == 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"Running", "Succeeded"}, storageClient.phases(models.NewReference(running.Name)))
+}
+
+func Test_JobMonitorReconnect(t *testing.T) {
+	const name = "9b75d74e-681c-496a-bc43-496a798b9f03"
+	id := models.NewReference(name)
+
+	argoClient := fake.NewSimpleClientset()
+	watchers := make(chan *watch.FakeWatcher, 3)
+	var resourceVersions []string
+	var mu sync.Mutex
+	argoClient.PrependWatchReactor("workflows", func(action ktesting.Action) (bool, watch.Interface, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resourceVersions = append(resourceVersions, action.(ktesting.WatchActionImpl).WatchRestrictions.ResourceVersion)
+		w := watch.NewFakeWithChanSize(10, false)
+		watchers <- w
+		return true, w, nil
+	})
+
+	storageClient := newEventStorage()
+	storageClient.fail = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	monitor := NewJobMonitor(ctx, argoClient, storageClient).(*JobMonitorImpl)
+	monitor.Watch(id, "test")
+
+	// the first event cannot be stored, the monitor reports it and reconnects from the start
+	w := <-watchers
+	w.Add(makeWorkflow(name, "test", wfv1.WorkflowPending, "1"))
+	require.Eventually(t, func() bool { return monitor.Healthy() != nil }, time.Second, 10*time.Millisecond)
+
+	w = <-watchers
+	w.Add(makeWorkflow(name, "test", wfv1.WorkflowPending, "1"))
+	wf := makeWorkflow(name, "test", wfv1.WorkflowRunning, "2")
+	wf.Status.Nodes = wfv1.Nodes{"a": wfv1.NodeStatus{ID: "a", DisplayName: "a", Phase: wfv1.NodeRunning}}
+	w.Modify(wf)
+	require.Eventually(t, func() bool { return len(storageClient.phases(id)) == 2 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, monitor.Healthy())
+
+	// a closed watch is reestablished from the last stored version, unchanged states are not stored again
+	w.Stop()
+	w = <-watchers
+	w.Modify(wf)
+	wf = makeWorkflow(name, "test", wfv1.WorkflowFailed, "3")
+	wf.Status.Nodes = wfv1.Nodes{"a": wfv1.NodeStatus{ID: "a", DisplayName: "a", Phase: wfv1.NodeFailed, Message: "boom"}}
+	w.Modify(wf)
+	require.Eventually(t, func() bool { return monitor.Watching() == 0 }, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, []string{"Pending", "Running", "Failed"}, storageClient.phases(id))
+	events, _ := storageClient.ListJobEvents(ctx, id)
+	require.Len(t, events, 5, "two node events")
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"", "", "2"}, resourceVersions)
+}
+
+func Test_JobMonitorStop(t *testing.T) {
+	const name = "9b75d74e-681c-496a-bc43-496a798b9f04"
+	argoClient := fake.NewSimpleClientset(makeWorkflow(name, "test", wfv1.WorkflowRunning, ""))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	monitor := NewJobMonitor(ctx, argoClient, newEventStorage()).(*JobMonitorImpl)
+	monitor.Watch(models.NewReference(name), "test")
+	require.Equal(t, 1, monitor.Watching())
+
+	cancel()
+	require.Eventually(t, func() bool { return monitor.Watching() == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// The label carrying the schedule uid on the cron workflow and the workflows of its runs
+const ScheduleLabel = "flowify.io/schedule"
+
+// The delay before a failed or closed watch is reestablished
+var ReconnectDelay = 5 * time.Second
+
+// The runs of a schedule are started by an argo cron workflow with generated names. Since jobs are named by their uid,
+// each run is created suspended and replaced by a job workflow with the same spec once the job is stored
+type Scheduler interface {
+	// Run adopts the runs of all schedules until the context is done
+	Run(ctx context.Context)
+}
+
+// Implements scheduler.Scheduler
+type SchedulerImpl struct {
+	argoClient     argoclient.Interface
+	scheduleClient storage.ScheduleClient
+	monitor        jobmonitor.JobMonitor
+}
+
+func NewScheduler(argoClient argoclient.Interface, scheduleClient storage.ScheduleClient, monitor jobmonitor.JobMonitor) Scheduler {
+	return &SchedulerImpl{argoClient: argoClient, scheduleClient: scheduleClient, monitor: monitor}
+}
+
+// NewCronWorkflow returns the cron workflow running the transpiled workflow of a schedule, the runs are created suspended
+func NewCronWorkflow(schedule models.Schedule, wf *wfv1.Workflow) *wfv1.CronWorkflow {
+	spec := wf.Spec.DeepCopy()
+	suspend := true
+	spec.Suspend = &suspend
+
+	labels := map[string]string{ScheduleLabel: schedule.Uid.String()}
+	return &wfv1.CronWorkflow{
+		ObjectMeta: metav1.ObjectMeta{Name: schedule.Uid.String(), Namespace: schedule.Workspace, Labels: labels},
+		Spec: wfv1.CronWorkflowSpec{
+			WorkflowSpec:     *spec,
+			Schedule:         schedule.Cron,
+			Timezone:         schedule.Timezone,
+			Suspend:          schedule.Suspended,
+			WorkflowMetadata: &metav1.ObjectMeta{Labels: labels, Annotations: wf.Annotations},
+		},
+	}
+}
+
+// JobUid returns the uid of the job of a scheduled run, the same run always gives the same job
+func JobUid(run *wfv1.Workflow) models.ComponentReference {
+	return models.ComponentReference(uuid.NewSHA1(uuid.NameSpaceURL, []byte(run.Namespace+"/"+run.Name)))
+}
+
+// NewJobWorkflow returns the workflow of the job of a scheduled run, detached from the cron workflow
+func NewJobWorkflow(run *wfv1.Workflow, jobUid models.ComponentReference) *wfv1.Workflow {
+	labels := map[string]string{}
+	for k, v := range run.Labels {
+		if k != common.LabelKeyCronWorkflow {
+			labels[k] = v
+		}
+	}
+	spec := run.Spec.DeepCopy()
+	spec.Suspend = nil
+
+	return &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobUid.String(),
+			Namespace:   run.Namespace,
+			Labels:      labels,
+			Annotations: run.Annotations,
+		},
+		Spec: *spec,
+	}
+}
+
+func (s *SchedulerImpl) Run(ctx context.Context) {
+	selector := fmt.Sprintf("%s,%s", ScheduleLabel, common.LabelKeyCronWorkflow)
+	wfi := s.argoClient.ArgoprojV1alpha1().Workflows("")
+
+	for {
+		// runs started while not watching are adopted from the list
+		list, err := wfi.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			log.Errorf("cannot list scheduled runs: %s", err.Error())
+		} else {
+			for i := range list.Items {
+				s.adopt(ctx, &list.Items[i])
+			}
+
+			w, err := wfi.Watch(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: list.ResourceVersion})
+			if err != nil {
+				log.Errorf("cannot watch scheduled runs: %s", err.Error())
+			} else {
+				s.handleEvents(ctx, w)
+				w.Stop()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ReconnectDelay):
+			log.Debug("Reconnecting scheduled run watch")
+		}
+	}
+}
+
+func (s *SchedulerImpl) handleEvents(ctx context.Context, w watch.Interface) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, open := <-w.ResultChan():
+			if !open {
+				return
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				if wf, ok := event.Object.(*wfv1.Workflow); ok {
+					s.adopt(ctx, wf)
+				}
+			case watch.Error:
+				log.Errorf("scheduled run watch error: %s", apierr.FromObject(event.Object).Error())
+				return
+			}
+		}
+	}
+}
+
+// adopt stores the job of a scheduled run, starts its workflow and removes the run. Runs of deleted schedules are removed.
+// Each step can be repeated, a run is adopted again if any step fails
+func (s *SchedulerImpl) adopt(ctx context.Context, run *wfv1.Workflow) {
+	logger := log.WithFields(log.Fields{"run": run.Name, "workspace": run.Namespace})
+	if run.DeletionTimestamp != nil || run.Labels[common.LabelKeyCronWorkflow] == "" {
+		return
+	}
+
+	scheduleId, err := uuid.Parse(run.Labels[ScheduleLabel])
+	if err != nil {
+		logger.Warnf("scheduled run with invalid schedule label: %s", err.Error())
+		return
+	}
+
+	err = s.startJob(ctx, models.ComponentReference(scheduleId), run)
+	switch err {
+	case nil:
+	case storage.ErrNotFound:
+		logger.Infof("Schedule %s deleted, removing run", scheduleId.String())
+	default:
+		logger.Errorf("cannot start scheduled job: %s", err.Error())
+		return
+	}
+
+	err = s.argoClient.ArgoprojV1alpha1().Workflows(run.Namespace).Delete(ctx, run.Name, metav1.DeleteOptions{})
+	if err != nil && !apierr.IsNotFound(err) {
+		logger.Errorf("cannot remove scheduled run: %s", err.Error())
+	}
+}
+
+func (s *SchedulerImpl) startJob(ctx context.Context, scheduleId models.ComponentReference, run *wfv1.Workflow) error {
+	jobUid := JobUid(run)
+	if _, err := s.scheduleClient.CreateScheduledJob(ctx, scheduleId, jobUid); err != nil {
+		if err == storage.ErrNotFound {
+			return err
+		}
+		return errors.Wrap(err, "cannot store job")
+	}
+
+	_, err := s.argoClient.ArgoprojV1alpha1().Workflows(run.Namespace).Create(ctx, NewJobWorkflow(run, jobUid), metav1.CreateOptions{})
+	if err != nil && !apierr.IsAlreadyExists(err) {
+		return errors.Wrap(err, "cannot create job workflow")
+	}
+
+	s.monitor.Watch(jobUid, run.Namespace)
+	log.WithFields(log.Fields{"job": jobUid.String(), "schedule": scheduleId.String()}).Info("Scheduled job started")
+	return nil
+}
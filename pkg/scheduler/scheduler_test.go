@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// records the jobs created from the stored schedules
+type scheduleStorage struct {
+	storage.ScheduleClient
+
+	mu        sync.Mutex
+	schedules map[models.ComponentReference]models.Schedule
+	jobs      map[models.ComponentReference]models.Job
+}
+
+func (s *scheduleStorage) CreateScheduledJob(ctx context.Context, scheduleId models.ComponentReference, jobId models.ComponentReference) (models.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedule, ok := s.schedules[scheduleId]
+	if !ok {
+		return models.Job{}, storage.ErrNotFound
+	}
+	if job, ok := s.jobs[jobId]; ok {
+		return job, nil
+	}
+	job := schedule.Job
+	job.Uid = jobId
+	job.Origin = &models.JobOrigin{Uid: scheduleId, Type: models.JobOriginSchedule}
+	s.jobs[jobId] = job
+	return job, nil
+}
+
+func (s *scheduleStorage) job(id models.ComponentReference) (models.Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func makeRun(name string, schedule models.ComponentReference) *wfv1.Workflow {
+	suspend := true
+	return &wfv1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test",
+			Labels:    map[string]string{ScheduleLabel: schedule.String(), common.LabelKeyCronWorkflow: schedule.String()},
+		},
+		Spec: wfv1.WorkflowSpec{Entrypoint: "main", Suspend: &suspend},
+	}
+}
+
+func init() {
+	ReconnectDelay = 10 * time.Millisecond
+}
+
+func Test_NewCronWorkflow(t *testing.T) {
+	schedule := models.Schedule{Metadata: models.Metadata{Uid: models.NewComponentReference()}, Workspace: "test", Cron: "0 2 * * *", Timezone: "Europe/Oslo"}
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"flowify.io/origin": schedule.Uid.String()}}, Spec: wfv1.WorkflowSpec{Entrypoint: "main"}}
+
+	cron := NewCronWorkflow(schedule, wf)
+	require.Equal(t, schedule.Uid.String(), cron.Name)
+	require.Equal(t, "test", cron.Namespace)
+	require.Equal(t, "0 2 * * *", cron.Spec.Schedule)
+	require.Equal(t, "Europe/Oslo", cron.Spec.Timezone)
+	require.False(t, cron.Spec.Suspend)
+	require.True(t, *cron.Spec.WorkflowSpec.Suspend, "runs are created suspended")
+	require.Nil(t, wf.Spec.Suspend, "the transpiled workflow is unchanged")
+	require.Equal(t, schedule.Uid.String(), cron.Spec.WorkflowMetadata.Labels[ScheduleLabel])
+	require.Equal(t, wf.Annotations, cron.Spec.WorkflowMetadata.Annotations)
+}
+
+func Test_SchedulerAdoptsRuns(t *testing.T) {
+	scheduleId := models.NewComponentReference()
+	deletedId := models.NewComponentReference()
+	run := makeRun("schedule-1664625600", scheduleId)
+	orphan := makeRun("deleted-1664625600", deletedId)
+	job := makeRun("9b75d74e-681c-496a-bc43-496a798b9f01", scheduleId)
+	delete(job.Labels, common.LabelKeyCronWorkflow)
+
+	argoClient := fake.NewSimpleClientset(run, orphan, job)
+	scheduleClient := &scheduleStorage{
+		schedules: map[models.ComponentReference]models.Schedule{scheduleId: {Job: models.Job{Metadata: models.Metadata{Name: "nightly"}, Type: "job"}}},
+		jobs:      map[models.ComponentReference]models.Job{},
+	}
+	monitor := jobmonitor.NewDefaultJobMonitorMock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go NewScheduler(argoClient, scheduleClient, monitor).Run(ctx)
+
+	jobUid := JobUid(run)
+	wfi := argoClient.ArgoprojV1alpha1().Workflows("test")
+	require.Eventually(t, func() bool {
+		_, err := wfi.Get(ctx, run.Name, metav1.GetOptions{})
+		return err != nil
+	}, time.Second, 10*time.Millisecond, "the run is replaced by the job")
+
+	stored, ok := scheduleClient.job(jobUid)
+	require.True(t, ok)
+	require.Equal(t, "nightly", stored.Name)
+	require.Equal(t, &models.JobOrigin{Uid: scheduleId, Type: models.JobOriginSchedule}, stored.Origin)
+
+	jobWf, err := wfi.Get(ctx, jobUid.String(), metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Nil(t, jobWf.Spec.Suspend)
+	require.Equal(t, "main", jobWf.Spec.Entrypoint)
+	require.Equal(t, scheduleId.String(), jobWf.Labels[ScheduleLabel])
+	require.NotContains(t, jobWf.Labels, common.LabelKeyCronWorkflow)
+	monitor.AssertCalled(t, "Watch", jobUid, "test")
+
+	// runs of deleted schedules are removed without a job
+	require.Eventually(t, func() bool {
+		_, err := wfi.Get(ctx, orphan.Name, metav1.GetOptions{})
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	_, ok = scheduleClient.job(JobUid(orphan))
+	require.False(t, ok)
+
+	// jobs of earlier runs are left alone
+	_, err = wfi.Get(ctx, job.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// new runs are adopted as they are created
+	next := makeRun("schedule-1664712000", scheduleId)
+	_, err = wfi.Create(ctx, next, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, err := wfi.Get(ctx, next.Name, metav1.GetOptions{})
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = wfi.Get(ctx, JobUid(next).String(), metav1.GetOptions{})
+	require.NoError(t, err)
+	monitor.AssertNumberOfCalls(t, "Watch", 2)
+}
@@ -0,0 +1,80 @@
+package workspace
+
+import (
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// The key of the scheduling policy in the workspace config, as json
+const SchedulingPolicyKey = "schedulingPolicy"
+
+// Where and with which resources the pods of the jobs in a workspace run
+type SchedulingPolicy struct {
+	// the requests and limits of containers without their own
+	Defaults core.ResourceRequirements `json:"defaults,omitempty"`
+	// the largest requests and limits allowed, containers without a limit are limited to the maximum
+	Max                core.ResourceList `json:"max,omitempty"`
+	NodeSelector       map[string]string `json:"nodeSelector,omitempty"`
+	Tolerations        []core.Toleration `json:"tolerations,omitempty"`
+	ServiceAccountName string            `json:"serviceAccountName,omitempty"`
+}
+
+// A container requesting more than the workspace allows
+type ResourceLimitError struct {
+	Resource core.ResourceName
+	Quantity resource.Quantity
+	Max      resource.Quantity
+}
+
+func (e *ResourceLimitError) Error() string {
+	return fmt.Sprintf("%s of %s exceeds the workspace maximum of %s", e.Resource, e.Quantity.String(), e.Max.String())
+}
+
+// Resources returns the requirements of a container with the defaults of the policy filled in,
+// or a *ResourceLimitError if they exceed the maximum
+func (p SchedulingPolicy) Resources(requirements core.ResourceRequirements) (core.ResourceRequirements, error) {
+	r := *requirements.DeepCopy()
+	if r.Requests == nil {
+		r.Requests = core.ResourceList{}
+	}
+	if r.Limits == nil {
+		r.Limits = core.ResourceList{}
+	}
+
+	for name, q := range p.Defaults.Requests {
+		if _, ok := r.Requests[name]; !ok {
+			r.Requests[name] = q.DeepCopy()
+		}
+	}
+	for name, q := range p.Defaults.Limits {
+		if _, ok := r.Limits[name]; ok {
+			continue
+		}
+		// a default limit below an explicit request would make the pod invalid
+		if request, ok := r.Requests[name]; ok && request.Cmp(q) > 0 {
+			q = request
+		}
+		r.Limits[name] = q.DeepCopy()
+	}
+
+	for name, max := range p.Max {
+		if _, ok := r.Limits[name]; !ok {
+			r.Limits[name] = max.DeepCopy()
+		}
+		for _, list := range []core.ResourceList{r.Requests, r.Limits} {
+			if q, ok := list[name]; ok && q.Cmp(max) > 0 {
+				return core.ResourceRequirements{}, &ResourceLimitError{Resource: name, Quantity: q, Max: max}
+			}
+		}
+	}
+
+	if len(r.Requests) == 0 {
+		r.Requests = nil
+	}
+	if len(r.Limits) == 0 {
+		r.Limits = nil
+	}
+	return r, nil
+}
@@ -55,6 +55,9 @@ type Workspace struct {
 
 	// the list of required roles for access
 	Roles [][]userpkg.Role `json:"roles,omitempty"`
+
+	// applied to all jobs of the workspace
+	SchedulingPolicy *SchedulingPolicy `json:"schedulingPolicy,omitempty"`
 }
 
 type WorkspaceClient interface {
@@ -163,11 +166,21 @@ func listWorkspaceConfigMaps(namespace string, cmInformer v1.ConfigMapInformer)
 		var hideForUnauthorized bool
 		json.Unmarshal([]byte(cm.Data[IsHiddenKey]), &hideForUnauthorized)
 
+		// skipped rather than running jobs without the intended limits
+		var policy *SchedulingPolicy
+		if raw, ok := cm.Data[SchedulingPolicyKey]; ok {
+			if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+				logrus.Warnf("bad scheduling policy in configmap: %s.%s. skipping", cm.Namespace, cm.Name)
+				continue
+			}
+		}
+
 		ws := Workspace{
 			Name:                cm.Name,
 			Roles:               roles,
 			HideForUnauthorized: hideForUnauthorized,
 			Description:         cm.Data["description"],
+			SchedulingPolicy:    policy,
 		}
 		newlist = append(newlist, ws)
 	}
@@ -382,6 +395,8 @@ func (w *workspaceImpl) Update(k8sclient kubernetes.Interface, data Data) (strin
 
 	cm, err := k8sclient.CoreV1().ConfigMaps(data.Namespace).Get(context.Background(), data.Name, metav1.GetOptions{})
 
+	// the scheduling policy is managed in the configmap directly, not by the update
+	policy, hasPolicy := cm.Data[SchedulingPolicyKey]
 	cm.Name = data.Name
 	cm.Namespace = data.Namespace
 	cm.Data = map[string]string{
@@ -389,6 +404,9 @@ func (w *workspaceImpl) Update(k8sclient kubernetes.Interface, data Data) (strin
 		"projectName":         data.Name,
 		"hideForUnauthorized": data.HideForUnauthorized,
 	}
+	if hasPolicy {
+		cm.Data[SchedulingPolicyKey] = policy
+	}
 
 	_, err = k8sclient.CoreV1().ConfigMaps(data.Namespace).Update(context.Background(), cm, metav1.UpdateOptions{})
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/stretchr/testify/require"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -140,3 +141,113 @@ func Test_WorkspaceNoRoleConfigMap(t *testing.T) {
 		require.Contains(t, []string{"workspace-xyz", "workspace-abc"}, w.Name)
 	}
 }
+
+const ConfigMapPolicy = `{
+	"apiVersion": "v1",
+	"kind": "ConfigMap",
+	"metadata": {
+	  "labels": {
+		"app.kubernetes.io/component": "workspace-config",
+		"app.kubernetes.io/part-of": "flowify"
+	  },
+	  "name": "workspace-gpu",
+	  "namespace": "dummy-namespace"
+	},
+	"data": {
+	  "roles": "[\"token1\"]",
+	  "projectName": "workspace-gpu",
+	  "schedulingPolicy": "{\"defaults\": {\"requests\": {\"cpu\": \"500m\"}}, \"max\": {\"nvidia.com/gpu\": \"1\"}, \"nodeSelector\": {\"pool\": \"gpu\"}, \"tolerations\": [{\"key\": \"gpu\", \"operator\": \"Exists\", \"effect\": \"NoSchedule\"}], \"serviceAccountName\": \"gpu-runner\"}"
+	}
+  }
+  `
+
+func Test_WorkspaceSchedulingPolicy(t *testing.T) {
+	var cm1, cmPolicy, cmBad core.ConfigMap
+
+	json.Unmarshal([]byte(ConfigMap1), &cm1)
+	json.Unmarshal([]byte(ConfigMapPolicy), &cmPolicy)
+	json.Unmarshal([]byte(ConfigMapPolicy), &cmBad)
+	cmBad.Name = "workspace-bad"
+	cmBad.Data["schedulingPolicy"] = "{\"max\": {\"cpu\": \"lots\"}}"
+
+	client := workspace.NewWorkspaceClient(fake.NewSimpleClientset(&cm1, &cmPolicy, &cmBad), namespace)
+	ws := client.ListWorkspaces()
+
+	// the workspace with a broken policy is skipped
+	require.Len(t, ws, 2)
+	for _, w := range ws {
+		switch w.Name {
+		case "workspace-abc":
+			require.Nil(t, w.SchedulingPolicy)
+		case "workspace-gpu":
+			require.NotNil(t, w.SchedulingPolicy)
+			require.Equal(t, "500m", w.SchedulingPolicy.Defaults.Requests.Cpu().String())
+			require.Equal(t, map[string]string{"pool": "gpu"}, w.SchedulingPolicy.NodeSelector)
+			require.Equal(t, "gpu", w.SchedulingPolicy.Tolerations[0].Key)
+			require.Equal(t, "gpu-runner", w.SchedulingPolicy.ServiceAccountName)
+		default:
+			t.Errorf("unexpected workspace %s", w.Name)
+		}
+	}
+}
+
+func Test_SchedulingPolicyResources(t *testing.T) {
+	policy := workspace.SchedulingPolicy{
+		Defaults: core.ResourceRequirements{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("500m"), core.ResourceMemory: resource.MustParse("1Gi")},
+			Limits:   core.ResourceList{core.ResourceMemory: resource.MustParse("2Gi")},
+		},
+		Max: core.ResourceList{core.ResourceCPU: resource.MustParse("4"), core.ResourceMemory: resource.MustParse("8Gi")},
+	}
+	list := func(cpu string, memory string) core.ResourceList {
+		l := core.ResourceList{}
+		if cpu != "" {
+			l[core.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if memory != "" {
+			l[core.ResourceMemory] = resource.MustParse(memory)
+		}
+		return l
+	}
+
+	testCases := []struct {
+		Name          string
+		Requirements  core.ResourceRequirements
+		Expected      core.ResourceRequirements
+		ExpectedError bool
+	}{
+		{"defaults", core.ResourceRequirements{},
+			core.ResourceRequirements{Requests: list("500m", "1Gi"), Limits: list("4", "2Gi")}, false},
+		{"explicit", core.ResourceRequirements{Requests: list("1", "3Gi"), Limits: list("2", "")},
+			core.ResourceRequirements{Requests: list("1", "3Gi"), Limits: list("2", "3Gi")}, false},
+		{"request above max", core.ResourceRequirements{Requests: list("8", "")}, core.ResourceRequirements{}, true},
+		{"limit above max", core.ResourceRequirements{Limits: list("", "16Gi")}, core.ResourceRequirements{}, true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			r, err := policy.Resources(test.Requirements)
+			if test.ExpectedError {
+				var limitErr *workspace.ResourceLimitError
+				require.ErrorAs(t, err, &limitErr)
+				return
+			}
+			require.NoError(t, err)
+			for name, q := range test.Expected.Requests {
+				actual := r.Requests[name]
+				require.Zero(t, q.Cmp(actual), "request %s: %s", name, actual.String())
+			}
+			for name, q := range test.Expected.Limits {
+				actual := r.Limits[name]
+				require.Zero(t, q.Cmp(actual), "limit %s: %s", name, actual.String())
+			}
+			require.Len(t, r.Requests, len(test.Expected.Requests))
+			require.Len(t, r.Limits, len(test.Expected.Limits))
+		})
+	}
+
+	// without a policy the requirements are unchanged
+	r, err := workspace.SchedulingPolicy{}.Resources(core.ResourceRequirements{})
+	require.NoError(t, err)
+	require.Equal(t, core.ResourceRequirements{}, r)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
@@ -87,7 +88,7 @@ func ComponentVersionListHandler(client storage.ComponentClient) http.HandlerFun
 
 func ComponentVersionLikeListHandler(w http.ResponseWriter, r *http.Request, client storage.ComponentClient, kind string) {
 	tag := fmt.Sprintf("get%sVersions", strings.Title(kind))
-	pagination, err := parsePaginationsOrDefault(r.URL.Query()["limit"], r.URL.Query()["offset"])
+	pagination, err := parseCursorPaginationOrDefault(r.URL.Query())
 	if err != nil {
 		WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", err.Error()}, tag)
 		return
@@ -110,6 +111,10 @@ func ComponentVersionLikeListHandler(w http.ResponseWriter, r *http.Request, cli
 	}
 
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid pagination cursor", err.Error()}, tag)
+			return
+		}
 		WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error retrieving component versions", err.Error()}, tag)
 		return
 	}
@@ -214,8 +219,23 @@ func parsePaginationsOrDefault(limits []string, offsets []string) (storage.Pagin
 	return parsePaginationOrDefault(limit, offset)
 }
 
+// the pagination of the metadata listings, by offset or continuing after the cursor of a previous page
+func parseCursorPaginationOrDefault(query url.Values) (storage.Pagination, error) {
+	pagination, err := parsePaginationsOrDefault(query["limit"], query["offset"])
+	if err != nil {
+		return storage.Pagination{}, err
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if pagination.Skip != 0 {
+			return storage.Pagination{}, fmt.Errorf("the 'cursor' and 'offset' query parameters cannot be combined")
+		}
+		pagination.Cursor = cursor
+	}
+	return pagination, nil
+}
+
 func ComponentLikeListHandler(w http.ResponseWriter, r *http.Request, client storage.ComponentClient, kind string) {
-	pagination, err := parsePaginationsOrDefault(r.URL.Query()["limit"], r.URL.Query()["offset"])
+	pagination, err := parseCursorPaginationOrDefault(r.URL.Query())
 	if err != nil {
 		WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", err.Error()}, kind+"ListHandler")
 		return
@@ -232,6 +252,15 @@ func ComponentLikeListHandler(w http.ResponseWriter, r *http.Request, client sto
 	}
 
 	if err != nil {
+		var filterErr *storage.FilterError
+		if errors.As(err, &filterErr) {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid filter query", filterErr.Error()}, kind)
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid pagination cursor", err.Error()}, kind)
+			return
+		}
 		WriteErrorResponse(w, APIError{http.StatusInternalServerError, fmt.Sprintf("error listing %ss", kind), err.Error()}, kind)
 		return
 	}
@@ -251,6 +280,10 @@ func ComponentCreateHandler(w http.ResponseWriter, r *http.Request, client stora
 		WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "createComponent")
 		return
 	}
+	if err := request.Component.ValidateExpressions(); err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "createComponent")
+		return
+	}
 
 	component, err := InitializeComponent(r.Context(), request.Component)
 	if err != nil {
@@ -279,6 +312,10 @@ func ComponentPutHandler(componentClient storage.ComponentClient) http.HandlerFu
 			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "putComponent")
 			return
 		}
+		if err := request.Component.ValidateExpressions(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "putComponent")
+			return
+		}
 
 		id, err := uuid.Parse(mux.Vars(r)["id"])
 		if err != nil {
@@ -294,6 +331,10 @@ func ComponentPutHandler(componentClient storage.ComponentClient) http.HandlerFu
 
 		err = PutComponent(r.Context(), componentClient, request.Component)
 		if err != nil {
+			if errors.Is(err, storage.ErrNewerDocumentExists) {
+				WriteErrorResponse(w, APIError{http.StatusConflict, "a concurrent put stored the same version", ""}, "putComponent")
+				return
+			}
 			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not handle put request", ""}, "putComponent")
 			return
 		}
@@ -310,6 +351,10 @@ func ComponentPatchHandler(componentClient storage.ComponentClient) http.Handler
 			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "patchComponent")
 			return
 		}
+		if err := request.Component.ValidateExpressions(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "patchComponent")
+			return
+		}
 
 		id, err := getIdFromMuxerPath(r)
 		if err != nil {
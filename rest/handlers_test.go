@@ -10,14 +10,19 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
+	argoutil "github.com/argoproj/argo-workflows/v3/workflow/util"
 	"github.com/equinor/flowify-workflows-server/auth"
 	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/artifact"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
 	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/equinor/flowify-workflows-server/storage"
 	"github.com/equinor/flowify-workflows-server/user"
@@ -26,12 +31,17 @@ import (
 	gmux "github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 	ktesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
 )
 
 func init() {
@@ -203,6 +213,11 @@ func (c *componentClient) AddJobEvents(ctx context.Context, id models.ComponentR
 	return args.Error(1)
 }
 
+func (c *componentClient) ListJobEvents(ctx context.Context, id models.ComponentReference) ([]models.JobEvent, error) {
+	args := c.Called(ctx, id)
+	return args.Get(0).([]models.JobEvent), args.Error(1)
+}
+
 type testCase struct {
 	Name                       string
 	Method                     string
@@ -255,6 +270,8 @@ func Test_ComponentHTTPHandler(t *testing.T) {
 	wfWithUidBytes, _ := json.Marshal(wfWithUid)
 
 	client.On("ListComponentsMetadata", mock.Anything, []string(nil), []string(nil)).Return(models.MetadataList{Items: []models.Metadata{c1.Metadata, c1.Metadata}, PageInfo: models.PageInfo{TotalNumber: 2, Skip: 0, Limit: 0}}, nil)
+	client.On("ListComponentsMetadata", mock.Anything, []string{"name[like]=x"}, []string(nil)).Return(nil, errors.Wrap(&storage.FilterError{Query: "name[like]=x", Pos: 5, Token: "like", Reason: "no such filter operator"}, "could not list metadata"))
+	client.On("ListWorkflowsMetadata", mock.Anything, []string(nil), []string{"-name"}).Return(nil, errors.Wrap(storage.ErrInvalidCursor, "the cursor was issued for another sort"))
 	client.On("ListComponentVersionsMetadata", mock.Anything, []string(nil)).Return(models.MetadataList{Items: []models.Metadata{c2v1.Metadata, c2v2.Metadata}, PageInfo: models.PageInfo{TotalNumber: 2, Skip: 0, Limit: 0}}, nil)
 	client.On("ListWorkflowsMetadata", mock.Anything, []string(nil), []string(nil)).Return(models.MetadataWorkspaceList{Items: []models.MetadataWorkspace{{Metadata: c1.Metadata, Workspace: "test"}}}, nil)
 	client.On("ListWorkflowVersionsMetadata", mock.Anything, []string(nil)).Return(
@@ -285,11 +302,14 @@ func Test_ComponentHTTPHandler(t *testing.T) {
 
 	testcases := []testCase{
 		{Name: "list components", Method: http.MethodGet, URL: "/api/v1/components/", Body: nil, ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
+		{Name: "list components bad filter", Method: http.MethodGet, URL: "/api/v1/components/?filter=name[like]=x", Body: nil, ExpectedResponseStatusCode: http.StatusBadRequest, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
 		{Name: "post component", Method: http.MethodPost, URL: "/api/v1/components/", Body: cmpReq, ExpectedResponseStatusCode: http.StatusCreated, Headers: map[string]string{"Content-Type": "application/json"}, ExpectedResponseHeaders: map[string]string{"Location": "/api/v1/components/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
 		{Name: "get component", Method: http.MethodGet, URL: "/api/v1/components/" + c1.Uid.String(), Body: cmpReq, ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: map[string]string{}}, //"Location": "/api/v1/components/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
 		{Name: "put component", Method: http.MethodPut, URL: "/api/v1/components/" + compWithUid.Uid.String(), Body: []byte(fmt.Sprintf(`{ "component": %s , "options": {}}`, stringify(compWithUid))), ExpectedResponseStatusCode: http.StatusNoContent, Headers: map[string]string{"Content-Type": "application/json"}, ExpectedResponseHeaders: map[string]string{"Location": "/api/v1/components/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
 		{Name: "list component versions", Method: http.MethodGet, URL: "/api/v1/components/" + c2Uid.String() + "/versions/", Body: nil, ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
 		{Name: "list workflows", Method: http.MethodGet, URL: "/api/v1/workflows/", Body: nil, ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
+		{Name: "list workflows cursor of another sort", Method: http.MethodGet, URL: "/api/v1/workflows/?cursor=abc&sort=-name", Body: nil, ExpectedResponseStatusCode: http.StatusBadRequest, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
+		{Name: "list workflows cursor and offset", Method: http.MethodGet, URL: "/api/v1/workflows/?cursor=abc&offset=10", Body: nil, ExpectedResponseStatusCode: http.StatusBadRequest, Headers: nil, ExpectedResponseHeaders: map[string]string{}},
 		{Name: "post workflow", Method: http.MethodPost, URL: "/api/v1/workflows/", Body: wfReq, ExpectedResponseStatusCode: http.StatusCreated, Headers: map[string]string{"Content-Type": "application/json"}, ExpectedResponseHeaders: map[string]string{"Location": "/api/v1/workflows/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
 		{Name: "get workflow", Method: http.MethodGet, URL: "/api/v1/workflows/" + wfWithUid.Uid.String(), Body: wfWithUidBytes, ExpectedResponseStatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "application/json"}, ExpectedResponseHeaders: map[string]string{}},
 		{Name: "put workflow", Method: http.MethodPut, URL: "/api/v1/workflows/" + wfWithUid.Uid.String(), Body: stringify(models.WorkflowPostRequest{Workflow: wfWithUid}), ExpectedResponseStatusCode: http.StatusNoContent, Headers: map[string]string{"Content-Type": "application/json"}, ExpectedResponseHeaders: map[string]string{}},
@@ -415,7 +435,7 @@ func Test_JobSubmitHTTPHandler(t *testing.T) {
 	argoClientSet := fake.NewSimpleClientset()
 	argoClientSet.PrependReactor("create", "workflows", UIDReactor)
 	mux := gmux.NewRouter()
-	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet)
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
 
 	testcases := []testCase{
 		{Name: "submit jobs", Method: http.MethodPost, URL: "/api/v1/jobs/", Body: []byte(jobSubmitRequest), ExpectedResponseStatusCode: http.StatusCreated, Headers: nil, ExpectedResponseHeaders: map[string]string{"Location": "/api/v1/jobs/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
@@ -454,6 +474,643 @@ func Test_JobSubmitHTTPHandler(t *testing.T) {
 	}
 }
 
+// the job submit request with an exit handler taking a single parameter input
+func withExitHandler(t *testing.T, input string) []byte {
+	var request map[string]any
+	require.NoError(t, json.Unmarshal([]byte(jobSubmitRequest), &request))
+	request["job"].(map[string]any)["workflow"].(map[string]any)["onExit"] = map[string]any{
+		"id": "notify",
+		"node": map[string]any{
+			"uid":    "00000000-0000-0000-0000-000000000002",
+			"type":   "component",
+			"inputs": []any{map[string]any{"name": input, "type": "parameter"}},
+			"implementation": map[string]any{
+				"type":      "brick",
+				"container": map[string]any{"name": "notify", "image": "curlimages/curl"},
+			},
+		},
+	}
+	return stringify(request)
+}
+
+// the job submit request running its brick if the expression on the input 'count' holds
+func withConditional(t *testing.T, expression map[string]any) []byte {
+	var request map[string]any
+	require.NoError(t, json.Unmarshal([]byte(jobSubmitRequest), &request))
+	job := request["job"].(map[string]any)
+	job["inputValues"] = []any{map[string]any{"target": "count", "value": "7"}}
+	component := job["workflow"].(map[string]any)["component"].(map[string]any)
+	brick := map[string]any{
+		"uid":            "00000000-0000-0000-0000-000000000003",
+		"type":           "component",
+		"implementation": component["implementation"],
+	}
+	component["inputs"] = []any{map[string]any{"name": "count", "type": "parameter"}}
+	component["implementation"] = map[string]any{"type": "conditional", "nodeTrue": brick, "expression": expression}
+	return stringify(request)
+}
+
+func Test_JobRenderHTTPHandler(t *testing.T) {
+	// no job is stored on dry-run, so CreateJob is not mocked
+	client := NewMockClient()
+
+	argoClientSet := fake.NewSimpleClientset()
+	argoClientSet.PrependReactor("create", "workflows", UIDReactor)
+	mux := gmux.NewRouter()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := user.UserContext(user.MockUser{Uid: "0", Roles: []user.Role{"tester"}}, r.Context())
+			ctx = context.WithValue(ctx, workspace.WorkspaceKey, []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	otherWorkspace := []byte(strings.Replace(jobSubmitRequest, `"workspace": "test"`, `"workspace": "other"`, 1))
+	testcases := []struct {
+		testCase
+		ExpectedContentType string
+	}{
+		{testCase{Name: "render job in other workspace", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: otherWorkspace, ExpectedResponseStatusCode: http.StatusForbidden}, "application/json"},
+		{testCase{Name: "submit job dry-run in other workspace", Method: http.MethodPost, URL: "/api/v1/jobs/?dryRun=true", Body: otherWorkspace, ExpectedResponseStatusCode: http.StatusForbidden}, "application/json"},
+		{testCase{Name: "render job", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: []byte(jobSubmitRequest), ExpectedResponseStatusCode: http.StatusOK}, "application/json"},
+		{testCase{Name: "render job yaml", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: []byte(jobSubmitRequest), ExpectedResponseStatusCode: http.StatusOK, Headers: map[string]string{"Accept": "application/yaml"}}, "application/yaml"},
+		{testCase{Name: "submit job dry-run", Method: http.MethodPost, URL: "/api/v1/jobs/?dryRun=true", Body: []byte(jobSubmitRequest), ExpectedResponseStatusCode: http.StatusOK}, "application/json"},
+		{testCase{Name: "render bad job", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: []byte(`{"job": {}}`), ExpectedResponseStatusCode: http.StatusBadRequest}, "application/json"},
+		{testCase{Name: "render job with exit handler", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: withExitHandler(t, "status"), ExpectedResponseStatusCode: http.StatusOK}, "application/json"},
+		{testCase{Name: "render job with unsatisfied exit handler", Method: http.MethodPost, URL: "/api/v1/jobs/render", Body: withExitHandler(t, "recipient"), ExpectedResponseStatusCode: http.StatusBadRequest}, "application/json"},
+		{testCase{Name: "render conditional job", Method: http.MethodPost, URL: "/api/v1/jobs/render", ExpectedResponseStatusCode: http.StatusOK,
+			Body: withConditional(t, map[string]any{"or": []any{
+				map[string]any{"left": map[string]any{"name": "count", "type": "parameter"}, "operator": ">=", "right": 5},
+				map[string]any{"not": map[string]any{"left": map[string]any{"name": "count", "type": "parameter"}, "operator": "matches", "right": "^[0-9]+$"}},
+			}})}, "application/json"},
+		{testCase{Name: "render conditional job on unknown input", Method: http.MethodPost, URL: "/api/v1/jobs/render", ExpectedResponseStatusCode: http.StatusBadRequest,
+			Body: withConditional(t, map[string]any{"left": map[string]any{"name": "size", "type": "parameter"}, "operator": "==", "right": "5"})}, "application/json"},
+		{testCase{Name: "render conditional job on a status outside a graph", Method: http.MethodPost, URL: "/api/v1/jobs/render", ExpectedResponseStatusCode: http.StatusBadRequest,
+			Body: withConditional(t, map[string]any{"left": map[string]any{"statusOf": "loader"}, "operator": "==", "right": "Failed"})}, "application/json"},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(test.Method, test.URL, bytes.NewReader(test.Body))
+			req.Header["Content-Type"] = []string{"application/json"}
+			for k, v := range test.Headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			payload, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, string(payload))
+			require.Equal(t, test.ExpectedContentType, res.Header.Get("Content-Type"))
+
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var wf v1alpha1.Workflow
+			switch test.ExpectedContentType {
+			case "application/yaml":
+				require.NoError(t, yaml.Unmarshal(payload, &wf))
+			default:
+				require.NoError(t, json.Unmarshal(payload, &wf))
+			}
+			require.NotEmpty(t, wf.Spec.Templates)
+			require.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$", wf.GetName())
+		})
+	}
+
+	t.Run("render job with a reference cycle", func(t *testing.T) {
+		// the graph holds itself
+		cyclicUid := models.NewReference("00000000-0000-0000-0000-000000000004")
+		cyclic := models.Component{
+			ComponentBase: models.ComponentBase{Type: "component", Metadata: models.Metadata{Uid: cyclicUid, Version: models.Version{Current: models.VersionInit}}},
+			Implementation: models.Graph{
+				ImplementationBase: models.ImplementationBase{Type: "graph"},
+				Nodes:              []models.Node{{Id: "inner", Node: cyclicUid}},
+			},
+		}
+		// the latest version, then pinned by the lockfile
+		client.On("GetComponent", mock.Anything, models.CRefVersion{Uid: cyclicUid}).Return(cyclic, nil)
+		client.On("GetComponent", mock.Anything, models.CRefVersion{Uid: cyclicUid, Version: models.VersionInit}).Return(cyclic, nil)
+
+		var request map[string]any
+		require.NoError(t, json.Unmarshal([]byte(jobSubmitRequest), &request))
+		request["job"].(map[string]any)["workflow"].(map[string]any)["component"] = map[string]any{
+			"uid":  "00000000-0000-0000-0000-000000000005",
+			"type": "component",
+			"implementation": map[string]any{
+				"type":  "graph",
+				"nodes": []any{map[string]any{"id": "outer", "node": cyclicUid.String()}},
+			},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/render", bytes.NewReader(stringify(request)))
+		req.Header["Content-Type"] = []string{"application/json"}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+		var apiErr struct {
+			APIError
+			Cause storage.DereferenceError `json:"cause"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.Equal(t, "cannot dereference job component", apiErr.Summary)
+		assert.Equal(t, storage.DereferenceErrorCycle, apiErr.Cause.Kind)
+		assert.Equal(t, []storage.ReferencePathElement{
+			{NodeId: "outer", Uid: cyclicUid, Version: models.VersionInit},
+			{NodeId: "inner", Uid: cyclicUid, Version: models.VersionInit},
+		}, apiErr.Cause.Path)
+	})
+	client.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func Test_JobSchedulingPolicy(t *testing.T) {
+	client := NewMockClient()
+	argoClientSet := fake.NewSimpleClientset()
+	argoClientSet.PrependReactor("create", "workflows", UIDReactor)
+
+	policy := workspace.SchedulingPolicy{
+		Defaults:     corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}},
+		Max:          corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		NodeSelector: map[string]string{"pool": "spot"},
+		Tolerations:  []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+	}
+	limited := policy
+	limited.Max = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+
+	testCases := []struct {
+		Name                       string
+		Policy                     workspace.SchedulingPolicy
+		ExpectedResponseStatusCode int
+	}{
+		{"within limits", policy, http.StatusOK},
+		{"exceeds limits", limited, http.StatusBadRequest},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			mux := gmux.NewRouter()
+			mux.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					wss := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}, SchedulingPolicy: &test.Policy}}
+					ctx := user.UserContext(user.MockUser{Uid: "0", Roles: []user.Role{"tester"}}, r.Context())
+					next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, workspace.WorkspaceKey, wss)))
+				})
+			})
+			RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/render", bytes.NewReader([]byte(jobSubmitRequest)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, w.Body.String())
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var wf v1alpha1.Workflow
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &wf))
+			for _, template := range wf.Spec.Templates {
+				if template.Container == nil {
+					continue
+				}
+				require.Equal(t, "250m", template.Container.Resources.Requests.Cpu().String())
+				require.Equal(t, "1", template.Container.Resources.Limits.Cpu().String())
+				require.Equal(t, map[string]string{"pool": "spot"}, template.NodeSelector)
+				require.Equal(t, policy.Tolerations, template.Tolerations)
+			}
+		})
+	}
+}
+
+func Test_JobResubmitHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+
+	brickUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fc9")
+	brick := models.Component{
+		ComponentBase: models.ComponentBase{Type: "component", Metadata: models.Metadata{Uid: brickUid, Version: models.Version{Current: models.VersionNumber(2)}}},
+		Implementation: models.Brick{ImplementationBase: models.ImplementationBase{Type: models.BrickType},
+			Container: &corev1.Container{Name: "containername", Image: "docker/whalesay", Command: []string{"cowsay"}}},
+	}
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fca")
+	lockfile := []models.CRefVersion{{Uid: brickUid, Version: models.VersionNumber(2)}}
+	job := models.Job{Metadata: models.Metadata{Uid: jobUid, Name: "test-job"}, Type: "job",
+		Workflow: models.Workflow{Type: "workflow", Workspace: "test",
+			Component: models.Component{ComponentBase: models.ComponentBase{Type: "component", Metadata: models.Metadata{Uid: models.NewComponentReference()}},
+				Implementation: models.Graph{ImplementationBase: models.ImplementationBase{Type: models.GraphType},
+					Nodes: []models.Node{{Id: "N1", Node: brickUid}}}}},
+		Lockfile: lockfile,
+	}
+
+	// the reference is pinned to the locked version
+	client.On("GetJob", mock.Anything, jobUid).Return(job, nil)
+	client.On("GetJob", mock.Anything, mock.Anything).Return(models.Job{}, storage.ErrNotFound)
+	client.On("GetComponent", mock.Anything, models.CRefVersion{Uid: brickUid, Version: models.VersionNumber(2)}).Return(brick, nil)
+	client.On("CreateJob", mock.Anything, mock.MatchedBy(func(j models.Job) bool {
+		return j.Uid != jobUid && assert.ObjectsAreEqual(lockfile, j.Lockfile) && assert.ObjectsAreEqual(job.Workflow, j.Workflow)
+	})).Return(nil)
+
+	argoClientSet := fake.NewSimpleClientset()
+	argoClientSet.PrependReactor("create", "workflows", UIDReactor)
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	testcases := []testCase{
+		{Name: "resubmit job", Method: http.MethodPost, URL: "/api/v1/jobs/" + jobUid.String() + "/resubmit", ExpectedResponseStatusCode: http.StatusCreated, ExpectedResponseHeaders: map[string]string{"Location": "/api/v1/jobs/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"}},
+		{Name: "resubmit missing job", Method: http.MethodPost, URL: "/api/v1/jobs/" + models.NewComponentReference().String() + "/resubmit", ExpectedResponseStatusCode: http.StatusNotFound},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(test.Method, test.URL, bytes.NewReader(test.Body))
+			req.Header["Content-Type"] = []string{"application/json"}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+
+			payload, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, string(payload))
+
+			for k, v := range test.ExpectedResponseHeaders {
+				require.Regexp(t, regexp.MustCompile(v), res.Header[k][0])
+			}
+		})
+	}
+	client.AssertCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func makeFailedWorkflow(name string, workspace string) *v1alpha1.Workflow {
+	wf := &v1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: workspace}}
+	wf.Status.Phase = v1alpha1.WorkflowFailed
+	nodeA := v1alpha1.NodeStatus{Name: name + ".A", DisplayName: "A", Type: v1alpha1.NodeTypePod, Phase: v1alpha1.NodeSucceeded, BoundaryID: name}
+	nodeA.ID = wf.NodeID(nodeA.Name)
+	nodeB := v1alpha1.NodeStatus{Name: name + ".B", DisplayName: "B", Type: v1alpha1.NodeTypePod, Phase: v1alpha1.NodeFailed, BoundaryID: name}
+	nodeB.ID = wf.NodeID(nodeB.Name)
+	nodeA.Children = []string{nodeB.ID}
+	root := v1alpha1.NodeStatus{ID: name, Name: name, Type: v1alpha1.NodeTypeDAG, Phase: v1alpha1.NodeFailed, Children: []string{nodeA.ID}}
+	wf.Status.Nodes = v1alpha1.Nodes{root.ID: root, nodeA.ID: nodeA, nodeB.ID: nodeB}
+	return wf
+}
+
+func Test_RetryWorkflow(t *testing.T) {
+	const name = "9b75d74e-681c-496a-bc43-496a798b9fcb"
+	const retryName = "9b75d74e-681c-496a-bc43-496a798b9fcc"
+	wf := makeFailedWorkflow(name, "test")
+
+	retry, err := RetryWorkflow(wf, retryName)
+	require.NoError(t, err)
+	require.Equal(t, retryName, retry.Name)
+	require.Equal(t, name, retry.Labels["workflows.argoproj.io/resubmitted-from-workflow"])
+	require.Len(t, retry.Status.Nodes, 3)
+
+	root, ok := retry.Status.Nodes[retryName]
+	require.True(t, ok)
+	nodeA, ok := retry.Status.Nodes[retry.NodeID(retryName+".A")]
+	require.True(t, ok)
+	nodeB, ok := retry.Status.Nodes[retry.NodeID(retryName+".B")]
+	require.True(t, ok)
+
+	// successful nodes are reused, failed nodes are run again
+	require.Equal(t, []string{nodeA.ID}, root.Children)
+	require.Equal(t, []string{nodeB.ID}, nodeA.Children)
+	require.Equal(t, retryName, nodeA.BoundaryID)
+	require.Equal(t, v1alpha1.NodeSkipped, nodeA.Phase)
+	require.Equal(t, v1alpha1.NodePending, nodeB.Phase)
+
+	// only failed workflows can be retried
+	wf.Status.Phase = v1alpha1.WorkflowSucceeded
+	_, err = RetryWorkflow(wf, retryName)
+	require.Error(t, err)
+}
+
+func Test_JobRetryHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fcd")
+	job := models.Job{Metadata: models.Metadata{Uid: jobUid, Name: "test-job"}, Type: "job",
+		Workflow: models.Workflow{Type: "workflow", Workspace: "test"}}
+	client.On("GetJob", mock.Anything, jobUid).Return(job, nil)
+	client.On("CreateJob", mock.Anything, mock.MatchedBy(func(j models.Job) bool {
+		return j.Uid != jobUid && j.Origin != nil && *j.Origin == models.JobOrigin{Uid: jobUid, Type: models.JobOriginRetry}
+	})).Return(nil)
+
+	argoClientSet := fake.NewSimpleClientset(makeFailedWorkflow(jobUid.String(), "test"))
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+jobUid.String()+"/retry", nil)
+	req.Header["Content-Type"] = []string{"application/json"}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	location := w.Result().Header.Get("Location")
+	retryUid := path.Base(location)
+	retry, err := argoClientSet.ArgoprojV1alpha1().Workflows("test").Get(context.TODO(), retryUid, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, jobUid.String(), retry.Annotations["flowify.io/origin"])
+	client.AssertCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func Test_JobSuspendResumeHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fce")
+	running := &v1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: jobUid.String(), Namespace: "test"}}
+	running.Status.Phase = v1alpha1.WorkflowRunning
+	finished := &v1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "9b75d74e-681c-496a-bc43-496a798b9fcf", Namespace: "test"}}
+	finished.Status.Phase = v1alpha1.WorkflowSucceeded
+	finished.Status.FinishedAt = metav1.NewTime(time.Now())
+
+	argoClientSet := fake.NewSimpleClientset(running)
+	mux := gmux.NewRouter()
+	var caller user.User = user.MockUser{Uid: "0", Roles: []user.Role{"tester"}}
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := user.UserContext(caller, r.Context())
+			ctx = context.WithValue(ctx, workspace.WorkspaceKey, []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	status := func(t *testing.T) models.JobStatus {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+jobUid.String()+"/status", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var jobStatus models.JobStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobStatus))
+		return jobStatus
+	}
+
+	testcases := []struct {
+		testCase
+		ExpectedSuspended bool
+	}{
+		{testCase{Name: "suspend job", Method: http.MethodPost, URL: "/api/v1/jobs/" + jobUid.String() + "/suspend", ExpectedResponseStatusCode: http.StatusAccepted}, true},
+		{testCase{Name: "resume job", Method: http.MethodPost, URL: "/api/v1/jobs/" + jobUid.String() + "/resume", ExpectedResponseStatusCode: http.StatusAccepted}, false},
+		{testCase{Name: "resume job not suspended", Method: http.MethodPost, URL: "/api/v1/jobs/" + jobUid.String() + "/resume", ExpectedResponseStatusCode: http.StatusConflict}, false},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(test.Method, test.URL, nil)
+			req.Header["Content-Type"] = []string{"application/json"}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, w.Body.String())
+
+			jobStatus := status(t)
+			require.Equal(t, test.ExpectedSuspended, jobStatus.Suspended)
+			require.Equal(t, v1alpha1.WorkflowRunning, jobStatus.Status)
+		})
+	}
+
+	for _, op := range []string{"suspend", "resume"} {
+		t.Run(op+" job without workspace access", func(t *testing.T) {
+			caller = user.MockUser{Uid: "1", Roles: []user.Role{"other"}}
+			defer func() { caller = user.MockUser{Uid: "0", Roles: []user.Role{"tester"}} }()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+jobUid.String()+"/"+op, nil)
+			req.Header["Content-Type"] = []string{"application/json"}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+			wf, err := argoClientSet.ArgoprojV1alpha1().Workflows("test").Get(context.TODO(), jobUid.String(), metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Nil(t, wf.Spec.Suspend)
+		})
+	}
+
+	t.Run("suspend finished job", func(t *testing.T) {
+		err := SuspendJob(context.TODO(), fake.NewSimpleClientset(finished), models.NewReference(finished.Name), "test")
+		require.ErrorIs(t, err, ErrJobStateConflict)
+	})
+}
+
+func Test_JobNodesHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fd0")
+	argoClientSet := fake.NewSimpleClientset(makeFailedWorkflow(jobUid.String(), "test"))
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	wss := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"test-dev"}}}}
+	request := func(u user.User) *httptest.ResponseRecorder {
+		ctx := context.WithValue(context.Background(), user.UserKey, u)
+		ctx = context.WithValue(ctx, workspace.WorkspaceKey, wss)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+jobUid.String()+"/nodes", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"test-dev"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var nodes []models.JobNodeStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &nodes))
+	require.Len(t, nodes, 3)
+	assert.Equal(t, "", nodes[0].Path)
+	assert.Equal(t, v1alpha1.NodeFailed, nodes[0].Phase)
+	assert.Equal(t, "A", nodes[1].NodeId)
+	assert.Equal(t, v1alpha1.NodeSucceeded, nodes[1].Phase)
+	assert.Equal(t, "B", nodes[2].NodeId)
+	assert.Equal(t, v1alpha1.NodeFailed, nodes[2].Phase)
+
+	t.Run("no access", func(t *testing.T) {
+		w := request(user.MockUser{Uid: "1", Email: "other@author.com", Roles: []user.Role{"other"}})
+		require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), "phase")
+	})
+}
+
+func Test_JobTimelineHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fd2")
+	noAccessUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fd3")
+	events := []models.JobEvent{
+		{JobUid: jobUid, Phase: "Running", Timestamp: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{JobUid: jobUid, NodeId: "a", Name: "A", Phase: "Succeeded", Timestamp: time.Date(2022, 10, 1, 12, 1, 0, 0, time.UTC)},
+	}
+	client.On("GetJob", mock.Anything, jobUid).Return(models.Job{}, nil)
+	client.On("GetJob", mock.Anything, noAccessUid).Return(models.Job{}, errors.Wrap(storage.ErrNoAccess, "user has no access to workspace (test)"))
+	client.On("GetJob", mock.Anything, mock.Anything).Return(models.Job{}, storage.ErrNotFound)
+	client.On("ListJobEvents", mock.Anything, jobUid).Return(events, nil)
+
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, fake.NewSimpleClientset(), k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	testcases := []struct {
+		Name                       string
+		Uid                        string
+		ExpectedResponseStatusCode int
+	}{
+		{"timeline", jobUid.String(), http.StatusOK},
+		{"no access", noAccessUid.String(), http.StatusForbidden},
+		{"missing job", models.NewComponentReference().String(), http.StatusNotFound},
+		{"bad id", "not-a-uid", http.StatusBadRequest},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+test.Uid+"/timeline", nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, w.Body.String())
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var timeline models.JobTimeline
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &timeline))
+			assert.Equal(t, models.JobTimeline{Uid: jobUid, Events: events}, timeline)
+		})
+	}
+}
+
+func Test_JobOutputsHTTPHandler(t *testing.T) {
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fd4")
+	wf := makeFailedWorkflow(jobUid.String(), "test")
+	root := wf.Status.Nodes[wf.Name]
+	root.Outputs = &v1alpha1.Outputs{
+		Parameters: []v1alpha1.Parameter{{Name: "result", Value: v1alpha1.AnyStringPtr("42")}},
+		Artifacts: []v1alpha1.Artifact{{Name: "report", ArtifactLocation: v1alpha1.ArtifactLocation{
+			S3: &v1alpha1.S3Artifact{S3Bucket: v1alpha1.S3Bucket{Endpoint: "minio:9000", Bucket: "artifacts"}, Key: jobUid.String() + "/pod/report.tgz"}}},
+			{Name: "collected", ArtifactLocation: v1alpha1.ArtifactLocation{S3: &v1alpha1.S3Artifact{Key: jobUid.String() + "/collected"}}}},
+	}
+	wf.Status.Nodes[wf.Name] = root
+	wf.Status.ArtifactRepositoryRef = &v1alpha1.ArtifactRepositoryRefStatus{
+		ArtifactRepository: &v1alpha1.ArtifactRepository{S3: &v1alpha1.S3ArtifactRepository{S3Bucket: v1alpha1.S3Bucket{Endpoint: "minio:9000", Bucket: "repository"}}},
+	}
+
+	job := models.Job{Metadata: models.Metadata{Uid: jobUid}, Workflow: models.Workflow{Workspace: "test"}}
+	job.Workflow.Component.Outputs = []models.Data{
+		{Name: "result", Type: models.FlowifyParameterType},
+		{Name: "report", Type: models.FlowifyArtifactType},
+		{Name: "pending", Type: models.FlowifyArtifactType},
+		{Name: "collected", Type: models.FlowifyArtifactType},
+	}
+	client := NewMockClient()
+	client.On("GetJob", mock.Anything, jobUid).Return(job, nil)
+	client.On("GetJob", mock.Anything, mock.Anything).Return(models.Job{}, storage.ErrNotFound)
+
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, fake.NewSimpleClientset(wf), k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	{
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+jobUid.String()+"/outputs", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var outputs []models.JobOutput
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &outputs))
+		require.Len(t, outputs, 4)
+		require.NotNil(t, outputs[0].Value)
+		assert.Equal(t, "42", *outputs[0].Value)
+		require.NotNil(t, outputs[1].Artifact)
+		assert.Equal(t, "artifacts", outputs[1].Artifact.S3.Bucket)
+		assert.Nil(t, outputs[2].Artifact, "not produced")
+		require.NotNil(t, outputs[3].Artifact)
+		assert.Equal(t, "repository", outputs[3].Artifact.S3.Bucket, "resolved against the artifact repository of the job")
+		assert.Equal(t, jobUid.String()+"/collected", outputs[3].Artifact.S3.Key)
+	}
+
+	testcases := []struct {
+		Name                       string
+		URL                        string
+		ExpectedResponseStatusCode int
+		ExpectedBody               string
+	}{
+		{"download", "/api/v1/jobs/" + jobUid.String() + "/outputs/report/artifact", http.StatusOK, "artifact"},
+		{"not produced", "/api/v1/jobs/" + jobUid.String() + "/outputs/pending/artifact", http.StatusNotFound, ""},
+		{"parameter", "/api/v1/jobs/" + jobUid.String() + "/outputs/result/artifact", http.StatusBadRequest, ""},
+		{"no such output", "/api/v1/jobs/" + jobUid.String() + "/outputs/missing/artifact", http.StatusNotFound, ""},
+		{"no such job", "/api/v1/jobs/" + models.NewComponentReference().String() + "/outputs/report/artifact", http.StatusNotFound, ""},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.URL, nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, w.Body.String())
+			if w.Code == http.StatusOK {
+				assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="report.tgz"`, w.Header().Get("Content-Disposition"))
+				assert.Equal(t, test.ExpectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_JobLogsHTTPHandler(t *testing.T) {
+	jobUid := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fd1")
+	wf := makeFailedWorkflow(jobUid.String(), "test")
+	nodeB := wf.Status.Nodes.FindByDisplayName("B")
+	require.NotNil(t, nodeB)
+	podName := argoutil.PodName(wf.Name, nodeB.Name, nodeB.TemplateName, nodeB.ID, argoutil.GetWorkflowPodNameVersion(wf))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: "test", Labels: map[string]string{"workflows.argoproj.io/workflow": wf.Name}},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+	}
+
+	mux := gmux.NewRouter()
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), NewMockClient(), fake.NewSimpleClientset(wf), k8sfake.NewSimpleClientset(pod), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
+
+	wss := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"test-dev"}}}}
+	accessUser := user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"test-dev"}}
+	noAccessUser := user.MockUser{Uid: "1", Email: "other@author.com", Roles: []user.Role{"other"}}
+
+	testcases := []struct {
+		Name                       string
+		URL                        string
+		User                       user.User
+		ExpectedResponseStatusCode int
+		ExpectedContentType        string
+		ExpectedBody               string
+	}{
+		{"job logs", "/api/v1/jobs/" + jobUid.String() + "/logs", accessUser, http.StatusOK, "text/plain", podName + ": fake logs\n"},
+		{"node logs", "/api/v1/jobs/" + jobUid.String() + "/nodes/" + nodeB.ID + "/logs", accessUser, http.StatusOK, "text/plain", "fake logs\n"},
+		{"follow job logs", "/api/v1/jobs/" + jobUid.String() + "/logs?follow=true", accessUser, http.StatusOK, "text/event-stream",
+			fmt.Sprintf("data: {\"content\":\"fake logs\",\"podName\":\"%s\"}\n\n", podName)},
+		{"node without pod", "/api/v1/jobs/" + jobUid.String() + "/nodes/" + wf.Name + "/logs", accessUser, http.StatusBadRequest, "application/json", ""},
+		{"missing node", "/api/v1/jobs/" + jobUid.String() + "/nodes/missing/logs", accessUser, http.StatusNotFound, "application/json", ""},
+		{"bad options", "/api/v1/jobs/" + jobUid.String() + "/logs?tailLines=x", accessUser, http.StatusBadRequest, "application/json", ""},
+		{"no access", "/api/v1/jobs/" + jobUid.String() + "/logs", noAccessUser, http.StatusForbidden, "application/json", ""},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			// the follow stream is closed by the client, since the fake watches never report the job completed
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			ctx = context.WithValue(ctx, user.UserKey, test.User)
+			ctx = context.WithValue(ctx, workspace.WorkspaceKey, wss)
+			req := httptest.NewRequest(http.MethodGet, test.URL, nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			require.Equal(t, test.ExpectedResponseStatusCode, w.Code, w.Body.String())
+			require.Equal(t, test.ExpectedContentType, w.Result().Header.Get("Content-Type"))
+			if test.ExpectedBody != "" {
+				require.Equal(t, test.ExpectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
 func Test_PermissionMiddleware(t *testing.T) {
 	mux := gmux.NewRouter()
 	subrouter := mux.PathPrefix("/").Subrouter()
@@ -528,7 +1185,7 @@ func Test_JobEventHTTPHandler(t *testing.T) {
 	}()
 
 	mux := gmux.NewRouter()
-	RegisterJobRoutes(mux.PathPrefix("/api/v1"), nil, argoClientSet)
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), nil, argoClientSet, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
 
 	testcases := []testCase{
 		{Name: "listen for job events", Method: http.MethodGet, URL: "/api/v1/jobs/dummy/events/", Body: nil, ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: nil}}
@@ -583,7 +1240,7 @@ func Test_JobDeleteHandler(t *testing.T) {
 	argoClient.PrependReactor("get", "workflows", GetReactor)
 	argoClient.PrependReactor("delete", "workflows", DeleteReactor)
 	mux := gmux.NewRouter()
-	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClient)
+	RegisterJobRoutes(mux.PathPrefix("/api/v1"), client, argoClient, k8sfake.NewSimpleClientset(), jobmonitor.NewDefaultJobMonitorMock(), artifact.NewDefaultArtifactClientMock())
 
 	testcases := []testCase{
 		{Name: "terminate job", Method: http.MethodDelete, URL: fmt.Sprintf("/api/v1/jobs/%s", cRefVer.Uid.String()), Body: []byte(cRefVer.Uid.String()), ExpectedResponseStatusCode: http.StatusOK, Headers: nil, ExpectedResponseHeaders: nil},
@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/gorilla/mux"
+)
+
+func RegisterIndexRoutes(r *mux.Route, client storage.IndexClient) {
+	s := r.Subrouter()
+
+	const intype = "application/json"
+	const outtype = "application/json"
+
+	s.Use(CheckContentHeaderMiddleware(intype))
+	s.Use(CheckAcceptRequestHeaderMiddleware(outtype))
+	s.Use(SetContentTypeMiddleware(outtype))
+
+	s.HandleFunc("/admin/indexes/", StorageAdminAuthorization(IndexReportHandler(client))).Methods(http.MethodGet)
+}
+
+func StorageAdminAuthorization(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := user.GetUser(r.Context())
+		if !user.CanManageStorage(u) {
+			err := fmt.Errorf("not authorized")
+			AuthorizationDenied(w, r, err)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Reports the missing and extra indexes of the collections, compared to the indexes ensured at startup
+func IndexReportHandler(client storage.IndexClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "getIndexReport"
+
+		report, err := client.IndexReport(r.Context())
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get index report", err.Error()}, opId)
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, report, opId)
+	})
+}
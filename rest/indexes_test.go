@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/user"
+	gmux "github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// implement a mock index client
+type mockIndexClient struct {
+	mock.Mock
+}
+
+func (c *mockIndexClient) EnsureIndexes(ctx context.Context) (models.IndexReport, error) {
+	args := c.Called(ctx)
+	return args.Get(0).(models.IndexReport), args.Error(1)
+}
+
+func (c *mockIndexClient) IndexReport(ctx context.Context) (models.IndexReport, error) {
+	args := c.Called(ctx)
+	return args.Get(0).(models.IndexReport), args.Error(1)
+}
+
+func Test_IndexReportHTTPHandler(t *testing.T) {
+	report := models.IndexReport{Collections: []models.CollectionIndexReport{
+		{Collection: "Components", Missing: []string{"uid_version"}, Extra: []string{}},
+		{Collection: "Workflows", Missing: []string{}, Extra: []string{"legacy"}},
+	}}
+	client := &mockIndexClient{}
+	client.On("IndexReport", mock.Anything).Return(report, nil)
+
+	mux := gmux.NewRouter()
+	RegisterIndexRoutes(mux.PathPrefix("/api/v1"), client)
+
+	serve := func(role user.Role) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/indexes/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(user.UserContext(user.MockUser{Uid: "0", Roles: []user.Role{role}}, req.Context()))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	{
+		w := serve("admin")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var out models.IndexReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		require.Equal(t, report, out)
+	}
+
+	{
+		w := serve("tester")
+		require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	}
+}
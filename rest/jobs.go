@@ -7,25 +7,45 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 
+	"github.com/argoproj/argo-workflows/v3/persist/sqldb"
+	workflowpkg "github.com/argoproj/argo-workflows/v3/pkg/apiclient/workflow"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
-	v1a1 "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/typed/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logs"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/argoproj/argo-workflows/v3/workflow/hydrator"
 	"github.com/argoproj/argo-workflows/v3/workflow/util"
 	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/artifact"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/equinor/flowify-workflows-server/storage"
 	"github.com/equinor/flowify-workflows-server/transpiler"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
 )
 
+const (
+	yamlMediaType    = "application/yaml"
+	tagsAnnotation   = "flowify.io/tags"
+	originAnnotation = "flowify.io/origin"
+)
+
+// returned when a job is not in a state allowing the requested change, e.g. resuming a job that is not suspended
+var ErrJobStateConflict = errors.New("job state conflict")
+
 func getWorkspaceByJobUID(ctx context.Context, argoClient argoclient.Interface, id models.ComponentReference) (string, error) {
 	jobs, err := argoClient.ArgoprojV1alpha1().Workflows("").List(ctx, metav1.ListOptions{FieldSelector: GetFieldnameSelector(id.String())})
 	if err != nil {
@@ -42,7 +62,7 @@ func getWorkspaceByJobUID(ctx context.Context, argoClient argoclient.Interface,
 	return jobs.Items[0].GetNamespace(), nil
 }
 
-func RegisterJobRoutes(r *mux.Route, componentClient storage.ComponentClient, argoclient argoclient.Interface) {
+func RegisterJobRoutes(r *mux.Route, componentClient storage.ComponentClient, argoclient argoclient.Interface, k8sclient kubernetes.Interface, monitor jobmonitor.JobMonitor, artifacts artifact.ArtifactClient) {
 	// path is ../
 	s := r.PathPrefix("/jobs/").Subrouter()
 
@@ -51,16 +71,26 @@ func RegisterJobRoutes(r *mux.Route, componentClient storage.ComponentClient, ar
 	s.Use(CheckContentHeaderMiddleware(intype))
 	s.Use(SetContentTypeMiddleware(outtype)) // the event handler may override this
 
+	// the rendered manifest can be requested as yaml, register before the json-only routes
+	s.HandleFunc("/render", JobRenderHandler(componentClient, argoclient)).Methods(http.MethodPost)
+
 	s1 := s.NewRoute().Subrouter()
 	s1.Use(CheckAcceptRequestHeaderMiddleware(outtype))
 
 	// first add some explicit handlefuncs that will match the root path ("jobs/")
-	s1.HandleFunc("/", JobsSubmitHandler(componentClient, argoclient)).Methods(http.MethodPost)
+	s1.HandleFunc("/", JobsSubmitHandler(componentClient, argoclient, monitor)).Methods(http.MethodPost)
 	s1.HandleFunc("/", JobsListHandler(componentClient, argoclient)).Methods(http.MethodGet)
 	s1.HandleFunc("/{id}", JobGetHandler(componentClient)).Methods(http.MethodGet)
 	s1.HandleFunc("/{id}", JobDeleteHandler(componentClient, argoclient)).Methods(http.MethodDelete)
 	s1.HandleFunc("/{id}/terminate", JobTerminateHandler(argoclient)).Methods(http.MethodPost)
+	s1.HandleFunc("/{id}/suspend", JobSuspendHandler(argoclient)).Methods(http.MethodPost)
+	s1.HandleFunc("/{id}/resume", JobResumeHandler(argoclient)).Methods(http.MethodPost)
+	s1.HandleFunc("/{id}/resubmit", JobResubmitHandler(componentClient, argoclient, monitor)).Methods(http.MethodPost)
+	s1.HandleFunc("/{id}/retry", JobRetryHandler(componentClient, argoclient, monitor)).Methods(http.MethodPost)
 	s1.HandleFunc("/{id}/status", JobStatusHandler(argoclient)).Methods(http.MethodGet)
+	s1.HandleFunc("/{id}/nodes", JobNodesHandler(argoclient)).Methods(http.MethodGet)
+	s1.HandleFunc("/{id}/timeline", JobTimelineHandler(componentClient)).Methods(http.MethodGet)
+	s1.HandleFunc("/{id}/outputs", JobOutputsHandler(componentClient, argoclient)).Methods(http.MethodGet)
 
 	// now add the wildcard paths
 	s2 := s.PathPrefix("/{id}/events/").Subrouter()
@@ -68,6 +98,11 @@ func RegisterJobRoutes(r *mux.Route, componentClient storage.ComponentClient, ar
 	const eventOutType = "text/event-stream"
 	s2.Use(CheckAcceptRequestHeaderMiddleware(eventOutType))
 
+	// logs are sent as an event stream in follow mode, and as plain text otherwise. artifacts are sent as stored
+	s3 := s.PathPrefix("/{id}").Subrouter()
+	s3.HandleFunc("/logs", JobLogsHandler(argoclient, k8sclient)).Methods(http.MethodGet)
+	s3.HandleFunc("/nodes/{nodeId}/logs", JobNodeLogsHandler(argoclient, k8sclient)).Methods(http.MethodGet)
+	s3.HandleFunc("/outputs/{port}/artifact", JobOutputArtifactHandler(componentClient, argoclient, artifacts)).Methods(http.MethodGet)
 }
 
 func JobGetHandler(componentClient storage.ComponentClient) http.HandlerFunc {
@@ -101,7 +136,7 @@ func JobsListHandler(componentClient storage.ComponentClient, argoclient argocli
 	})
 }
 
-func JobsSubmitHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface) http.HandlerFunc {
+func JobsSubmitHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface, monitor jobmonitor.JobMonitor) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		request := models.JobPostRequest{}
 		if err := ReadBody(r, &request); err != nil {
@@ -115,106 +150,351 @@ func JobsSubmitHandler(componentClient storage.ComponentClient, argoclient argoc
 			return
 		}
 
-		// create a storeble job from request job
-		job, err := InitializeJob(r.Context(), request.Job)
+		// the lockfile is always generated on submission
+		request.Job.Lockfile = nil
+
+		if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun")); dryRun {
+			JobLikeDryRunHandler(w, r, componentClient, argoclient, request.Job, request.SubmitOptions, "submitJob")
+			return
+		}
+
+		JobLikeSubmitHandler(w, r, componentClient, argoclient, monitor, request.Job, request.SubmitOptions, "submitJob")
+	})
+}
+
+func JobResubmitHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface, monitor jobmonitor.JobMonitor) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
 		if err != nil {
-			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error saving job info to db", err.Error()}, "submitJob")
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "resubmitJob")
 			return
 		}
 
-		_, err = StoreJob(r.Context(), componentClient, job)
+		original, err := componentClient.GetJob(r.Context(), id)
 		if err != nil {
-			log.Error(errors.Wrapf(err, "cannot store job").Error())
-			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error storing component", err.Error()}, "submitJob")
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get job", err.Error()}, "resubmitJob")
+			case errors.Is(err, storage.ErrNoAccess):
+				WriteErrorResponse(w, APIError{http.StatusForbidden, "could not get job", err.Error()}, "resubmitJob")
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get job", err.Error()}, "resubmitJob")
+			}
 			return
 		}
 
-		// dereferencing component
-		cmp := job.Workflow.Component
-		derefCmp, err := storage.DereferenceComponent(r.Context(), componentClient, cmp)
+		// replay the stored job with the versions pinned by its lockfile
+		job := models.Job{
+			Metadata:    models.Metadata{Name: original.Name, Description: original.Description},
+			Type:        original.Type,
+			InputValues: original.InputValues,
+			Workflow:    original.Workflow,
+			Lockfile:    original.Lockfile,
+			Origin:      &models.JobOrigin{Uid: original.Uid, Type: models.JobOriginResubmit},
+		}
+
+		JobLikeSubmitHandler(w, r, componentClient, argoclient, monitor, job, models.JobPostOptions{}, "resubmitJob")
+	})
+}
+
+func JobRetryHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface, monitor jobmonitor.JobMonitor) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
 		if err != nil {
-			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}, "submitJob")
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "retryJob")
 			return
 		}
-		job.Workflow.Component = derefCmp
 
-		argoWf, err := transpiler.GetArgoWorkflow(job)
+		original, err := componentClient.GetJob(r.Context(), id)
 		if err != nil {
-			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}, "submitJob")
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get job", err.Error()}, "retryJob")
+			case errors.Is(err, storage.ErrNoAccess):
+				WriteErrorResponse(w, APIError{http.StatusForbidden, "could not get job", err.Error()}, "retryJob")
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get job", err.Error()}, "retryJob")
+			}
 			return
 		}
 
-		// set the argo-job name same as the flowify uid
-		argoWf.SetName(job.Metadata.Uid.String())
+		workspace, err := getWorkspaceByJobUID(r.Context(), argoclient, id)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, err.Error(), fmt.Sprintf("workspace for job %s not found", id.String())}, "retryJob")
+			return
+		}
 
-		if len(request.SubmitOptions.Tags) > 0 {
-			argoWf.SetAnnotations(map[string]string{"flowify.io/tags": strings.Join(request.SubmitOptions.Tags, ";")})
+		wfi := argoclient.ArgoprojV1alpha1().Workflows(workspace)
+		originalWf, err := wfi.Get(r.Context(), id.String(), metav1.GetOptions{})
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("job not found: %s", id.String()), ""}, "retryJob")
+			return
+		}
+
+		job := models.Job{
+			Metadata:    models.Metadata{Name: original.Name, Description: original.Description},
+			Type:        original.Type,
+			InputValues: original.InputValues,
+			Workflow:    original.Workflow,
+			Lockfile:    original.Lockfile,
+			Origin:      &models.JobOrigin{Uid: original.Uid, Type: models.JobOriginRetry},
+		}
+		job, err = InitializeJob(r.Context(), job)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error saving job info to db", err.Error()}, "retryJob")
+			return
 		}
-		rwf := job.Workflow
-		wfi := argoclient.ArgoprojV1alpha1().Workflows(rwf.Workspace)
-		_, err = wfi.Create(r.Context(), argoWf, metav1.CreateOptions{})
 
+		argoWf, err := RetryWorkflow(originalWf, job.Uid.String())
 		if err != nil {
-			// TODO: work this out more detailed
-			var code int
-			switch err.(type) {
-			case *apierr.StatusError:
-				code = http.StatusServiceUnavailable
-			default:
-				code = http.StatusBadRequest
-			}
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot retry job", err.Error()}, "retryJob")
+			return
+		}
+		argoWf.Annotations[originAnnotation] = original.Uid.String()
+
+		_, err = StoreJob(r.Context(), componentClient, job)
+		if err != nil {
+			log.Error(errors.Wrapf(err, "cannot store job").Error())
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error storing component", err.Error()}, "retryJob")
+			return
+		}
 
-			WriteErrorResponse(w, APIError{code, "cannot start the workflow job", err.Error()}, "submitJob")
+		_, err = wfi.Create(r.Context(), argoWf, metav1.CreateOptions{})
+		if err != nil {
+			WriteErrorResponse(w, APIError{createErrorCode(err), "cannot start the workflow job", err.Error()}, "retryJob")
 			return
 		}
 
-		go EventSaver(context.TODO(), wfi, job.Uid, componentClient)
+		monitor.Watch(job.Uid, workspace)
 
 		locHeader := map[string]string{"Location": path.Join("/api/v1/jobs/", job.Metadata.Uid.String())}
-		//WriteResponseAndHeaders(w, http.StatusCreated, locHeader, []byte(`{}`))
-		WriteResponse(w, http.StatusCreated, locHeader, nil, "submitJob")
+		WriteResponse(w, http.StatusCreated, locHeader, nil, "retryJob")
 	})
 }
 
-func EventSaver(ctx context.Context, wfi v1a1.WorkflowInterface, jobid models.ComponentReference, storageClient storage.ComponentClient) {
-	watch, _ := wfi.Watch(ctx, metav1.ListOptions{FieldSelector: GetFieldnameSelector(jobid.String())})
-	defer watch.Stop()
+// Formulates a new workflow with the given name from a failed workflow. Successful nodes of the original are
+// marked as skipped and their outputs are reused, as with argo resubmit --memoized
+func RetryWorkflow(wf *wfv1.Workflow, name string) (*wfv1.Workflow, error) {
+	newWf, err := util.FormulateResubmitWorkflow(wf, true, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot formulate retry of workflow %s", wf.Name)
+	}
 
-	events := []models.JobEvent{}
+	// the formulated workflow has a generated name, the node names and ids are derived from it
+	generatedName := newWf.Name
+	newWf.Name = name
+	newWf.GenerateName = ""
 
-loop:
-	for {
-		select {
-		case event, open := <-watch.ResultChan():
-			if !open {
-				log.Error(fmt.Sprintf("cannot monitor events for job %s, monitoring channel closed", jobid.String()))
-			}
+	nodeIds := make(map[string]string, len(newWf.Status.Nodes))
+	for id, node := range newWf.Status.Nodes {
+		nodeIds[id] = newWf.NodeID(name + strings.TrimPrefix(node.Name, generatedName))
+	}
+	convert := func(ids []string) []string {
+		converted := make([]string, len(ids))
+		for i, id := range ids {
+			converted[i] = nodeIds[id]
+		}
+		return converted
+	}
 
-			wf, ok := event.Object.(*wfv1.Workflow)
-			if !ok {
-				// object is probably metav1.Status, `FromObject` can deal with anything
-				log.Warnf("job %s event error: %s", jobid, apierr.FromObject(event.Object).Error())
+	nodes := make(wfv1.Nodes, len(newWf.Status.Nodes))
+	for id, node := range newWf.Status.Nodes {
+		node.Name = name + strings.TrimPrefix(node.Name, generatedName)
+		node.ID = nodeIds[id]
+		if node.BoundaryID != "" {
+			node.BoundaryID = nodeIds[node.BoundaryID]
+		}
+		node.Children = convert(node.Children)
+		node.OutboundNodes = convert(node.OutboundNodes)
+		nodes[node.ID] = node
+	}
+	newWf.Status.Nodes = nodes
+
+	return newWf, nil
+}
+
+// Initializes the job metadata, dereferences the job component and transpiles it to an argo workflow,
+// with the scheduling policy of the workspace applied. The returned job keeps the component references and has its lockfile set
+func RenderJob(ctx context.Context, componentClient storage.ComponentClient, job models.Job, options models.JobPostOptions) (models.Job, *wfv1.Workflow, *DetailedAPIError) {
+	// create a storeble job from request job
+	job, err := InitializeJob(ctx, job)
+	if err != nil {
+		return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "error saving job info to db", err.Error()}}
+	}
+
+	// dereferencing component, the stored job keeps the references and the resolved versions in the lockfile
+	derefCmp, lockfile, err := storage.DereferenceComponentWithLockfile(ctx, componentClient, job.Workflow.Component, job.Lockfile)
+	if err != nil {
+		var derefErr *storage.DereferenceError
+		if errors.As(err, &derefErr) {
+			return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusBadRequest, "cannot dereference job component", err.Error()}, Cause: derefErr}
+		}
+		return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}}
+	}
+
+	resolvedJob := job
+	resolvedJob.Workflow.Component = derefCmp
+	if job.Workflow.OnExit != nil {
+		// pinned to the versions used by the workflow component
+		derefExit, exitLockfile, err := storage.DereferenceComponentWithLockfile(ctx, componentClient, job.Workflow.OnExit.Node, lockfile)
+		if err != nil {
+			var derefErr *storage.DereferenceError
+			if errors.As(err, &derefErr) {
+				return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusBadRequest, "cannot dereference job exit handler", err.Error()}, Cause: derefErr}
 			}
+			return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}}
+		}
+	exitLocks:
+		for _, l := range exitLockfile {
+			for _, locked := range lockfile {
+				if locked.Uid == l.Uid {
+					continue exitLocks
+				}
+			}
+			lockfile = append(lockfile, l)
+		}
+		resolvedJob.Workflow.OnExit = &models.Node{Id: job.Workflow.OnExit.Id, Node: derefExit}
+		if err := resolvedJob.Workflow.ValidateOnExit(); err != nil {
+			return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusBadRequest, "invalid job exit handler", err.Error()}}
+		}
+	}
+	job.Lockfile = lockfile
+	resolvedJob.Lockfile = lockfile
 
-			events = append(events, models.JobEvent(*wf))
+	argoWf, err := transpiler.GetArgoWorkflow(resolvedJob)
+	if err != nil {
+		var exprErr *models.ExpressionError
+		if errors.As(err, &exprErr) {
+			return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}}
+		}
+		return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}}
+	}
 
-			if wf.Status.Phase.Completed() {
-				watch.Stop()
-				break loop
+	for _, ws := range GetWorkspaceAccess(ctx) {
+		if ws.Name != job.Workflow.Workspace || ws.SchedulingPolicy == nil {
+			continue
+		}
+		if err := transpiler.ApplySchedulingPolicy(argoWf, *ws.SchedulingPolicy); err != nil {
+			var limitErr *workspace.ResourceLimitError
+			if errors.As(err, &limitErr) {
+				return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusBadRequest, "job exceeds the resource limits of the workspace", err.Error()}}
 			}
-		case <-ctx.Done():
-			break loop
+			return models.Job{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "error generating a Argo workflow manifest", err.Error()}}
 		}
 	}
 
-	if len(events) > 0 {
-		err := storageClient.AddJobEvents(ctx, jobid, events)
+	// set the argo-job name same as the flowify uid
+	argoWf.SetName(job.Metadata.Uid.String())
+
+	annotations := map[string]string{}
+	if len(options.Tags) > 0 {
+		annotations[tagsAnnotation] = strings.Join(options.Tags, ";")
+	}
+	if job.Origin != nil {
+		annotations[originAnnotation] = job.Origin.Uid.String()
+	}
+	if len(annotations) > 0 {
+		argoWf.SetAnnotations(annotations)
+	}
+
+	return job, argoWf, nil
+}
+
+func createErrorCode(err error) int {
+	// TODO: work this out more detailed
+	switch err.(type) {
+	case *apierr.StatusError:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Dereferences, transpiles, stores and starts a job, writes the http response
+func JobLikeSubmitHandler(w http.ResponseWriter, r *http.Request, componentClient storage.ComponentClient, argoclient argoclient.Interface, monitor jobmonitor.JobMonitor, job models.Job, options models.JobPostOptions, opId string) {
+	job, argoWf, apiErr := RenderJob(r.Context(), componentClient, job, options)
+	if apiErr != nil {
+		WriteDetailedErrorResponse(w, *apiErr, opId)
+		return
+	}
+
+	_, err := StoreJob(r.Context(), componentClient, job)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "cannot store job").Error())
+		WriteErrorResponse(w, APIError{http.StatusInternalServerError, "error storing component", err.Error()}, opId)
+		return
+	}
+
+	rwf := job.Workflow
+	wfi := argoclient.ArgoprojV1alpha1().Workflows(rwf.Workspace)
+	_, err = wfi.Create(r.Context(), argoWf, metav1.CreateOptions{})
+
+	if err != nil {
+		WriteErrorResponse(w, APIError{createErrorCode(err), "cannot start the workflow job", err.Error()}, opId)
+		return
+	}
+
+	monitor.Watch(job.Uid, rwf.Workspace)
+
+	locHeader := map[string]string{"Location": path.Join("/api/v1/jobs/", job.Metadata.Uid.String())}
+	//WriteResponseAndHeaders(w, http.StatusCreated, locHeader, []byte(`{}`))
+	WriteResponse(w, http.StatusCreated, locHeader, nil, opId)
+}
+
+// Runs the submit pipeline with a server side dry-run create, nothing is stored or started.
+// Writes the resulting argo workflow as json, or yaml if requested
+func JobLikeDryRunHandler(w http.ResponseWriter, r *http.Request, componentClient storage.ComponentClient, argoclient argoclient.Interface, job models.Job, options models.JobPostOptions, opId string) {
+	// the dry-run is created with the server credentials, it is not to reach into other workspaces
+	if !storage.CheckWorkspaceAccess(r.Context(), job.Workflow.Workspace) {
+		WriteErrorResponse(w, APIError{http.StatusForbidden, "no access to job workspace", fmt.Sprintf("no access to workspace %s", job.Workflow.Workspace)}, opId)
+		return
+	}
+
+	job, argoWf, apiErr := RenderJob(r.Context(), componentClient, job, options)
+	if apiErr != nil {
+		WriteDetailedErrorResponse(w, *apiErr, opId)
+		return
+	}
+
+	opts := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+	wf, err := argoclient.ArgoprojV1alpha1().Workflows(job.Workflow.Workspace).Create(r.Context(), argoWf, opts)
+	if err != nil {
+		WriteErrorResponse(w, APIError{createErrorCode(err), "workflow job dry-run failed", err.Error()}, opId)
+		return
+	}
+
+	if r.Header.Get("Accept") == yamlMediaType || r.URL.Query().Get("format") == "yaml" {
+		bytes, err := yaml.Marshal(wf)
 		if err != nil {
-			log.Error(errors.Wrapf(err, "can not save job %s events", jobid.String()))
-		} else {
-			log.Infof("Job %s events saved", jobid.String())
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "cannot marshal workflow manifest", err.Error()}, opId)
+			return
 		}
+		w.Header().Set("Content-Type", yamlMediaType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(bytes)
+		return
 	}
+
+	WriteResponse(w, http.StatusOK, nil, wf, opId)
+}
+
+func JobRenderHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := models.JobPostRequest{}
+		if err := ReadBody(r, &request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "renderJob")
+			return
+		}
+
+		// assert that the request does not have a uid set
+		if !request.Job.Metadata.Uid.IsZero() {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "JobSubmitRequest may not have uid set", fmt.Sprintf("non zero uid (%s)", request.Job.Metadata.Uid.String())}, "renderJob")
+			return
+		}
+		request.Job.Lockfile = nil
+
+		JobLikeDryRunHandler(w, r, componentClient, argoclient, request.Job, request.SubmitOptions, "renderJob")
+	})
 }
 
 func JobDeleteHandler(storageClient storage.ComponentClient, argoclient argoclient.Interface) http.HandlerFunc {
@@ -275,6 +555,72 @@ func JobTerminateHandler(argoClient argoclient.Interface) http.HandlerFunc {
 	})
 }
 
+func JobSuspendHandler(argoClient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "suspendJob")
+			return
+		}
+
+		workspace, err := getWorkspaceByJobUID(r.Context(), argoClient, id)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, err.Error(), fmt.Sprintf("workspace for job %s not found", id.String())}, "suspendJob")
+			return
+		}
+
+		if !storage.CheckWorkspaceAccess(r.Context(), workspace) {
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "no access to job", fmt.Sprintf("no access to workspace %s", workspace)}, "suspendJob")
+			return
+		}
+
+		err = SuspendJob(r.Context(), argoClient, id, workspace)
+		if err != nil {
+			if errors.Is(err, ErrJobStateConflict) {
+				WriteErrorResponse(w, APIError{http.StatusConflict, err.Error(), "job suspension failed"}, "suspendJob")
+				return
+			}
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, err.Error(), "job suspension failed"}, "suspendJob")
+			return
+		}
+
+		WriteResponse(w, http.StatusAccepted, nil, id, "suspendJob")
+	})
+}
+
+func JobResumeHandler(argoClient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "resumeJob")
+			return
+		}
+
+		workspace, err := getWorkspaceByJobUID(r.Context(), argoClient, id)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, err.Error(), fmt.Sprintf("workspace for job %s not found", id.String())}, "resumeJob")
+			return
+		}
+
+		if !storage.CheckWorkspaceAccess(r.Context(), workspace) {
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "no access to job", fmt.Sprintf("no access to workspace %s", workspace)}, "resumeJob")
+			return
+		}
+
+		err = ResumeJob(r.Context(), argoClient, id, workspace)
+		if err != nil {
+			if errors.Is(err, ErrJobStateConflict) {
+				WriteErrorResponse(w, APIError{http.StatusConflict, err.Error(), "job resume failed"}, "resumeJob")
+				return
+			}
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, err.Error(), "job resume failed"}, "resumeJob")
+			return
+		}
+
+		WriteResponse(w, http.StatusAccepted, nil, id, "resumeJob")
+	})
+}
+
 func JobsEventstreamHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		jobid := mux.Vars(r)["id"]
@@ -340,14 +686,364 @@ func JobStatusHandler(argoclient argoclient.Interface) http.HandlerFunc {
 			return
 		}
 		jobStatus := models.JobStatus{
-			Uid:    id,
-			Status: job.Status.Phase,
+			Uid:       id,
+			Status:    job.Status.Phase,
+			Suspended: util.IsWorkflowSuspended(job),
 		}
 
 		WriteResponse(w, http.StatusOK, nil, jobStatus, "statusJob")
 	})
 }
 
+func JobTimelineHandler(componentClient storage.ComponentClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "timelineJob")
+			return
+		}
+
+		// the job is access checked, its events are not
+		_, err = componentClient.GetJob(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get job", err.Error()}, "timelineJob")
+			case errors.Is(err, storage.ErrNoAccess):
+				WriteErrorResponse(w, APIError{http.StatusForbidden, "could not get job", err.Error()}, "timelineJob")
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get job", err.Error()}, "timelineJob")
+			}
+			return
+		}
+
+		events, err := componentClient.ListJobEvents(r.Context(), id)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get job events", err.Error()}, "timelineJob")
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, models.JobTimeline{Uid: id, Events: events}, "timelineJob")
+	})
+}
+
+// getJobWorkflow returns the stored job and its running or finished workflow, writes the error response if not accessible
+func getJobWorkflow(w http.ResponseWriter, r *http.Request, componentClient storage.ComponentClient, argoclient argoclient.Interface, opId string) (models.Job, *wfv1.Workflow, bool) {
+	id, err := getIdFromMuxerPath(r)
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+		return models.Job{}, nil, false
+	}
+
+	job, err := componentClient.GetJob(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get job", err.Error()}, opId)
+		case errors.Is(err, storage.ErrNoAccess):
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "could not get job", err.Error()}, opId)
+		default:
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get job", err.Error()}, opId)
+		}
+		return models.Job{}, nil, false
+	}
+
+	wf, err := argoclient.ArgoprojV1alpha1().Workflows(job.Workflow.Workspace).Get(r.Context(), id.String(), metav1.GetOptions{})
+	if err != nil {
+		if apierr.IsNotFound(err) {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("job workflow not found: %s", id.String()), err.Error()}, opId)
+		} else {
+			WriteErrorResponse(w, APIError{http.StatusServiceUnavailable, "could not get job workflow", err.Error()}, opId)
+		}
+		return models.Job{}, nil, false
+	}
+	return job, wf, true
+}
+
+func JobOutputsHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, wf, ok := getJobWorkflow(w, r, componentClient, argoclient, "outputsJob")
+		if !ok {
+			return
+		}
+
+		outputs := transpiler.GetJobOutputs(job.Workflow.Component.Outputs, wf)
+		for i, o := range outputs {
+			if o.Artifact == nil {
+				continue
+			}
+			// artifacts stored by key are located in the artifact repository of the workflow
+			location, err := artifact.ResolveLocation(wf, wfv1.Artifact{Name: o.Name, ArtifactLocation: *o.Artifact})
+			if err != nil {
+				// not resolvable, show as recorded
+				continue
+			}
+			outputs[i].Artifact = location
+		}
+
+		WriteResponse(w, http.StatusOK, nil, outputs, "outputsJob")
+	})
+}
+
+func JobOutputArtifactHandler(componentClient storage.ComponentClient, argoclient argoclient.Interface, artifacts artifact.ArtifactClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, wf, ok := getJobWorkflow(w, r, componentClient, argoclient, "artifactJob")
+		if !ok {
+			return
+		}
+
+		port := mux.Vars(r)["port"]
+		var output *models.Data
+		for i, o := range job.Workflow.Component.Outputs {
+			if o.Name == port {
+				output = &job.Workflow.Component.Outputs[i]
+			}
+		}
+		if output == nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("no output %s", port), ""}, "artifactJob")
+			return
+		}
+		if output.Type != models.FlowifyArtifactType {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, fmt.Sprintf("output %s is not an artifact", port), output.Type}, "artifactJob")
+			return
+		}
+
+		art := transpiler.GetJobOutputArtifact(wf, port)
+		if art == nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("artifact %s not produced", port), ""}, "artifactJob")
+			return
+		}
+
+		reader, err := artifacts.OpenArtifact(r.Context(), wf, *art)
+		if err != nil {
+			switch err {
+			case artifact.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("artifact %s not found", port), err.Error()}, "artifactJob")
+			case artifact.ErrUnsupported:
+				WriteErrorResponse(w, APIError{http.StatusNotImplemented, fmt.Sprintf("cannot download artifact %s", port), err.Error()}, "artifactJob")
+			default:
+				WriteErrorResponse(w, APIError{http.StatusServiceUnavailable, fmt.Sprintf("cannot download artifact %s", port), err.Error()}, "artifactJob")
+			}
+			return
+		}
+		defer reader.Close()
+
+		filename := port
+		if key, err := art.GetKey(); err == nil && path.Base(key) != "." && path.Base(key) != "/" {
+			filename = path.Base(key)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, reader); err != nil {
+			// the status is already sent
+			log.Errorf("cannot send artifact %s of job %s: %s", port, job.Uid.String(), err.Error())
+		}
+	})
+}
+
+func JobNodesHandler(argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, "nodesJob")
+			return
+		}
+
+		workspace, err := getWorkspaceByJobUID(r.Context(), argoclient, id)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, err.Error(), fmt.Sprintf("workspace for job %s not found", id.String())}, "nodesJob")
+			return
+		}
+
+		if !storage.CheckWorkspaceAccess(r.Context(), workspace) {
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "no access to job", fmt.Sprintf("no access to workspace %s", workspace)}, "nodesJob")
+			return
+		}
+
+		job, err := argoclient.ArgoprojV1alpha1().Workflows(workspace).Get(r.Context(), id.String(), metav1.GetOptions{})
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("job not found: %s", id.String()), ""}, "nodesJob")
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, transpiler.GetJobNodeStatuses(job), "nodesJob")
+	})
+}
+
+func JobLogsHandler(argoclient argoclient.Interface, k8sclient kubernetes.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		JobLikeLogsHandler(w, r, argoclient, k8sclient, "", "jobLogs")
+	})
+}
+
+func JobNodeLogsHandler(argoclient argoclient.Interface, k8sclient kubernetes.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		JobLikeLogsHandler(w, r, argoclient, k8sclient, mux.Vars(r)["nodeId"], "nodeLogs")
+	})
+}
+
+// Writes the container logs of the pods of a job, or of a single node if nodeId is set.
+// With ?follow=true the logs are streamed as server-sent events until the job completes,
+// otherwise the current logs are returned as a plain text download
+func JobLikeLogsHandler(w http.ResponseWriter, r *http.Request, argoclient argoclient.Interface, k8sclient kubernetes.Interface, nodeId string, opId string) {
+	id, err := getIdFromMuxerPath(r)
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+		return
+	}
+
+	options, err := getLogOptions(r)
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot parse log options", err.Error()}, opId)
+		return
+	}
+
+	workspace, err := getWorkspaceByJobUID(r.Context(), argoclient, id)
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusNotFound, err.Error(), fmt.Sprintf("workspace for job %s not found", id.String())}, opId)
+		return
+	}
+
+	if !storage.CheckWorkspaceAccess(r.Context(), workspace) {
+		WriteErrorResponse(w, APIError{http.StatusForbidden, "no access to job logs", fmt.Sprintf("no access to workspace %s", workspace)}, opId)
+		return
+	}
+
+	wf, err := argoclient.ArgoprojV1alpha1().Workflows(workspace).Get(r.Context(), id.String(), metav1.GetOptions{})
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("job not found: %s", id.String()), ""}, opId)
+		return
+	}
+
+	request := workflowpkg.WorkflowLogRequest{Name: wf.Name, Namespace: workspace, LogOptions: &options, Grep: r.URL.Query().Get("grep")}
+	filename := wf.Name + ".log"
+	if nodeId != "" {
+		node, ok := wf.Status.Nodes[nodeId]
+		if !ok {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, fmt.Sprintf("node not found: %s", nodeId), ""}, opId)
+			return
+		}
+		if node.Type == wfv1.NodeTypeRetry && len(node.Children) > 0 {
+			// the logs of the latest attempt
+			node = wf.Status.Nodes[node.Children[len(node.Children)-1]]
+		}
+		if node.Type != wfv1.NodeTypePod {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, fmt.Sprintf("node %s has no logs", nodeId), fmt.Sprintf("node of type %s does not run a pod", node.Type)}, opId)
+			return
+		}
+		request.PodName = util.PodName(wf.Name, node.Name, node.TemplateName, node.ID, util.GetWorkflowPodNameVersion(wf))
+		filename = request.PodName + ".log"
+	}
+
+	var sender logSender
+	if options.Follow {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		w.Header().Set("Connection", "keep-alive")
+		sender = &sseLogSender{w: w}
+	} else {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		// only prefix the lines by pod when the logs of several pods are mixed
+		sender = &plainLogSender{w: w, prefix: nodeId == ""}
+	}
+
+	err = logs.WorkflowLogs(r.Context(), argoclient, k8sclient, &request, sender)
+	if err != nil {
+		log.Error(errors.Wrapf(err, "cannot send logs for job %s", id.String()))
+		if !sender.Started() {
+			w.Header().Set("Content-Type", "application/json") // back to json
+			w.Header().Del("Content-Disposition")
+			w.Header().Del("Connection")
+			WriteErrorResponse(w, APIError{http.StatusServiceUnavailable, "error reading job logs", err.Error()}, opId)
+		}
+		return
+	}
+
+	if !sender.Started() {
+		// no logs yet, still respond with the headers
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func getLogOptions(r *http.Request) (corev1.PodLogOptions, error) {
+	query := r.URL.Query()
+	options := corev1.PodLogOptions{Container: common.MainContainerName}
+	if container := query.Get("container"); container != "" {
+		options.Container = container
+	}
+	if follow := query.Get("follow"); follow != "" {
+		value, err := strconv.ParseBool(follow)
+		if err != nil {
+			return corev1.PodLogOptions{}, errors.Wrapf(err, "invalid value for follow")
+		}
+		options.Follow = value
+	}
+	if timestamps := query.Get("timestamps"); timestamps != "" {
+		value, err := strconv.ParseBool(timestamps)
+		if err != nil {
+			return corev1.PodLogOptions{}, errors.Wrapf(err, "invalid value for timestamps")
+		}
+		options.Timestamps = value
+	}
+	if tailLines := query.Get("tailLines"); tailLines != "" {
+		value, err := strconv.ParseInt(tailLines, 10, 64)
+		if err != nil || value < 0 {
+			return corev1.PodLogOptions{}, errors.Errorf("invalid value for tailLines: %s", tailLines)
+		}
+		options.TailLines = &value
+	}
+	if sinceSeconds := query.Get("sinceSeconds"); sinceSeconds != "" {
+		value, err := strconv.ParseInt(sinceSeconds, 10, 64)
+		if err != nil || value <= 0 {
+			return corev1.PodLogOptions{}, errors.Errorf("invalid value for sinceSeconds: %s", sinceSeconds)
+		}
+		options.SinceSeconds = &value
+	}
+	return options, nil
+}
+
+type logSender interface {
+	Send(entry *workflowpkg.LogEntry) error
+	// true when anything has been written to the response
+	Started() bool
+}
+
+type sseLogSender struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *sseLogSender) Send(entry *workflowpkg.LogEntry) error {
+	s.started = true
+	return WriteEventToSSEStream(s.w, s.w.(http.Flusher), entry)
+}
+
+func (s *sseLogSender) Started() bool {
+	return s.started
+}
+
+type plainLogSender struct {
+	w       io.Writer
+	prefix  bool
+	started bool
+}
+
+func (s *plainLogSender) Send(entry *workflowpkg.LogEntry) error {
+	s.started = true
+	if s.prefix {
+		_, err := fmt.Fprintf(s.w, "%s: %s\n", entry.PodName, entry.Content)
+		return err
+	}
+	_, err := fmt.Fprintln(s.w, entry.Content)
+	return err
+}
+
+func (s *plainLogSender) Started() bool {
+	return s.started
+}
+
 func GetFieldnameSelector(jobname string) string {
 	return fields.OneTermEqualSelector("metadata.name", jobname).String()
 }
@@ -485,3 +1181,29 @@ func TerminateJob(ctx context.Context, argoClient argoclient.Interface, uid mode
 	err = util.TerminateWorkflow(ctx, argoClient.ArgoprojV1alpha1().Workflows(workspace), uid.String())
 	return err
 }
+
+func SuspendJob(ctx context.Context, argoClient argoclient.Interface, uid models.ComponentReference, workspace string) error {
+	wf, err := argoClient.ArgoprojV1alpha1().Workflows(workspace).Get(ctx, uid.String(), metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "cannot suspend job %s", uid.String())
+	}
+	if !wf.Status.FinishedAt.IsZero() {
+		return errors.Wrapf(ErrJobStateConflict, "job %s already stopped", uid.String())
+	}
+	err = util.SuspendWorkflow(ctx, argoClient.ArgoprojV1alpha1().Workflows(workspace), uid.String())
+	return err
+}
+
+// Resumes a suspended job, including jobs waiting in suspend steps
+func ResumeJob(ctx context.Context, argoClient argoclient.Interface, uid models.ComponentReference, workspace string) error {
+	wf, err := argoClient.ArgoprojV1alpha1().Workflows(workspace).Get(ctx, uid.String(), metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "cannot resume job %s", uid.String())
+	}
+	if !util.IsWorkflowSuspended(wf) {
+		return errors.Wrapf(ErrJobStateConflict, "job %s is not suspended", uid.String())
+	}
+	// node status offloading is not configured, so nodes are always stored in the workflow object
+	err = util.ResumeWorkflow(ctx, argoClient.ArgoprojV1alpha1().Workflows(workspace), hydrator.New(sqldb.ExplosiveOffloadNodeStatusRepo), uid.String(), "")
+	return err
+}
@@ -12,6 +12,8 @@ import (
 	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
 	"github.com/equinor/flowify-workflows-server/auth"
 	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/artifact"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
 	"github.com/equinor/flowify-workflows-server/pkg/secret"
 	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/equinor/flowify-workflows-server/storage"
@@ -27,6 +29,13 @@ type APIError struct {
 	Detail  string `json:"detail"`
 }
 
+// DetailedAPIError is an APIError with a structured cause the client can act on,
+// e.g. the kind and path of a component reference that cannot be dereferenced
+type DetailedAPIError struct {
+	APIError
+	Cause interface{} `json:"cause,omitempty"`
+}
+
 const (
 	// tries to validate all input according to spec
 	validateInput bool = true
@@ -98,15 +107,26 @@ func WriteErrorResponse(w http.ResponseWriter, apierr APIError, tag string) {
 	WriteResponse(w, apierr.Code, nil, apierr, tag)
 }
 
+// write an error response with its structured cause
+func WriteDetailedErrorResponse(w http.ResponseWriter, apierr DetailedAPIError, tag string) {
+	WriteResponse(w, apierr.Code, nil, apierr, tag)
+}
+
 func RegisterRoutes(r *mux.Route,
 	componentClient storage.ComponentClient,
 	volumeClient storage.VolumeClient,
+	scheduleClient storage.ScheduleClient,
+	triggerClient storage.TriggerClient,
+	searchClient storage.SearchClient,
+	indexClient storage.IndexClient,
 	secretClient secret.SecretClient,
 	argoclient argoclient.Interface,
 	k8sclient kubernetes.Interface,
 	sec auth.AuthenticationClient,
 	authz auth.AuthorizationClient,
 	wsclient workspace.WorkspaceClient,
+	monitor jobmonitor.JobMonitor,
+	artifacts artifact.ArtifactClient,
 	namespace string) {
 
 	subrouter := r.Subrouter()
@@ -122,9 +142,13 @@ func RegisterRoutes(r *mux.Route,
 
 	// the following handlers below will use the authorized context's WorkspaceAccess
 	RegisterWorkflowRoutes(subrouter.PathPrefix(""), componentClient)
-	RegisterJobRoutes(subrouter.PathPrefix(""), componentClient, argoclient)
+	RegisterJobRoutes(subrouter.PathPrefix(""), componentClient, argoclient, k8sclient, monitor, artifacts)
 	RegisterSecretRoutes(subrouter.PathPrefix(""), secretClient, authz)
 	RegisterVolumeRoutes(subrouter.PathPrefix(""), volumeClient, authz)
+	RegisterScheduleRoutes(subrouter.PathPrefix(""), componentClient, scheduleClient, argoclient)
+	RegisterTriggerRoutes(subrouter.PathPrefix(""), componentClient, triggerClient, authz)
+	RegisterSearchRoutes(subrouter.PathPrefix(""), searchClient)
+	RegisterIndexRoutes(subrouter.PathPrefix(""), indexClient)
 
 }
 
@@ -0,0 +1,258 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/scheduler"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RegisterScheduleRoutes(r *mux.Route, componentClient storage.ComponentClient, scheduleClient storage.ScheduleClient, argoclient argoclient.Interface) {
+	s := r.PathPrefix("/schedules/").Subrouter()
+
+	const intype = "application/json"
+	const outtype = "application/json"
+	s.Use(CheckContentHeaderMiddleware(intype))
+	s.Use(CheckAcceptRequestHeaderMiddleware(outtype))
+	s.Use(SetContentTypeMiddleware(outtype))
+
+	s.HandleFunc("/", SchedulesListHandler(scheduleClient)).Methods(http.MethodGet)
+	s.HandleFunc("/", SchedulePostHandler(componentClient, scheduleClient, argoclient)).Methods(http.MethodPost)
+	s.HandleFunc("/{id}", ScheduleGetHandler(scheduleClient)).Methods(http.MethodGet)
+	s.HandleFunc("/{id}", ScheduleDeleteHandler(scheduleClient, argoclient)).Methods(http.MethodDelete)
+	s.HandleFunc("/{id}/suspend", ScheduleSuspendHandler(scheduleClient, argoclient)).Methods(http.MethodPost)
+	s.HandleFunc("/{id}/resume", ScheduleResumeHandler(scheduleClient, argoclient)).Methods(http.MethodPost)
+}
+
+func SchedulesListHandler(scheduleClient storage.ScheduleClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "listSchedules"
+		pagination, err := parseCursorPaginationOrDefault(r.URL.Query())
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", err.Error()}, opId)
+			return
+		}
+
+		list, err := scheduleClient.ListSchedules(r.Context(), pagination, r.URL.Query()["filter"], r.URL.Query()["sort"])
+		if err != nil {
+			var filterErr *storage.FilterError
+			if errors.As(err, &filterErr) {
+				WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid filter query", filterErr.Error()}, opId)
+				return
+			}
+			if errors.Is(err, storage.ErrInvalidCursor) {
+				WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid pagination cursor", err.Error()}, opId)
+				return
+			}
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not list schedules", err.Error()}, opId)
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, list, opId)
+	})
+}
+
+// get the schedule of the path, writes the error response if it cannot be read
+func getScheduleFromMuxerPath(w http.ResponseWriter, r *http.Request, scheduleClient storage.ScheduleClient, opId string) (models.Schedule, bool) {
+	id, err := getIdFromMuxerPath(r)
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+		return models.Schedule{}, false
+	}
+
+	schedule, err := scheduleClient.GetSchedule(r.Context(), id)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get schedule", err.Error()}, opId)
+		case storage.ErrNoAccess:
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "could not get schedule", err.Error()}, opId)
+		default:
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get schedule", err.Error()}, opId)
+		}
+		return models.Schedule{}, false
+	}
+	return schedule, true
+}
+
+func ScheduleGetHandler(scheduleClient storage.ScheduleClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "getSchedule"
+		schedule, ok := getScheduleFromMuxerPath(w, r, scheduleClient, opId)
+		if !ok {
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, schedule, opId)
+	})
+}
+
+func SchedulePostHandler(componentClient storage.ComponentClient, scheduleClient storage.ScheduleClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "createSchedule"
+		var request models.Schedule
+		if err := ReadBody(r, &request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, opId)
+			return
+		}
+
+		if !request.Uid.IsZero() {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "schedule id required to be zero", ""}, opId)
+			return
+		}
+		if _, err := cron.ParseStandard(request.Cron); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid cron expression", err.Error()}, opId)
+			return
+		}
+		if _, err := time.LoadLocation(request.Timezone); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid time zone", err.Error()}, opId)
+			return
+		}
+
+		wf, err := componentClient.GetWorkflow(r.Context(), request.Workflow)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get schedule workflow", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusBadRequest, "could not get schedule workflow", err.Error()}, opId)
+			}
+			return
+		}
+
+		schedule, argoWf, apiErr := RenderSchedule(r.Context(), componentClient, request, wf)
+		if apiErr != nil {
+			WriteDetailedErrorResponse(w, *apiErr, opId)
+			return
+		}
+
+		if err := scheduleClient.PutSchedule(r.Context(), schedule); err != nil {
+			switch err {
+			case storage.ErrNoAccess:
+				WriteErrorResponse(w, APIError{http.StatusForbidden, "could not store schedule", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not store schedule", err.Error()}, opId)
+			}
+			return
+		}
+
+		cronWf := scheduler.NewCronWorkflow(schedule, argoWf)
+		_, err = argoclient.ArgoprojV1alpha1().CronWorkflows(schedule.Workspace).Create(r.Context(), cronWf, metav1.CreateOptions{})
+		if err != nil {
+			// don't leave a schedule that never runs
+			if err := scheduleClient.DeleteSchedule(r.Context(), schedule.Uid); err != nil {
+				log.Error(errors.Wrapf(err, "cannot remove schedule %s", schedule.Uid.String()).Error())
+			}
+			WriteErrorResponse(w, APIError{createErrorCode(err), "cannot create the schedule", err.Error()}, opId)
+			return
+		}
+
+		location := map[string]string{"Location": path.Join("/api/v1/schedules/", schedule.Uid.String())}
+		WriteResponse(w, http.StatusCreated, location, nil, opId)
+	})
+}
+
+// Initializes the schedule and renders the job of its runs from the stored workflow, the workflow version and
+// the component versions are fixed when the schedule is created. Returns the schedule and the transpiled workflow
+func RenderSchedule(ctx context.Context, componentClient storage.ComponentClient, schedule models.Schedule, wf models.Workflow) (models.Schedule, *wfv1.Workflow, *DetailedAPIError) {
+	if err := InitializeMetadata(ctx, &schedule.Metadata); err != nil {
+		return models.Schedule{}, nil, &DetailedAPIError{APIError: APIError{http.StatusInternalServerError, "cannot initialize schedule", err.Error()}}
+	}
+	schedule.Type = "schedule"
+	schedule.Workspace = wf.Workspace
+	schedule.Workflow = models.CRefVersion{Uid: wf.Uid, Version: wf.Version.Current}
+
+	job := models.Job{
+		Metadata:    models.Metadata{Name: schedule.Name, Description: schedule.Description},
+		Type:        "job",
+		InputValues: schedule.InputValues,
+		Workflow:    wf,
+		Origin:      &models.JobOrigin{Uid: schedule.Uid, Type: models.JobOriginSchedule},
+	}
+	job, argoWf, apiErr := RenderJob(ctx, componentClient, job, models.JobPostOptions{})
+	if apiErr != nil {
+		return models.Schedule{}, nil, apiErr
+	}
+	// each run is stored as a job with its own uid
+	job.Uid = models.ComponentReference{}
+	schedule.Job = job
+
+	return schedule, argoWf, nil
+}
+
+func ScheduleDeleteHandler(scheduleClient storage.ScheduleClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "deleteSchedule"
+		schedule, ok := getScheduleFromMuxerPath(w, r, scheduleClient, opId)
+		if !ok {
+			return
+		}
+
+		// the jobs of earlier runs are kept
+		err := argoclient.ArgoprojV1alpha1().CronWorkflows(schedule.Workspace).Delete(r.Context(), schedule.Uid.String(), metav1.DeleteOptions{})
+		if err != nil && !apierr.IsNotFound(err) {
+			WriteErrorResponse(w, APIError{createErrorCode(err), "cannot delete the schedule", err.Error()}, opId)
+			return
+		}
+
+		if err := scheduleClient.DeleteSchedule(r.Context(), schedule.Uid); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not delete schedule", err.Error()}, opId)
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, schedule.Uid, opId)
+	})
+}
+
+func ScheduleSuspendHandler(scheduleClient storage.ScheduleClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ScheduleLikeSuspendHandler(w, r, scheduleClient, argoclient, true, "suspendSchedule")
+	})
+}
+
+func ScheduleResumeHandler(scheduleClient storage.ScheduleClient, argoclient argoclient.Interface) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ScheduleLikeSuspendHandler(w, r, scheduleClient, argoclient, false, "resumeSchedule")
+	})
+}
+
+// Suspends or resumes the cron workflow of a schedule, writes the updated schedule
+func ScheduleLikeSuspendHandler(w http.ResponseWriter, r *http.Request, scheduleClient storage.ScheduleClient, argoclient argoclient.Interface, suspend bool, opId string) {
+	schedule, ok := getScheduleFromMuxerPath(w, r, scheduleClient, opId)
+	if !ok {
+		return
+	}
+
+	cwfi := argoclient.ArgoprojV1alpha1().CronWorkflows(schedule.Workspace)
+	cronWf, err := cwfi.Get(r.Context(), schedule.Uid.String(), metav1.GetOptions{})
+	if err != nil {
+		WriteErrorResponse(w, APIError{http.StatusNotFound, "cannot get the schedule cron workflow", err.Error()}, opId)
+		return
+	}
+	cronWf.Spec.Suspend = suspend
+	if _, err := cwfi.Update(r.Context(), cronWf, metav1.UpdateOptions{}); err != nil {
+		WriteErrorResponse(w, APIError{createErrorCode(err), "cannot update the schedule cron workflow", err.Error()}, opId)
+		return
+	}
+
+	schedule.Suspended = suspend
+	TouchMetadata(r.Context(), &schedule.Metadata)
+	if err := scheduleClient.PutSchedule(r.Context(), schedule); err != nil {
+		WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not store schedule", err.Error()}, opId)
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, nil, schedule, opId)
+}
@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/scheduler"
+	"github.com/equinor/flowify-workflows-server/storage"
+	gmux "github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktesting "k8s.io/client-go/testing"
+)
+
+// implement a mock schedule client
+type mockScheduleClient struct {
+	mock.Mock
+}
+
+func (c *mockScheduleClient) ListSchedules(ctx context.Context, pagination storage.Pagination, filters []string, sorts []string) (models.ScheduleList, error) {
+	args := c.Called(ctx, pagination, filters, sorts)
+	return args.Get(0).(models.ScheduleList), args.Error(1)
+}
+
+func (c *mockScheduleClient) GetSchedule(ctx context.Context, id models.ComponentReference) (models.Schedule, error) {
+	args := c.Called(ctx, id)
+	return args.Get(0).(models.Schedule), args.Error(1)
+}
+
+func (c *mockScheduleClient) PutSchedule(ctx context.Context, schedule models.Schedule) error {
+	args := c.Called(ctx, schedule)
+	return args.Error(0)
+}
+
+func (c *mockScheduleClient) DeleteSchedule(ctx context.Context, id models.ComponentReference) error {
+	args := c.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (c *mockScheduleClient) CreateScheduledJob(ctx context.Context, scheduleId models.ComponentReference, jobId models.ComponentReference) (models.Job, error) {
+	args := c.Called(ctx, scheduleId, jobId)
+	return args.Get(0).(models.Job), args.Error(1)
+}
+
+func Test_SchedulePostHTTPHandler(t *testing.T) {
+	client := NewMockClient()
+	wfId := models.NewReference("9b75d74e-681c-496a-bc43-496a798b9fc8")
+	client.On("GetWorkflow", mock.Anything, models.CRefVersion{Uid: wfId}).Return(
+		models.Workflow{Metadata: models.Metadata{Uid: wfId, Name: "test-wf", Version: models.Version{Current: models.VersionNumber(3)}},
+			Component: models.Component{ComponentBase: models.ComponentBase{Type: "component", Metadata: models.Metadata{Name: "test-comp", Uid: models.NewComponentReference()}},
+				Implementation: models.Brick{ImplementationBase: models.ImplementationBase{Type: models.BrickType},
+					Container: &corev1.Container{Name: "containername", Image: "docker/whalesay", Command: []string{"cowsay"}}}},
+			Type:      "workflow",
+			Workspace: "test"},
+		nil)
+	client.On("GetWorkflow", mock.Anything, mock.Anything).Return(models.Workflow{}, storage.ErrNotFound)
+
+	schedules := &mockScheduleClient{}
+	var stored models.Schedule
+	schedules.On("PutSchedule", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { stored = args.Get(1).(models.Schedule) }).Return(nil)
+	schedules.On("DeleteSchedule", mock.Anything, mock.Anything).Return(nil)
+
+	argoClientSet := fake.NewSimpleClientset()
+	failCreate := false
+	argoClientSet.PrependReactor("create", "cronworkflows", func(action ktesting.Action) (bool, runtime.Object, error) {
+		if failCreate {
+			return true, nil, fmt.Errorf("cluster unavailable")
+		}
+		return false, nil, nil
+	})
+	mux := gmux.NewRouter()
+	RegisterScheduleRoutes(mux.PathPrefix("/api/v1"), client, schedules, argoClientSet)
+
+	request := func(cron string, workflow models.ComponentReference) []byte {
+		return []byte(fmt.Sprintf(`{"name": "nightly", "workflow": {"uid": "%s"}, "cron": "%s", "timezone": "Europe/Oslo"}`, workflow.String(), cron))
+	}
+
+	testcases := []struct {
+		Name         string
+		Body         []byte
+		FailCreate   bool
+		ExpectedCode int
+	}{
+		{Name: "create schedule", Body: request("0 2 * * *", wfId), ExpectedCode: http.StatusCreated},
+		{Name: "bad cron", Body: request("0 25 * * *", wfId), ExpectedCode: http.StatusBadRequest},
+		{Name: "bad body", Body: []byte(`{"name": "nightly", "cron": "@daily"}`), ExpectedCode: http.StatusBadRequest},
+		{Name: "missing workflow", Body: request("@daily", models.NewComponentReference()), ExpectedCode: http.StatusNotFound},
+		{Name: "cron create fails", Body: request("@daily", wfId), FailCreate: true, ExpectedCode: http.StatusBadRequest},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			failCreate = test.FailCreate
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/schedules/", bytes.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			payload, err := io.ReadAll(w.Result().Body)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedCode, w.Code, string(payload))
+		})
+	}
+
+	// the stored schedule pins the workflow version, the runs are stored from its job
+	require.Equal(t, models.CRefVersion{Uid: wfId, Version: models.VersionNumber(3)}, stored.Workflow)
+	require.Equal(t, "test", stored.Workspace)
+	require.Equal(t, "nightly", stored.Job.Name)
+	require.True(t, stored.Job.Uid.IsZero())
+	require.Equal(t, &models.JobOrigin{Uid: stored.Uid, Type: models.JobOriginSchedule}, stored.Job.Origin)
+
+	// only the last schedule was removed again
+	schedules.AssertNumberOfCalls(t, "DeleteSchedule", 1)
+	schedules.AssertCalled(t, "DeleteSchedule", mock.Anything, stored.Uid)
+
+	crons, err := argoClientSet.ArgoprojV1alpha1().CronWorkflows("test").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, crons.Items, 1)
+	cron := crons.Items[0]
+	require.Equal(t, "0 2 * * *", cron.Spec.Schedule)
+	require.Equal(t, "Europe/Oslo", cron.Spec.Timezone)
+	require.True(t, *cron.Spec.WorkflowSpec.Suspend)
+	require.Equal(t, cron.Name, cron.Spec.WorkflowMetadata.Labels[scheduler.ScheduleLabel])
+	require.Equal(t, cron.Name, cron.Spec.WorkflowMetadata.Annotations[originAnnotation])
+}
+
+func Test_ScheduleSuspendResumeHTTPHandler(t *testing.T) {
+	id := models.NewComponentReference()
+	schedule := models.Schedule{Metadata: models.Metadata{Uid: id, Name: "nightly"}, Type: "schedule", Workspace: "test", Cron: "@daily"}
+	cron := &v1alpha1.CronWorkflow{ObjectMeta: metav1.ObjectMeta{Name: id.String(), Namespace: "test"}, Spec: v1alpha1.CronWorkflowSpec{Schedule: "@daily"}}
+
+	schedules := &mockScheduleClient{}
+	schedules.On("GetSchedule", mock.Anything, id).Return(schedule, nil)
+	schedules.On("GetSchedule", mock.Anything, mock.Anything).Return(models.Schedule{}, storage.ErrNotFound)
+	schedules.On("PutSchedule", mock.Anything, mock.Anything).Return(nil)
+	schedules.On("DeleteSchedule", mock.Anything, id).Return(nil)
+
+	argoClientSet := fake.NewSimpleClientset(cron)
+	mux := gmux.NewRouter()
+	RegisterScheduleRoutes(mux.PathPrefix("/api/v1"), NewMockClient(), schedules, argoClientSet)
+
+	testcases := []struct {
+		Name             string
+		Method           string
+		URL              string
+		ExpectedCode     int
+		ExpectedSuspend  bool
+		ExpectedDeletion bool
+	}{
+		{Name: "suspend", Method: http.MethodPost, URL: "/api/v1/schedules/" + id.String() + "/suspend", ExpectedCode: http.StatusOK, ExpectedSuspend: true},
+		{Name: "resume", Method: http.MethodPost, URL: "/api/v1/schedules/" + id.String() + "/resume", ExpectedCode: http.StatusOK, ExpectedSuspend: false},
+		{Name: "suspend missing", Method: http.MethodPost, URL: "/api/v1/schedules/" + models.NewComponentReference().String() + "/suspend", ExpectedCode: http.StatusNotFound},
+		{Name: "delete", Method: http.MethodDelete, URL: "/api/v1/schedules/" + id.String(), ExpectedCode: http.StatusOK, ExpectedDeletion: true},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(test.Method, test.URL, nil)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			payload, err := io.ReadAll(w.Result().Body)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedCode, w.Code, string(payload))
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			stored, err := argoClientSet.ArgoprojV1alpha1().CronWorkflows("test").Get(context.TODO(), id.String(), metav1.GetOptions{})
+			if test.ExpectedDeletion {
+				require.Error(t, err)
+				schedules.AssertCalled(t, "DeleteSchedule", mock.Anything, id)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedSuspend, stored.Spec.Suspend)
+
+			var result models.Schedule
+			require.NoError(t, json.Unmarshal(payload, &result))
+			require.Equal(t, test.ExpectedSuspend, result.Suspended)
+		})
+	}
+}
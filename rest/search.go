@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/gorilla/mux"
+)
+
+func RegisterSearchRoutes(r *mux.Route, client storage.SearchClient) {
+	s := r.Subrouter()
+
+	const intype = "application/json"
+	const outtype = "application/json"
+
+	s.Use(CheckContentHeaderMiddleware(intype))
+	s.Use(CheckAcceptRequestHeaderMiddleware(outtype))
+	s.Use(SetContentTypeMiddleware(outtype))
+
+	s.HandleFunc("/search/", SearchHandler(client)).Methods(http.MethodGet)
+}
+
+// Searches the latest versions of the components and the workflows in the workspaces accessible to the user
+func SearchHandler(client storage.SearchClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "search"
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", "missing search query 'q'"}, opId)
+			return
+		}
+
+		pagination, err := parsePaginationsOrDefault(r.URL.Query()["limit"], r.URL.Query()["offset"])
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", err.Error()}, opId)
+			return
+		}
+
+		results, err := client.Search(r.Context(), query, pagination)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not search", err.Error()}, opId)
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, results, opId)
+	})
+}
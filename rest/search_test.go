@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	gmux "github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SearchHTTPHandler(t *testing.T) {
+	workflow := makeTriggerWorkflow(models.NewComponentReference(), "test")
+	other := makeTriggerWorkflow(models.NewComponentReference(), "other")
+	component := workflow.Component
+	component.Name = "whalesay"
+
+	client := storage.NewLocalSearchClient([]models.Component{component}, []models.Workflow{workflow, other})
+	mux := gmux.NewRouter()
+	RegisterSearchRoutes(mux.PathPrefix("/api/v1"), client)
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		ctx := user.UserContext(user.MockUser{Uid: "0", Roles: []user.Role{"tester"}}, req.Context())
+		ctx = context.WithValue(ctx, workspace.WorkspaceKey, []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}})
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req.WithContext(ctx))
+		return w
+	}
+
+	{
+		w := serve("/api/v1/search/?q=whale")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list models.SearchResultList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Equal(t, 2, list.PageInfo.TotalNumber)
+		require.Len(t, list.Items, 2)
+		require.Equal(t, component.Uid, list.Items[0].Uid, "the name outranks the image")
+		require.Equal(t, models.SearchKindComponent, list.Items[0].Kind)
+		require.Equal(t, workflow.Uid, list.Items[1].Uid, "the workflow of the other workspace is hidden")
+		require.Equal(t, models.SearchKindWorkflow, list.Items[1].Kind)
+		require.Equal(t, []models.SearchHighlight{{Field: "component.implementation.container.image", Fragment: "docker/<em>whale</em>say"}}, list.Items[1].Highlights)
+	}
+
+	testcases := []struct {
+		Name         string
+		URL          string
+		ExpectedCode int
+	}{
+		{"no query", "/api/v1/search/", http.StatusBadRequest},
+		{"blank query", "/api/v1/search/?q=%20", http.StatusBadRequest},
+		{"bad limit", "/api/v1/search/?q=whale&limit=-1", http.StatusBadRequest},
+		{"no match", "/api/v1/search/?q=narwhal", http.StatusOK},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			w := serve(test.URL)
+			require.Equal(t, test.ExpectedCode, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package rest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	argoclient "github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned"
+	"github.com/equinor/flowify-workflows-server/auth"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const (
+	// the largest webhook payload read
+	maxWebhookPayload = 1 << 20
+	// the role of the user submitting the jobs of webhook calls
+	webhookRole user.Role = "flowify-webhook"
+)
+
+func TriggersPathAuthorization(action auth.Action, authz auth.AuthorizationClient, next http.HandlerFunc) http.HandlerFunc {
+	return PathAuthorization(auth.Triggers, action, "workspace", authz, next)
+}
+
+func RegisterTriggerRoutes(r *mux.Route, componentClient storage.ComponentClient, client storage.TriggerClient, authz auth.AuthorizationClient) {
+	s := r.Subrouter()
+
+	const intype = "application/json"
+	const outtype = "application/json"
+
+	s.Use(CheckContentHeaderMiddleware(intype))
+	s.Use(CheckAcceptRequestHeaderMiddleware(outtype))
+	s.Use(SetContentTypeMiddleware(outtype))
+
+	s.HandleFunc("/triggers/{workspace}/", TriggersPathAuthorization(auth.List, authz, TriggersListHandler(client, authz))).Methods(http.MethodGet)
+	s.HandleFunc("/triggers/{workspace}/", TriggersPathAuthorization(auth.Write, authz, TriggerPostHandler(componentClient, client))).Methods(http.MethodPost)
+	s.HandleFunc("/triggers/{workspace}/{id}", TriggersPathAuthorization(auth.Read, authz, TriggerGetHandler(client, authz))).Methods(http.MethodGet)
+	s.HandleFunc("/triggers/{workspace}/{id}", TriggersPathAuthorization(auth.Write, authz, TriggerPutHandler(componentClient, client))).Methods(http.MethodPut)
+	s.HandleFunc("/triggers/{workspace}/{id}", TriggersPathAuthorization(auth.Delete, authz, TriggerDeleteHandler(client))).Methods(http.MethodDelete)
+}
+
+// The webhooks are called without authentication, each call is authorized by the signature of its url
+func RegisterWebhookRoutes(r *mux.Route, componentClient storage.ComponentClient, client storage.TriggerClient, argoclient argoclient.Interface, wsclient workspace.WorkspaceClient, monitor jobmonitor.JobMonitor) {
+	s := r.Subrouter()
+
+	const intype = "application/json"
+	const outtype = "application/json"
+
+	s.Use(CheckContentHeaderMiddleware(intype))
+	s.Use(CheckAcceptRequestHeaderMiddleware(outtype))
+	s.Use(SetContentTypeMiddleware(outtype))
+
+	s.HandleFunc("/webhooks/{id}/{signature}", WebhookHandler(componentClient, client, argoclient, wsclient, monitor)).Methods(http.MethodPost)
+}
+
+// WebhookSignature signs the webhook of a trigger with its secret
+func WebhookSignature(trigger models.Trigger) string {
+	h := hmac.New(sha256.New, []byte(trigger.Secret))
+	h.Write([]byte(trigger.Uid.String()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func webhookPath(trigger models.Trigger) string {
+	return path.Join("/api/v1/webhooks/", trigger.Uid.String(), WebhookSignature(trigger))
+}
+
+func newTriggerSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "cannot generate trigger secret")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// only workspace admins get the webhook url
+func withWebhook(r *http.Request, authz auth.AuthorizationClient, trigger models.Trigger) models.Trigger {
+	if admin, err := authz.Authorize(auth.Triggers, auth.Write, user.GetUser(r.Context()), trigger.Workspace); err == nil && admin {
+		trigger.Webhook = webhookPath(trigger)
+	}
+	return trigger
+}
+
+// get the stored workflow of a trigger, writes the error response if it cannot be read or is in another workspace
+func getTriggerWorkflow(w http.ResponseWriter, r *http.Request, componentClient storage.ComponentClient, trigger models.Trigger, opId string) (models.Workflow, bool) {
+	wf, err := componentClient.GetWorkflow(r.Context(), trigger.Workflow)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get trigger workflow", err.Error()}, opId)
+		default:
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "could not get trigger workflow", err.Error()}, opId)
+		}
+		return models.Workflow{}, false
+	}
+	if wf.Workspace != trigger.Workspace {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, "trigger workflow workspace mismatch", fmt.Sprintf("workflow %s is in workspace %s", wf.Uid.String(), wf.Workspace)}, opId)
+		return models.Workflow{}, false
+	}
+	return wf, true
+}
+
+func TriggersListHandler(client storage.TriggerClient, authz auth.AuthorizationClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "listTriggers"
+		ws := mux.Vars(r)["workspace"]
+
+		pagination, err := parsePaginationsOrDefault(r.URL.Query()["limit"], r.URL.Query()["offset"])
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "error parsing query parameters", err.Error()}, opId)
+			return
+		}
+
+		// the endpoint doesnt do ws-filtering, need to inject here
+		wsFilter := fmt.Sprintf("workspace[==]=%s", ws)
+		list, err := client.ListTriggers(r.Context(), pagination, []string{wsFilter}, []string{})
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not list triggers in workspace", err.Error()}, opId)
+			return
+		}
+		for i, t := range list.Items {
+			list.Items[i] = withWebhook(r, authz, t)
+		}
+
+		WriteResponse(w, http.StatusOK, nil, list, opId)
+	})
+}
+
+func TriggerGetHandler(client storage.TriggerClient, authz auth.AuthorizationClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "getTrigger"
+		ws := mux.Vars(r)["workspace"]
+
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+			return
+		}
+
+		trigger, err := client.GetTrigger(r.Context(), id)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get trigger", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get trigger", err.Error()}, opId)
+			}
+			return
+		}
+
+		if trigger.Workspace != ws {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get trigger", "no such trigger in workspace"}, opId)
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, withWebhook(r, authz, trigger), opId)
+	})
+}
+
+func TriggerPostHandler(componentClient storage.ComponentClient, client storage.TriggerClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "postTrigger"
+		ws := mux.Vars(r)["workspace"]
+
+		var request models.Trigger
+		if err := ReadBody(r, &request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, opId)
+			return
+		}
+
+		if !request.Uid.IsZero() {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "trigger id required to be zero", ""}, opId)
+			return
+		}
+
+		if request.Workspace != ws {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "trigger workspace mismatch", ""}, opId)
+			return
+		}
+
+		if _, ok := getTriggerWorkflow(w, r, componentClient, request, opId); !ok {
+			return
+		}
+
+		if err := InitializeMetadata(r.Context(), &request.Metadata); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "cannot initialize trigger", err.Error()}, opId)
+			return
+		}
+		request.Type = "trigger"
+		request.Webhook = ""
+		secret, err := newTriggerSecret()
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "cannot initialize trigger", err.Error()}, opId)
+			return
+		}
+		request.Secret = secret
+
+		if err := client.PutTrigger(r.Context(), request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "could not put trigger", err.Error()}, opId)
+			return
+		}
+
+		location := map[string]string{"Location": path.Join(r.URL.RequestURI(), request.Uid.String())}
+		WriteResponse(w, http.StatusCreated, location, nil, opId)
+	})
+}
+
+func TriggerPutHandler(componentClient storage.ComponentClient, client storage.TriggerClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "putTrigger"
+		ws := mux.Vars(r)["workspace"]
+
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+			return
+		}
+
+		var request models.Trigger
+		if err := ReadBody(r, &request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read trigger put request body", err.Error()}, opId)
+			return
+		}
+
+		if request.Workspace != ws {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "request workspace does not match path parameter", ""}, opId)
+			return
+		}
+
+		if request.Uid != id {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "request id required to match body", ""}, opId)
+			return
+		}
+
+		stored, err := client.GetTrigger(r.Context(), id)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not get trigger", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get trigger", err.Error()}, opId)
+			}
+			return
+		}
+		if stored.Workspace != ws {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "triggers cannot be moved between workspaces", ""}, opId)
+			return
+		}
+
+		if _, ok := getTriggerWorkflow(w, r, componentClient, request, opId); !ok {
+			return
+		}
+
+		// the webhook url stays valid
+		request.Type = "trigger"
+		request.Version = stored.Version
+		request.Secret = stored.Secret
+		request.Webhook = ""
+		TouchMetadata(r.Context(), &request.Metadata)
+
+		if err := client.PutTrigger(r.Context(), request); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "could not put trigger", err.Error()}, opId)
+			return
+		}
+
+		// update
+		WriteResponse(w, http.StatusNoContent, nil, nil, opId)
+	})
+}
+
+func TriggerDeleteHandler(client storage.TriggerClient) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "deleteTrigger"
+		ws := mux.Vars(r)["workspace"]
+
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+			return
+		}
+
+		// the path is authorized for its workspace, the trigger has to be in it
+		trigger, err := client.GetTrigger(r.Context(), id)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not delete trigger", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not delete trigger", err.Error()}, opId)
+			}
+			return
+		}
+		if trigger.Workspace != ws {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "could not delete trigger", "no such trigger in workspace"}, opId)
+			return
+		}
+
+		err = client.DeleteTrigger(r.Context(), id)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "could not delete trigger", err.Error()}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not delete trigger", err.Error()}, opId)
+			}
+			return
+		}
+
+		WriteResponse(w, http.StatusOK, nil, nil, opId)
+	})
+}
+
+// the user submitting the jobs of the webhook calls of a trigger
+type webhookUser struct {
+	trigger models.Trigger
+}
+
+func (u webhookUser) GetUid() string        { return u.trigger.Uid.String() }
+func (u webhookUser) GetName() string       { return u.trigger.Name }
+func (u webhookUser) GetEmail() string      { return "" }
+func (u webhookUser) GetRoles() []user.Role { return []user.Role{webhookRole} }
+
+// webhookContext returns a context with access to the workspace of the trigger only, as the webhook user
+func webhookContext(ctx context.Context, trigger models.Trigger, ws workspace.Workspace) context.Context {
+	ws.Roles = [][]user.Role{{webhookRole}}
+	ctx = user.UserContext(webhookUser{trigger: trigger}, ctx)
+	return context.WithValue(ctx, workspace.WorkspaceKey, []workspace.Workspace{ws})
+}
+
+func WebhookHandler(componentClient storage.ComponentClient, client storage.TriggerClient, argoclient argoclient.Interface, wsclient workspace.WorkspaceClient, monitor jobmonitor.JobMonitor) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const opId = "callWebhook"
+		id, err := getIdFromMuxerPath(r)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, err.Error(), ""}, opId)
+			return
+		}
+
+		trigger, err := client.GetWebhookTrigger(r.Context(), id)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				WriteErrorResponse(w, APIError{http.StatusNotFound, "no such webhook", ""}, opId)
+			default:
+				WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not get trigger", err.Error()}, opId)
+			}
+			return
+		}
+
+		signature, err := hex.DecodeString(mux.Vars(r)["signature"])
+		expected, _ := hex.DecodeString(WebhookSignature(trigger))
+		if err != nil || !hmac.Equal(signature, expected) {
+			WriteErrorResponse(w, APIError{http.StatusForbidden, "invalid webhook signature", ""}, opId)
+			return
+		}
+
+		var ws *workspace.Workspace
+		for _, item := range wsclient.ListWorkspaces() {
+			if item.Name == trigger.Workspace {
+				ws = &item
+				break
+			}
+		}
+		if ws == nil {
+			WriteErrorResponse(w, APIError{http.StatusNotFound, "no such webhook", fmt.Sprintf("workspace %s not found", trigger.Workspace)}, opId)
+			return
+		}
+
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read webhook payload", err.Error()}, opId)
+			return
+		}
+		values, err := trigger.JobInputValues(payload)
+		if err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot map webhook payload", err.Error()}, opId)
+			return
+		}
+
+		r = r.WithContext(webhookContext(r.Context(), trigger, *ws))
+		wf, ok := getTriggerWorkflow(w, r, componentClient, trigger, opId)
+		if !ok {
+			return
+		}
+
+		job := models.Job{
+			Metadata:    models.Metadata{Name: trigger.Name, Description: trigger.Description},
+			Type:        "job",
+			InputValues: values,
+			Workflow:    wf,
+			Origin:      &models.JobOrigin{Uid: trigger.Uid, Type: models.JobOriginTrigger},
+		}
+		JobLikeSubmitHandler(w, r, componentClient, argoclient, monitor, job, models.JobPostOptions{}, opId)
+	})
+}
@@ -0,0 +1,213 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj/argo-workflows/v3/pkg/client/clientset/versioned/fake"
+	"github.com/equinor/flowify-workflows-server/auth"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/jobmonitor"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	gmux "github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// implement a mock trigger client
+type mockTriggerClient struct {
+	mock.Mock
+}
+
+func (c *mockTriggerClient) ListTriggers(ctx context.Context, pagination storage.Pagination, filters []string, sorts []string) (models.TriggerList, error) {
+	args := c.Called(ctx, pagination, filters, sorts)
+	return args.Get(0).(models.TriggerList), args.Error(1)
+}
+
+func (c *mockTriggerClient) GetTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error) {
+	args := c.Called(ctx, id)
+	return args.Get(0).(models.Trigger), args.Error(1)
+}
+
+func (c *mockTriggerClient) PutTrigger(ctx context.Context, trigger models.Trigger) error {
+	args := c.Called(ctx, trigger)
+	return args.Error(0)
+}
+
+func (c *mockTriggerClient) DeleteTrigger(ctx context.Context, id models.ComponentReference) error {
+	args := c.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (c *mockTriggerClient) GetWebhookTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error) {
+	args := c.Called(ctx, id)
+	return args.Get(0).(models.Trigger), args.Error(1)
+}
+
+// grants the actions of the user's role
+type mockTriggerAuthorization struct {
+	Permissions map[user.Role]map[auth.Action]bool
+}
+
+func (m mockTriggerAuthorization) Authorize(subject auth.Subject, action auth.Action, usr user.User, object any) (bool, error) {
+	for _, role := range usr.GetRoles() {
+		if m.Permissions[role][action] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func makeTriggerWorkflow(uid models.ComponentReference, workspace string) models.Workflow {
+	return models.Workflow{Metadata: models.Metadata{Uid: uid, Name: "test-wf", Version: models.Version{Current: models.VersionNumber(1)}},
+		Component: models.Component{ComponentBase: models.ComponentBase{Type: "component", Metadata: models.Metadata{Uid: models.NewComponentReference()},
+			Inputs: []models.Data{{Name: "blob", Type: "parameter"}}},
+			Implementation: models.Brick{ImplementationBase: models.ImplementationBase{Type: models.BrickType},
+				Container: &corev1.Container{Name: "containername", Image: "docker/whalesay", Command: []string{"cowsay"}}}},
+		Type:      "workflow",
+		Workspace: workspace}
+}
+
+func Test_TriggerHTTPHandler(t *testing.T) {
+	wfId := models.NewComponentReference()
+	otherWfId := models.NewComponentReference()
+	client := NewMockClient()
+	client.On("GetWorkflow", mock.Anything, models.CRefVersion{Uid: wfId}).Return(makeTriggerWorkflow(wfId, "test"), nil)
+	client.On("GetWorkflow", mock.Anything, models.CRefVersion{Uid: otherWfId}).Return(makeTriggerWorkflow(otherWfId, "other"), nil)
+
+	stored := models.Trigger{Metadata: models.Metadata{Uid: models.NewComponentReference(), Name: "landing"}, Type: "trigger", Workspace: "test", Workflow: models.CRefVersion{Uid: wfId}, Secret: "secret"}
+	triggers := &mockTriggerClient{}
+	var put models.Trigger
+	triggers.On("PutTrigger", mock.Anything, mock.Anything).Run(func(args mock.Arguments) { put = args.Get(1).(models.Trigger) }).Return(nil)
+	triggers.On("GetTrigger", mock.Anything, stored.Uid).Return(stored, nil)
+	triggers.On("GetTrigger", mock.Anything, mock.Anything).Return(models.Trigger{}, storage.ErrNotFound)
+	triggers.On("DeleteTrigger", mock.Anything, stored.Uid).Return(nil)
+
+	authz := mockTriggerAuthorization{Permissions: map[user.Role]map[auth.Action]bool{
+		"admin": {auth.Read: true, auth.List: true, auth.Write: true, auth.Delete: true},
+		"user":  {auth.Read: true, auth.List: true},
+	}}
+	mux := gmux.NewRouter()
+	RegisterTriggerRoutes(mux.PathPrefix("/api/v1"), client, triggers, authz)
+
+	serve := func(method string, url string, body string, role user.Role) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(user.UserContext(user.MockUser{Uid: "0", Roles: []user.Role{role}}, req.Context()))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	{
+		w := serve(http.MethodPost, "/api/v1/triggers/test/", `{"name": "landing", "workspace": "test", "workflow": {"uid": "`+wfId.String()+`"}, "mappings": [{"target": "blob", "path": "data.url"}]}`, "admin")
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.Equal(t, "/api/v1/triggers/test/"+put.Uid.String(), w.Header().Get("Location"))
+		require.Len(t, put.Secret, 64, "a secret is generated")
+		require.Equal(t, models.ComponentType("trigger"), put.Type)
+	}
+
+	testcases := []struct {
+		Name         string
+		Method       string
+		URL          string
+		Body         string
+		Role         user.Role
+		ExpectedCode int
+	}{
+		{"post as user", http.MethodPost, "/api/v1/triggers/test/", `{"workspace": "test", "workflow": {"uid": "` + wfId.String() + `"}}`, "user", http.StatusUnauthorized},
+		{"post workflow of other workspace", http.MethodPost, "/api/v1/triggers/test/", `{"workspace": "test", "workflow": {"uid": "` + otherWfId.String() + `"}}`, "admin", http.StatusBadRequest},
+		{"post with secret", http.MethodPost, "/api/v1/triggers/test/", `{"workspace": "test", "workflow": {"uid": "` + wfId.String() + `"}, "secret": "mine"}`, "admin", http.StatusBadRequest},
+		{"put keeps secret", http.MethodPut, "/api/v1/triggers/test/" + stored.Uid.String(), `{"uid": "` + stored.Uid.String() + `", "name": "renamed", "workspace": "test", "workflow": {"uid": "` + wfId.String() + `"}}`, "admin", http.StatusNoContent},
+		{"delete from other workspace", http.MethodDelete, "/api/v1/triggers/other/" + stored.Uid.String(), "", "admin", http.StatusNotFound},
+		{"delete unknown", http.MethodDelete, "/api/v1/triggers/test/" + models.NewComponentReference().String(), "", "admin", http.StatusNotFound},
+		{"delete as user", http.MethodDelete, "/api/v1/triggers/test/" + stored.Uid.String(), "", "user", http.StatusUnauthorized},
+		{"delete", http.MethodDelete, "/api/v1/triggers/test/" + stored.Uid.String(), "", "admin", http.StatusOK},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			w := serve(test.Method, test.URL, test.Body, test.Role)
+			require.Equal(t, test.ExpectedCode, w.Code, w.Body.String())
+		})
+	}
+	triggers.AssertNumberOfCalls(t, "DeleteTrigger", 1)
+	require.Equal(t, "renamed", put.Name)
+	require.Equal(t, "secret", put.Secret)
+
+	// only admins see the webhook url
+	for role, expected := range map[user.Role]string{"admin": webhookPath(stored), "user": ""} {
+		w := serve(http.MethodGet, "/api/v1/triggers/test/"+stored.Uid.String(), "", role)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var trigger models.Trigger
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trigger))
+		require.Equal(t, expected, trigger.Webhook)
+		require.NotContains(t, w.Body.String(), "secret")
+	}
+}
+
+func Test_WebhookHTTPHandler(t *testing.T) {
+	wfId := models.NewComponentReference()
+	trigger := models.Trigger{Metadata: models.Metadata{Uid: models.NewComponentReference(), Name: "landing"}, Type: "trigger", Workspace: "test",
+		Workflow: models.CRefVersion{Uid: wfId}, Secret: "secret", Mappings: []models.TriggerMapping{{Target: "blob", Path: "data.url"}}}
+
+	// the webhook user has access to the workspace of the trigger only
+	hasAccess := mock.MatchedBy(func(ctx context.Context) bool {
+		return storage.CheckWorkspaceAccess(ctx, "test") && !storage.CheckWorkspaceAccess(ctx, "other")
+	})
+	client := NewMockClient()
+	client.On("GetWorkflow", hasAccess, models.CRefVersion{Uid: wfId}).Return(makeTriggerWorkflow(wfId, "test"), nil)
+	client.On("CreateJob", hasAccess, mock.MatchedBy(func(job models.Job) bool {
+		return job.Name == "landing" && job.Origin.Uid == trigger.Uid && job.Origin.Type == models.JobOriginTrigger &&
+			len(job.InputValues) == 1 && job.InputValues[0].Value == "https://blob/2022.csv"
+	})).Return(nil)
+
+	triggers := &mockTriggerClient{}
+	triggers.On("GetWebhookTrigger", mock.Anything, trigger.Uid).Return(trigger, nil)
+	triggers.On("GetWebhookTrigger", mock.Anything, mock.Anything).Return(models.Trigger{}, storage.ErrNotFound)
+
+	wsclient := NewMockWorkspaceClient()
+	wsclient.On("ListWorkspaces").Return([]workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}, {Name: "other"}})
+
+	argoClientSet := fake.NewSimpleClientset()
+	argoClientSet.PrependReactor("create", "workflows", UIDReactor)
+	mux := gmux.NewRouter()
+	RegisterWebhookRoutes(mux.PathPrefix("/api/v1"), client, triggers, argoClientSet, wsclient, jobmonitor.NewDefaultJobMonitorMock())
+
+	other := trigger
+	other.Secret = "other"
+	testcases := []struct {
+		Name         string
+		URL          string
+		Body         string
+		ExpectedCode int
+	}{
+		{"call webhook", webhookPath(trigger), `{"data": {"url": "https://blob/2022.csv"}}`, http.StatusCreated},
+		{"bad signature", webhookPath(other), `{"data": {"url": "https://blob/2022.csv"}}`, http.StatusForbidden},
+		{"malformed signature", "/api/v1/webhooks/" + trigger.Uid.String() + "/xyz", `{}`, http.StatusForbidden},
+		{"unknown trigger", "/api/v1/webhooks/" + models.NewComponentReference().String() + "/00", `{}`, http.StatusNotFound},
+		{"unmapped payload", webhookPath(trigger), `{"data": {}}`, http.StatusBadRequest},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.URL, bytes.NewReader([]byte(test.Body)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			payload, err := io.ReadAll(w.Result().Body)
+			require.NoError(t, err)
+			require.Equal(t, test.ExpectedCode, w.Code, string(payload))
+		})
+	}
+	client.AssertNumberOfCalls(t, "CreateJob", 1)
+}
@@ -112,6 +112,14 @@ func WorkflowPutHandler(componentClient storage.ComponentClient) http.HandlerFun
 			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "putWorkflow")
 			return
 		}
+		if err := request.Workflow.ValidateOnExit(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid workflow exit handler", err.Error()}, "putWorkflow")
+			return
+		}
+		if err := request.Workflow.ValidateExpressions(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "putWorkflow")
+			return
+		}
 
 		id, err := uuid.Parse(mux.Vars(r)["id"])
 		if err != nil {
@@ -127,6 +135,10 @@ func WorkflowPutHandler(componentClient storage.ComponentClient) http.HandlerFun
 
 		err = PutWorkflow(r.Context(), componentClient, request.Workflow)
 		if err != nil {
+			if errors.Is(err, storage.ErrNewerDocumentExists) {
+				WriteErrorResponse(w, APIError{http.StatusConflict, "a concurrent put stored the same version", ""}, "putWorkflow")
+				return
+			}
 			log.Error(errors.Wrapf(err, "cannot put %s", request.Workflow.Uid.String()).Error())
 			WriteErrorResponse(w, APIError{http.StatusInternalServerError, "could not handle put request", ""}, "putWorkflow")
 			return
@@ -144,6 +156,14 @@ func WorkflowPatchHandler(componentClient storage.ComponentClient) http.HandlerF
 			WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "patchWorkflow")
 			return
 		}
+		if err := request.Workflow.ValidateOnExit(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid workflow exit handler", err.Error()}, "patchWorkflow")
+			return
+		}
+		if err := request.Workflow.ValidateExpressions(); err != nil {
+			WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "patchWorkflow")
+			return
+		}
 
 		id, err := getIdFromMuxerPath(r)
 		if err != nil {
@@ -175,6 +195,14 @@ func WorkflowPostHandler(w http.ResponseWriter, r *http.Request, client storage.
 		WriteErrorResponse(w, APIError{http.StatusBadRequest, "cannot read request", err.Error()}, "postWorkflow")
 		return
 	}
+	if err := request.Workflow.ValidateOnExit(); err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid workflow exit handler", err.Error()}, "postWorkflow")
+		return
+	}
+	if err := request.Workflow.ValidateExpressions(); err != nil {
+		WriteErrorResponse(w, APIError{http.StatusBadRequest, "invalid conditional expression", err.Error()}, "postWorkflow")
+		return
+	}
 
 	workflow, err := InitializeWorkflow(r.Context(), request.Workflow)
 	if err != nil {
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// an index required by the queries of the storage, identified by its name
+type requiredIndex struct {
+	Name   string
+	Keys   bson.D
+	Unique bool
+	// the weighted fields of a text index, a collection has at most one
+	Text []searchField
+}
+
+type collectionIndexes struct {
+	Collection string
+	Indexes    []requiredIndex
+}
+
+const (
+	// the index on the mandatory _id field of every collection
+	idIndexName = "_id_"
+	// code of the server error listing the indexes of a collection not yet created
+	namespaceNotFoundCode = 26
+)
+
+// the uid and version of a document are unique, concurrent puts of the same version fail
+var versionedIndex = requiredIndex{Name: "uid_version", Keys: bson.D{{Key: "uid", Value: 1}, {Key: "version.current", Value: -1}}, Unique: true}
+var uidIndex = requiredIndex{Name: "uid", Keys: bson.D{{Key: "uid", Value: 1}}, Unique: true}
+var workspaceIndex = requiredIndex{Name: "workspace", Keys: bson.D{{Key: "workspace", Value: 1}}}
+var workspaceTimestampIndex = requiredIndex{Name: "workspace_timestamp", Keys: bson.D{{Key: "workspace", Value: 1}, {Key: "timestamp", Value: -1}}}
+
+// jobs keep the workspace in their workflow
+var jobWorkspaceTimestampIndex = requiredIndex{Name: "workflow.workspace_timestamp", Keys: bson.D{{Key: "workflow.workspace", Value: 1}, {Key: "timestamp", Value: -1}}}
+
+func textIndex(fields []searchField) requiredIndex {
+	return requiredIndex{Name: "search", Text: fields}
+}
+
+// the indexes required by the storage clients, per collection
+func requiredIndexes() []collectionIndexes {
+	return []collectionIndexes{
+		{componentCollection, []requiredIndex{
+			versionedIndex,
+			{Name: "timestamp", Keys: bson.D{{Key: "timestamp", Value: -1}}},
+			textIndex(componentSearchFields),
+		}},
+		{workflowCollection, []requiredIndex{versionedIndex, workspaceTimestampIndex, textIndex(workflowSearchFields())}},
+		{jobCollection, []requiredIndex{uidIndex, jobWorkspaceTimestampIndex}},
+		{jobEventCollection, []requiredIndex{{Name: "jobUid_timestamp", Keys: bson.D{{Key: "jobUid", Value: 1}, {Key: "timestamp", Value: 1}}}}},
+		{volumeCollection, []requiredIndex{uidIndex, workspaceIndex}},
+		{scheduleCollection, []requiredIndex{uidIndex, workspaceIndex}},
+		{triggerCollection, []requiredIndex{uidIndex, workspaceIndex}},
+	}
+}
+
+func (i requiredIndex) model() mongo.IndexModel {
+	opts := options.Index().SetName(i.Name)
+	if i.Unique {
+		opts.SetUnique(true)
+	}
+	if len(i.Text) == 0 {
+		return mongo.IndexModel{Keys: i.Keys, Options: opts}
+	}
+	keys := bson.D{}
+	weights := bson.D{}
+	for _, f := range i.Text {
+		keys = append(keys, bson.E{Key: f.Path, Value: "text"})
+		weights = append(weights, bson.E{Key: f.Path, Value: f.Weight})
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts.SetWeights(weights)}
+}
+
+// an index as listed by the server
+type existingIndex struct {
+	Name    string `bson:"name"`
+	Key     bson.D `bson:"key"`
+	Unique  bool   `bson:"unique"`
+	Weights bson.M `bson:"weights"`
+}
+
+// the server lists the numbers of keys and weights as any numeric type
+func asInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), float64(int(n)) == n
+	}
+	return 0, false
+}
+
+// true if the existing index has the keys and options of the required one
+func (i requiredIndex) matches(e existingIndex) bool {
+	if i.Name != e.Name || i.Unique != e.Unique {
+		return false
+	}
+	if len(i.Text) > 0 {
+		// text indexes list their fields as weights, the keys are internal
+		if len(i.Text) != len(e.Weights) {
+			return false
+		}
+		for _, f := range i.Text {
+			if w, ok := asInt(e.Weights[f.Path]); !ok || w != f.Weight {
+				return false
+			}
+		}
+		return true
+	}
+	if len(i.Keys) != len(e.Key) {
+		return false
+	}
+	for n, k := range i.Keys {
+		want, _ := asInt(k.Value)
+		if got, ok := asInt(e.Key[n].Value); !ok || k.Key != e.Key[n].Key || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// compares the indexes of a collection with the required ones
+func indexReport(collection string, required []requiredIndex, existing []existingIndex) models.CollectionIndexReport {
+	report := models.CollectionIndexReport{Collection: collection, Missing: []string{}, Extra: []string{}}
+	for _, r := range required {
+		found := false
+		for _, e := range existing {
+			if r.matches(e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report.Missing = append(report.Missing, r.Name)
+		}
+	}
+	for _, e := range existing {
+		found := e.Name == idIndexName
+		for _, r := range required {
+			if r.Name == e.Name {
+				found = true
+			}
+		}
+		if !found {
+			report.Extra = append(report.Extra, e.Name)
+		}
+	}
+	return report
+}
+
+// Implements storage.IndexClient, checking and creating the indexes of the collections used by the mongo clients
+type MongoIndexClientImpl struct {
+	client  *mongo.Client
+	db_name string
+}
+
+func NewMongoIndexClientFromConfig(config DbConfig, client *mongo.Client) (IndexClient, error) {
+	// check that client is ok
+	if client == nil {
+		log.Info("Nil mongo client is passed so a new client will be created. It is good practice to share clients")
+		nclient, err := NewMongoClientFromConfig(config)
+		if err != nil {
+			log.Error("Cannot create new client")
+			return nil, errors.Wrap(err, "Could not create new mongo client")
+		}
+		client = nclient
+	}
+
+	if client.Ping(context.TODO(), nil) != nil {
+		log.Error("Cannot connect to database. Check configuration")
+		return &MongoIndexClientImpl{}, fmt.Errorf("Cannot connect to database. Check configuration")
+	}
+
+	log.Infof("Connected to mongodb (%v), with db name %s", config, config.DbName)
+	return &MongoIndexClientImpl{client: client, db_name: config.DbName}, nil
+}
+
+func (c *MongoIndexClientImpl) listIndexes(ctx context.Context, collection string) ([]existingIndex, error) {
+	cur, err := c.client.Database(c.db_name).Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		var cerr mongo.CommandError
+		if errors.As(err, &cerr) && cerr.Code == namespaceNotFoundCode {
+			return []existingIndex{}, nil
+		}
+		return nil, errors.Wrapf(err, "cannot list indexes of %s", collection)
+	}
+	defer cur.Close(ctx)
+
+	indexes := []existingIndex{}
+	if err := cur.All(ctx, &indexes); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode indexes of %s", collection)
+	}
+	return indexes, nil
+}
+
+func (c *MongoIndexClientImpl) IndexReport(ctx context.Context) (models.IndexReport, error) {
+	report := models.IndexReport{Collections: []models.CollectionIndexReport{}}
+	for _, coll := range requiredIndexes() {
+		existing, err := c.listIndexes(ctx, coll.Collection)
+		if err != nil {
+			return models.IndexReport{}, err
+		}
+		report.Collections = append(report.Collections, indexReport(coll.Collection, coll.Indexes, existing))
+	}
+	return report, nil
+}
+
+func (c *MongoIndexClientImpl) EnsureIndexes(ctx context.Context) (models.IndexReport, error) {
+	for _, coll := range requiredIndexes() {
+		existing, err := c.listIndexes(ctx, coll.Collection)
+		if err != nil {
+			return models.IndexReport{}, err
+		}
+		missing := indexReport(coll.Collection, coll.Indexes, existing).Missing
+		for _, r := range coll.Indexes {
+			if !contains(missing, r.Name) {
+				continue
+			}
+			// an index cannot be created over a differing one with the same name,
+			// nor can a unique index over duplicates, both are left to the admins
+			_, err := c.client.Database(c.db_name).Collection(coll.Collection).Indexes().CreateOne(ctx, r.model())
+			if err != nil {
+				log.Warnf("Cannot create index %s on %s: %v", r.Name, coll.Collection, err)
+				continue
+			}
+			log.Infof("Created index %s on %s", r.Name, coll.Collection)
+		}
+	}
+	return c.IndexReport(ctx)
+}
+
+// logs the collections with missing or extra indexes
+func LogIndexReport(report models.IndexReport) {
+	for _, c := range report.Collections {
+		if len(c.Missing) > 0 {
+			log.Warnf("Collection %s is missing the indexes: %s", c.Collection, strings.Join(c.Missing, ", "))
+		}
+		if len(c.Extra) > 0 {
+			log.Infof("Collection %s has the unused indexes: %s", c.Collection, strings.Join(c.Extra, ", "))
+		}
+	}
+}
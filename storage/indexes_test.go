@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func Test_IndexReport(t *testing.T) {
+	text := textIndex([]searchField{{"name", 10}, {"description", 5}})
+	required := []requiredIndex{versionedIndex, workspaceIndex, text}
+
+	existing := []existingIndex{
+		{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+		{Name: "uid_version", Key: bson.D{{Key: "uid", Value: int32(1)}, {Key: "version.current", Value: int32(-1)}}, Unique: true},
+		{Name: "workspace", Key: bson.D{{Key: "workspace", Value: 1.0}}},
+		{Name: "search", Key: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}, Weights: bson.M{"name": int32(10), "description": int32(5)}},
+		{Name: "legacy", Key: bson.D{{Key: "name", Value: int32(1)}}},
+	}
+	report := indexReport("Components", required, existing)
+	assert.Equal(t, "Components", report.Collection)
+	assert.Empty(t, report.Missing)
+	assert.Equal(t, []string{"legacy"}, report.Extra)
+
+	// an index differing in its keys, uniqueness or weights is missing
+	existing[1].Unique = false
+	existing[2].Key = bson.D{{Key: "workspace", Value: int32(-1)}}
+	existing[3].Weights = bson.M{"name": int32(1), "description": int32(5)}
+	report = indexReport("Components", required, existing)
+	assert.Equal(t, []string{"uid_version", "workspace", "search"}, report.Missing)
+	assert.Equal(t, []string{"legacy"}, report.Extra)
+
+	report = indexReport("Components", required, nil)
+	assert.Equal(t, []string{"uid_version", "workspace", "search"}, report.Missing)
+	assert.Empty(t, report.Extra)
+}
+
+func Test_RequiredIndexes(t *testing.T) {
+	for _, coll := range requiredIndexes() {
+		names := []string{}
+		texts := 0
+		for _, i := range coll.Indexes {
+			assert.NotContains(t, names, i.Name, "the indexes of %s are identified by name", coll.Collection)
+			names = append(names, i.Name)
+			if len(i.Text) > 0 {
+				texts++
+			}
+		}
+		assert.LessOrEqual(t, texts, 1, "%s has at most one text index", coll.Collection)
+	}
+
+	// the jobs are listed by the workspace of their workflow
+	for _, coll := range requiredIndexes() {
+		if coll.Collection == jobCollection {
+			assert.Contains(t, coll.Indexes, jobWorkspaceTimestampIndex)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestEnsureIndexes(t *testing.T) {
+	mclient.Database(test_db_name).Drop(context.TODO())
+	// the unique indexes are not left to the other tests
+	defer mclient.Database(test_db_name).Drop(context.TODO())
+
+	c, err := storage.NewMongoIndexClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+
+	report, err := c.IndexReport(context.TODO())
+	require.NoError(t, err)
+	assert.False(t, report.Complete())
+
+	legacy := mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("legacy")}
+	_, err = mclient.Database(test_db_name).Collection("Components").Indexes().CreateOne(context.TODO(), legacy)
+	require.NoError(t, err)
+
+	report, err = c.EnsureIndexes(context.TODO())
+	require.NoError(t, err)
+	assert.True(t, report.Complete())
+	for _, coll := range report.Collections {
+		if coll.Collection == "Components" {
+			assert.Equal(t, []string{"legacy"}, coll.Extra, "extra indexes are reported, not dropped")
+		} else {
+			assert.Empty(t, coll.Extra)
+		}
+	}
+
+	// ensuring is idempotent
+	again, err := c.EnsureIndexes(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, report, again)
+
+	// the same version cannot be stored twice
+	cstorage, err := storage.NewMongoStorageClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	cmp := makeComponent(nil)
+	require.NoError(t, cstorage.CreateComponent(context.TODO(), cmp))
+	assert.Error(t, cstorage.CreateComponent(context.TODO(), cmp))
+}
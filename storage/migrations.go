@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// the field holding the schema version of the documents in the versioned collections,
+// documents without it were stored before the schema was versioned
+const schemaVersionField = "schemaVersion"
+
+// a write to another collection going with an upgraded document, skipped in dry runs
+type migrationWrite func(ctx context.Context, db *mongo.Database) error
+
+// a step upgrading the documents of a collection from the previous schema version
+type migration struct {
+	Version     int
+	Description string
+	Collection  string
+	// returns the upgraded document, a noop for documents already in shape
+	Upgrade func(doc bson.D) (bson.D, []migrationWrite, error)
+}
+
+// the migrations in order of version, the last one is the current schema version
+var migrations = []migration{
+	{Version: 1, Description: "initialize the version of components stored before versioning", Collection: componentCollection, Upgrade: initializeVersion},
+	{Version: 1, Description: "initialize the version of workflows stored before versioning", Collection: workflowCollection, Upgrade: initializeVersion},
+	{Version: 2, Description: "move the workflow snapshots stored in jobs into job events", Collection: jobCollection, Upgrade: moveJobEvents},
+}
+
+// the collections whose documents are versioned, the documents written are stamped with the current version
+var versionedCollections = []string{componentCollection, workflowCollection, jobCollection}
+
+// The schema version of the documents written by this server
+var CurrentSchemaVersion = migrations[len(migrations)-1].Version
+
+func lookupField(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func setField(doc bson.D, key string, value interface{}) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+func removeField(doc bson.D, key string) bson.D {
+	out := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// marshals a document of the versioned collections, stamped with the current schema version
+func versionedDocument(document interface{}) (bson.D, error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return setField(doc, schemaVersionField, CurrentSchemaVersion), nil
+}
+
+// the schema version of a stored document, zero for documents stored before versioning
+func schemaVersion(doc bson.D) int {
+	v, ok := lookupField(doc, schemaVersionField)
+	if !ok {
+		return 0
+	}
+	n, _ := asInt(v)
+	return n
+}
+
+func initializeVersion(doc bson.D) (bson.D, []migrationWrite, error) {
+	if v, ok := lookupField(doc, "version"); ok && v != nil {
+		return doc, nil, nil
+	}
+	// without versions each uid was stored once, as its latest
+	version := models.Version{Current: models.VersionNumber(1)}
+	version.SetLatestTag()
+	return setField(doc, "version", version), nil, nil
+}
+
+func moveJobEvents(doc bson.D) (bson.D, []migrationWrite, error) {
+	if _, ok := lookupField(doc, "events"); !ok {
+		return doc, nil, nil
+	}
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot marshal job")
+	}
+	var legacy struct {
+		Uid models.ComponentReference `bson:"uid"`
+		// the full workflow as received for each watch event
+		Events []wfv1.Workflow `bson:"events"`
+	}
+	if err := bson.Unmarshal(raw, &legacy); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decode job events")
+	}
+
+	var latest map[string]models.JobEvent
+	events := []models.JobEvent{}
+	for i := range legacy.Events {
+		// the snapshots were not timestamped, fall back to the creation of the workflow
+		wfEvents := models.JobEventsFromWorkflow(legacy.Uid, &legacy.Events[i], latest, legacy.Events[i].CreationTimestamp.Time)
+		latest = models.LatestJobEvents(latest, wfEvents)
+		events = append(events, wfEvents...)
+	}
+
+	write := func(ctx context.Context, db *mongo.Database) error {
+		jobEvents := db.Collection(jobEventCollection)
+		// events left by an interrupted migration of the same job are replaced
+		if _, err := jobEvents.DeleteMany(ctx, bson.D{bson.E{Key: "jobUid", Value: legacy.Uid}}); err != nil {
+			return errors.Wrapf(err, "cannot migrate events for job %s", legacy.Uid)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		docs := make([]interface{}, 0, len(events))
+		for _, e := range events {
+			docs = append(docs, e)
+		}
+		if _, err := jobEvents.InsertMany(ctx, docs); err != nil {
+			return errors.Wrapf(err, "cannot migrate events for job %s", legacy.Uid)
+		}
+		return nil
+	}
+	return removeField(doc, "events"), []migrationWrite{write}, nil
+}
+
+// checks that an upgraded document decodes into the current model of its collection
+func decodeMigrated(collection string, doc bson.D) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	switch collection {
+	case componentCollection:
+		return bson.Unmarshal(raw, &models.Component{})
+	case workflowCollection:
+		return bson.Unmarshal(raw, &models.Workflow{})
+	case jobCollection:
+		return bson.Unmarshal(raw, &models.Job{})
+	}
+	return errors.Errorf("no model for collection %s", collection)
+}
+
+// upgrades a document of a collection from its schema version to the current one
+func upgradeDocument(collection string, doc bson.D) (bson.D, []migrationWrite, error) {
+	from := schemaVersion(doc)
+	if from > CurrentSchemaVersion {
+		return nil, nil, errors.Errorf("schema version %d is newer than the supported version %d", from, CurrentSchemaVersion)
+	}
+	writes := []migrationWrite{}
+	for _, m := range migrations {
+		if m.Version <= from || m.Collection != collection {
+			continue
+		}
+		var w []migrationWrite
+		var err error
+		doc, w, err = m.Upgrade(doc)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot upgrade to schema version %d (%s)", m.Version, m.Description)
+		}
+		writes = append(writes, w...)
+	}
+	doc = setField(doc, schemaVersionField, CurrentSchemaVersion)
+	if err := decodeMigrated(collection, doc); err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot decode document upgraded to schema version %d", CurrentSchemaVersion)
+	}
+	return doc, writes, nil
+}
+
+type MigrationOptions struct {
+	// upgrades and checks the documents without writing them
+	DryRun bool
+	// the number of documents read at a time
+	BatchSize int
+}
+
+// The documents of a collection upgraded by a migration run
+type MigrationReport struct {
+	Collection string
+	// the documents upgraded, or to be upgraded by a dry run
+	Migrated int
+	// the errors of the documents that could not be upgraded, by uid
+	Failed map[string]string
+}
+
+// selects the documents below the current schema version, including those without one
+func outdatedFilter() bson.D {
+	return bson.D{{Key: schemaVersionField, Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: CurrentSchemaVersion}}}}}}
+}
+
+// CountOutdatedDocuments returns the number of documents to be upgraded by a migration
+func CountOutdatedDocuments(ctx context.Context, client *mongo.Client, dbname string) (int, error) {
+	total := 0
+	for _, collection := range versionedCollections {
+		n, err := client.Database(dbname).Collection(collection).CountDocuments(ctx, outdatedFilter())
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot count outdated documents of %s", collection)
+		}
+		total += int(n)
+	}
+	return total, nil
+}
+
+// Migrate upgrades the documents stored by earlier versions to the current schema version, in batches.
+// Documents that cannot be upgraded are reported and left as they are, a noop when there are none left
+func Migrate(ctx context.Context, client *mongo.Client, dbname string, opts MigrationOptions) ([]MigrationReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	db := client.Database(dbname)
+	reports := []MigrationReport{}
+	for _, collection := range versionedCollections {
+		report := MigrationReport{Collection: collection, Failed: map[string]string{}}
+		coll := db.Collection(collection)
+
+		// failed documents, and all documents of a dry run, stay outdated so the batches move on by id
+		var after interface{}
+		for {
+			filter := outdatedFilter()
+			if after != nil {
+				filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: after}}})
+			}
+			cur, err := coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(opts.BatchSize)))
+			if err != nil {
+				return reports, errors.Wrapf(err, "cannot find documents of %s to migrate", collection)
+			}
+			batch := []bson.D{}
+			err = cur.All(ctx, &batch)
+			cur.Close(ctx)
+			if err != nil {
+				return reports, errors.Wrapf(err, "cannot decode documents of %s to migrate", collection)
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, doc := range batch {
+				id, _ := lookupField(doc, "_id")
+				after = id
+				uid, _ := lookupField(doc, "uid")
+
+				upgraded, writes, err := upgradeDocument(collection, doc)
+				if err != nil {
+					report.Failed[fmt.Sprint(uid)] = err.Error()
+					continue
+				}
+				report.Migrated++
+				if opts.DryRun {
+					continue
+				}
+				for _, w := range writes {
+					if err := w(ctx, db); err != nil {
+						return append(reports, report), err
+					}
+				}
+				if _, err := coll.ReplaceOne(ctx, bson.D{{Key: "_id", Value: id}}, upgraded); err != nil {
+					return append(reports, report), errors.Wrapf(err, "cannot replace migrated document %v of %s", uid, collection)
+				}
+			}
+			log.Debugf("Migrated a batch of %d documents of %s", len(batch), collection)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the documents as stored by a version of the server, marshaled from the current models without the fields added since
+func fixture(t *testing.T, document interface{}, without ...string) bson.D {
+	raw, err := bson.Marshal(document)
+	require.NoError(t, err)
+	var doc bson.D
+	require.NoError(t, bson.Unmarshal(raw, &doc))
+	doc = setField(doc, "_id", primitive.NewObjectID())
+	for _, key := range without {
+		doc = removeField(doc, key)
+	}
+	return doc
+}
+
+func fixtureMetadata() models.Metadata {
+	return models.Metadata{Name: "fixture",
+		ModifiedBy: models.ModifiedBy{Oid: "0", Email: "test@author.com"},
+		Timestamp:  time.Now().In(time.UTC).Truncate(time.Millisecond),
+		Uid:        models.NewComponentReference()}
+}
+
+func fixtureComponent() models.Component {
+	return models.Component{
+		ComponentBase:  models.ComponentBase{Type: "component", Metadata: fixtureMetadata()},
+		Implementation: models.Any{ImplementationBase: models.ImplementationBase{Type: "any"}}}
+}
+
+func fixtureWorkflow() models.Workflow {
+	return models.Workflow{Metadata: fixtureMetadata(), Component: fixtureComponent(), Type: "workflow", Workspace: "test"}
+}
+
+func fixtureVersion() models.Version {
+	v := models.Version{Current: models.VersionNumber(1)}
+	v.SetLatestTag()
+	return v
+}
+
+func Test_Migrations(t *testing.T) {
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		assert.Positive(t, m.Version)
+		assert.NotEmpty(t, m.Description)
+		assert.Contains(t, versionedCollections, m.Collection, "migration %d", i)
+		if i > 0 {
+			assert.GreaterOrEqual(t, m.Version, migrations[i-1].Version, "migrations are ordered by version")
+		}
+	}
+	assert.Equal(t, migrations[len(migrations)-1].Version, CurrentSchemaVersion)
+}
+
+func Test_VersionedDocument(t *testing.T) {
+	cmp := fixtureComponent()
+	doc, err := versionedDocument(cmp)
+	require.NoError(t, err)
+	assert.Equal(t, CurrentSchemaVersion, schemaVersion(doc))
+
+	// documents stored at the current version are left as they are
+	upgraded, writes, err := upgradeDocument(componentCollection, doc)
+	require.NoError(t, err)
+	assert.Empty(t, writes)
+	assert.Equal(t, doc, upgraded)
+}
+
+func Test_UpgradeDocument(t *testing.T) {
+	unversioned := fixtureComponent()
+	versioned := fixtureComponent()
+	versioned.Version = models.Version{Current: models.VersionNumber(3), Tags: []string{"stable"}}
+	wf := fixtureWorkflow()
+	job := models.Job{Metadata: fixtureMetadata(), Type: "job", Workflow: fixtureWorkflow()}
+
+	// the full workflow was stored for each event by the jobs before job events
+	started := time.Now().In(time.UTC).Truncate(time.Millisecond)
+	running := wfv1.Workflow{}
+	running.CreationTimestamp.Time = started
+	running.Status.Phase = wfv1.WorkflowRunning
+	running.Status.StartedAt.Time = started
+	succeeded := running
+	succeeded.Status.Phase = wfv1.WorkflowSucceeded
+	succeeded.Status.FinishedAt.Time = started.Add(time.Minute)
+	legacyJob := fixture(t, job, "version")
+	legacyJob = setField(legacyJob, "events", []wfv1.Workflow{running, running, succeeded})
+
+	testCases := []struct {
+		Name       string
+		Collection string
+		Doc        bson.D
+		Version    models.Version
+		Writes     int
+	}{
+		{"component before versioning", componentCollection, fixture(t, unversioned, "version"), fixtureVersion(), 0},
+		{"versioned component before schema versions", componentCollection, fixture(t, versioned), versioned.Version, 0},
+		{"workflow before versioning", workflowCollection, fixture(t, wf, "version"), fixtureVersion(), 0},
+		{"job with events", jobCollection, legacyJob, models.Version{}, 1},
+		{"job without events", jobCollection, fixture(t, job, "version"), models.Version{}, 0},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Equal(t, 0, schemaVersion(test.Doc))
+
+			doc, writes, err := upgradeDocument(test.Collection, test.Doc)
+			require.NoError(t, err)
+			assert.Equal(t, CurrentSchemaVersion, schemaVersion(doc))
+			assert.Len(t, writes, test.Writes)
+			_, ok := lookupField(doc, "events")
+			assert.False(t, ok, "no events left in the document")
+
+			raw, err := bson.Marshal(doc)
+			require.NoError(t, err)
+			var meta models.Metadata
+			require.NoError(t, bson.Unmarshal(raw, &meta))
+			assert.Equal(t, test.Version, meta.Version)
+
+			// upgrading is idempotent
+			again, writes, err := upgradeDocument(test.Collection, doc)
+			require.NoError(t, err)
+			assert.Empty(t, writes)
+			assert.Equal(t, doc, again)
+		})
+	}
+
+	t.Run("newer schema version", func(t *testing.T) {
+		doc := setField(fixture(t, unversioned), schemaVersionField, CurrentSchemaVersion+1)
+		_, _, err := upgradeDocument(componentCollection, doc)
+		assert.Error(t, err)
+	})
+
+	t.Run("undecodable document", func(t *testing.T) {
+		doc := setField(fixture(t, unversioned, "version"), "timestamp", "yesterday")
+		_, _, err := upgradeDocument(componentCollection, doc)
+		assert.Error(t, err)
+	})
+}
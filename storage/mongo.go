@@ -52,6 +52,8 @@ type DbConfig struct {
 	Select string
 	DbName string
 	Config map[string]interface{}
+	// upgrade the documents stored by earlier versions at startup, on by default in the server config
+	Migrate bool
 }
 
 func (c MongoConfig) ConnectionString() (string, error) {
@@ -116,6 +118,7 @@ const (
 	componentCollection = "Components"
 	workflowCollection  = "Workflows"
 	jobCollection       = "Jobs"
+	jobEventCollection  = "JobEvents"
 )
 
 type DocumentKind string
@@ -206,6 +209,10 @@ func (c *MongoStorageClient) getJobCollection() *mongo.Collection {
 	return c.client.Database(c.db_name).Collection(jobCollection)
 }
 
+func (c *MongoStorageClient) getJobEventCollection() *mongo.Collection {
+	return c.client.Database(c.db_name).Collection(jobEventCollection)
+}
+
 func (c *MongoStorageClient) selectGetter(kind DocumentKind) getCollection {
 	switch kind {
 	case ComponentKind:
@@ -257,12 +264,16 @@ func (c *MongoStorageClient) updateCallback(ctx context.Context, document interf
 		return nil, fmt.Errorf("cannot update document, unknown type: %s", v)
 	}
 
-	bzon, err := bson.Marshal(document)
+	bzon, err := versionedDocument(document)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot marshal %s for database", kind)
 	}
 	coll := getter()
 	result, err := coll.InsertOne(ctx, bzon)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent put stored the version first
+		return nil, ErrNewerDocumentExists
+	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot put %s: %s", kind, nodeUid.String())
 	}
@@ -323,7 +334,8 @@ func (c *MongoStorageClient) patchCallback(ctx context.Context, document interfa
 		return nil, ErrNewerDocumentExists
 	}
 
-	_, err = bson.Marshal(document)
+	// the patched document has the current shape, it is stamped like an inserted one
+	bzon, err := versionedDocument(document)
 	if err != nil {
 		return nil, errors.Wrapf(err, "cannot marshal %s for database", kind)
 	}
@@ -331,7 +343,7 @@ func (c *MongoStorageClient) patchCallback(ctx context.Context, document interfa
 	after := options.After
 	opts := options.FindOneAndUpdateOptions{ReturnDocument: &after}
 	update := bson.D{
-		bson.E{Key: "$set", Value: document},
+		bson.E{Key: "$set", Value: bzon},
 	}
 	result := coll.FindOneAndUpdate(ctx, filter, update, &opts)
 
@@ -362,6 +374,10 @@ func (c *MongoStorageClient) deleteCallback(ctx context.Context, documentKind Do
 
 	coll := getter()
 	result, err := coll.DeleteOne(ctx, filter)
+	if err == nil && documentKind == JobKind {
+		// the events of a job are stored separately
+		_, err = c.getJobEventCollection().DeleteMany(ctx, bson.D{bson.E{Key: "jobUid", Value: crefversion.Uid}})
+	}
 
 	return result, err
 }
@@ -531,7 +547,7 @@ func (c *MongoStorageClient) CreateComponent(ctx context.Context, node models.Co
 	}
 
 	coll := c.getComponentCollection()
-	bzon, err := bson.Marshal(node)
+	bzon, err := versionedDocument(node)
 
 	if err != nil {
 		return errors.Wrap(err, "cannot marshal workflow for database")
@@ -691,44 +707,26 @@ func (c *MongoStorageClient) ListComponentsMetadata(ctx context.Context, paginat
 		}
 	}
 
+	sort, err := cursorSort(sorts)
+	if err != nil {
+		return models.MetadataList{}, errors.Wrap(err, "could not list metadata for components")
+	}
 	{
-		sortQuery, err := sort_queries(sorts)
+		sorting, err := sortStages(pagination, sort)
 		if err != nil {
 			return models.MetadataList{}, errors.Wrap(err, "could not list metadata for components")
 		}
-
-		if len(sortQuery) > 0 {
-			sortStage := bson.D{bson.E{Key: "$sort", Value: sortQuery}}
-			stages = append(stages, sortStage)
-		}
+		stages = append(stages, sorting...)
 	}
 
 	{
 		// Reflect the Metadata type to create a (subset) projection for the db query
-		proj := ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.Metadata{})))
+		proj := append(ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.Metadata{}))), bson.E{Key: cursorField, Value: 1})
 		projStage := bson.D{bson.E{Key: "$project", Value: proj}}
 		stages = append(stages, projStage)
 	}
 
-	{
-		// append facet/splitting stage
-		facet := bson.D{bson.E{Key: "$facet", Value: bson.D{
-			bson.E{Key: "pageInfo", Value: bson.A{
-				bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
-				bson.D{bson.E{Key: "$addFields", Value: bson.D{
-					bson.E{Key: "skip", Value: pagination.Skip},
-					bson.E{Key: "limit", Value: pagination.Limit},
-				}}},
-			}},
-			bson.E{Key: "items", Value: bson.A{
-				// order is important, skip before limit
-				bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
-				bson.D{bson.E{Key: "$limit", Value: pagination.Limit}},
-			}},
-		}}}
-
-		stages = append(stages, facet)
-	}
+	stages = append(stages, pageFacetStage(pagination))
 
 	coll := c.getComponentCollection()
 	//opts := options.Aggregate()
@@ -740,32 +738,11 @@ func (c *MongoStorageClient) ListComponentsMetadata(ctx context.Context, paginat
 
 	defer cur.Close(ctx)
 
-	// the facet-aggregation returns an array with a single entry: { items: [...], pageInfo: [{ total: ... }] }
-	if !cur.Next(ctx) {
-		return models.MetadataList{}, errors.Wrap(err, "Error decoding component from storage, empty aggregation result")
-	}
-
-	var result models.MetadataList
-	{
-		facets := struct {
-			PageInfo []models.PageInfo `bson:"pageInfo"`
-			Items    []models.Metadata `bson:"items"`
-		}{}
-		err := cur.Decode(&facets)
-		if err != nil {
-			return models.MetadataList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		if len(facets.PageInfo) == 0 {
-			return models.MetadataList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		result = models.MetadataList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
-	}
-
-	if err := cur.Err(); err != nil {
-		return models.MetadataList{}, errors.Wrap(err, "Error getting components from storage")
+	items, pageInfo, err := decodePage[models.Metadata](ctx, cur, pagination, sort)
+	if err != nil {
+		return models.MetadataList{}, errors.Wrap(err, "Error decoding components from storage")
 	}
-
-	return result, nil
+	return models.MetadataList{Items: items, PageInfo: pageInfo}, nil
 }
 
 func (c *MongoStorageClient) ListComponentVersionsMetadata(ctx context.Context, id models.ComponentReference, pagination Pagination, sorts []string) (models.MetadataList, error) {
@@ -774,35 +751,22 @@ func (c *MongoStorageClient) ListComponentVersionsMetadata(ctx context.Context,
 	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "uid", Value: id}}}}
 	stages = append(stages, matchStage)
 
-	sortQuery, err := sort_queries(sorts)
+	sort, err := cursorSort(sorts)
 	if err != nil {
 		return models.MetadataList{}, errors.Wrap(err, "could not list metadata for components")
 	}
-	if len(sortQuery) > 0 {
-		sortStage := bson.D{bson.E{Key: "$sort", Value: sortQuery}}
-		stages = append(stages, sortStage)
+	sorting, err := sortStages(pagination, sort)
+	if err != nil {
+		return models.MetadataList{}, errors.Wrap(err, "could not list metadata for components")
 	}
+	stages = append(stages, sorting...)
 
 	// Reflect the Metadata type to create a (subset) projection for the db query
-	proj := ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.Metadata{})))
+	proj := append(ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.Metadata{}))), bson.E{Key: cursorField, Value: 1})
 	projStage := bson.D{bson.E{Key: "$project", Value: proj}}
 	stages = append(stages, projStage)
 
-	facet := bson.D{bson.E{Key: "$facet", Value: bson.D{
-		bson.E{Key: "pageInfo", Value: bson.A{
-			bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
-			bson.D{bson.E{Key: "$addFields", Value: bson.D{
-				bson.E{Key: "skip", Value: pagination.Skip},
-				bson.E{Key: "limit", Value: pagination.Limit},
-			}}},
-		}},
-		bson.E{Key: "items", Value: bson.A{
-			// order is important, skip before limit
-			bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
-			bson.D{bson.E{Key: "$limit", Value: pagination.Limit}},
-		}},
-	}}}
-	stages = append(stages, facet)
+	stages = append(stages, pageFacetStage(pagination))
 
 	coll := c.getComponentCollection()
 	cursor, err := coll.Aggregate(ctx, stages)
@@ -811,25 +775,11 @@ func (c *MongoStorageClient) ListComponentVersionsMetadata(ctx context.Context,
 	}
 	defer cursor.Close(ctx)
 
-	if !cursor.Next(ctx) {
-		return models.MetadataList{}, errors.Wrap(err, "Error decoding document versions from storage, empty aggregation result")
-	}
-
-	var result models.MetadataList
-	facets := struct {
-		PageInfo []models.PageInfo `bson:"pageInfo"`
-		Items    []models.Metadata `bson:"items"`
-	}{}
-	err = cursor.Decode(&facets)
+	items, pageInfo, err := decodePage[models.Metadata](ctx, cursor, pagination, sort)
 	if err != nil {
-		return models.MetadataList{}, errors.Wrap(err, "Error decoding component version from storage")
-	}
-	if len(facets.PageInfo) == 0 {
-		return models.MetadataList{}, errors.Wrap(err, "Error decoding component version from storage")
+		return models.MetadataList{}, errors.Wrap(err, "Error decoding component versions from storage")
 	}
-	result = models.MetadataList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
-
-	return result, nil
+	return models.MetadataList{Items: items, PageInfo: pageInfo}, nil
 }
 
 // Workflow storage impl
@@ -847,7 +797,7 @@ func (c *MongoStorageClient) CreateWorkflow(ctx context.Context, node models.Wor
 	}
 
 	coll := c.getWorkflowCollection()
-	bzon, err := bson.Marshal(node)
+	bzon, err := versionedDocument(node)
 
 	if err != nil {
 		return errors.Wrap(err, "cannot marshal workflow for database")
@@ -965,6 +915,16 @@ func createWorkspaceFilter(wsAccesses []workspace.Workspace, wsFieldPath string)
 	return wsFilter
 }
 
+// true if the value is in the slice
+func contains[T comparable](slice []T, value T) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *MongoStorageClient) ListWorkflowsMetadata(ctx context.Context, pagination Pagination, filterstrings []string, sorts []string) (models.MetadataWorkspaceList, error) {
 	// make sure we have authz
 	usr := user.GetUser(ctx)
@@ -998,43 +958,26 @@ func (c *MongoStorageClient) ListWorkflowsMetadata(ctx context.Context, paginati
 		}
 	}
 
+	sort, err := cursorSort(sorts)
+	if err != nil {
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflows")
+	}
 	{
-		sortQuery, err := sort_queries(sorts)
+		sorting, err := sortStages(pagination, sort)
 		if err != nil {
 			return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflows")
 		}
-		if len(sortQuery) > 0 {
-			sortStage := bson.D{bson.E{Key: "$sort", Value: sortQuery}}
-			stages = append(stages, sortStage)
-		}
+		stages = append(stages, sorting...)
 	}
 
 	{
 		// Reflect the Metadata type to create a (subset) projection for the db query
-		proj := ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{})))
+		proj := append(ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{}))), bson.E{Key: cursorField, Value: 1})
 		projStage := bson.D{bson.E{Key: "$project", Value: proj}}
 		stages = append(stages, projStage)
 	}
 
-	{
-		// append facet/splitting stage
-		facet := bson.D{bson.E{Key: "$facet", Value: bson.D{
-			bson.E{Key: "pageInfo", Value: bson.A{
-				bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
-				bson.D{bson.E{Key: "$addFields", Value: bson.D{
-					bson.E{Key: "skip", Value: pagination.Skip},
-					bson.E{Key: "limit", Value: pagination.Limit},
-				}}},
-			}},
-			bson.E{Key: "items", Value: bson.A{
-				// order is important, skip before limit
-				bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
-				bson.D{bson.E{Key: "$limit", Value: pagination.Limit}},
-			}}},
-		}}
-
-		stages = append(stages, facet)
-	}
+	stages = append(stages, pageFacetStage(pagination))
 
 	coll := c.getWorkflowCollection()
 	cur, err := coll.Aggregate(ctx, stages /*opts*/)
@@ -1043,32 +986,11 @@ func (c *MongoStorageClient) ListWorkflowsMetadata(ctx context.Context, paginati
 	}
 	defer cur.Close(ctx)
 
-	// the facet-aggregation returns an array with a single entry: { items: [...], pageInfo: [{ total: ... }] }
-	if !cur.Next(ctx) {
-		return models.MetadataWorkspaceList{}, fmt.Errorf("Error decoding component from storage, empty aggregation result")
-	}
-
-	var result models.MetadataWorkspaceList
-	{
-		facets := struct {
-			PageInfo []models.PageInfo          `bson:"pageInfo"`
-			Items    []models.MetadataWorkspace `bson:"items"`
-		}{}
-		err := cur.Decode(&facets)
-		if err != nil {
-			return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		if len(facets.PageInfo) == 0 {
-			return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		result = models.MetadataWorkspaceList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
-	}
-
-	if err := cur.Err(); err != nil {
-		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error getting components from storage")
+	items, pageInfo, err := decodePage[models.MetadataWorkspace](ctx, cur, pagination, sort)
+	if err != nil {
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding workflows from storage")
 	}
-
-	return result, nil
+	return models.MetadataWorkspaceList{Items: items, PageInfo: pageInfo}, nil
 }
 
 func (c *MongoStorageClient) ListWorkflowVersionsMetadata(ctx context.Context, id models.ComponentReference, pagination Pagination, sorts []string) (models.MetadataWorkspaceList, error) {
@@ -1085,35 +1007,22 @@ func (c *MongoStorageClient) ListWorkflowVersionsMetadata(ctx context.Context, i
 	matchStage := bson.D{bson.E{Key: "$match", Value: filter}}
 	stages = append(stages, matchStage)
 
-	sortQuery, err := sort_queries(sorts)
+	sort, err := cursorSort(sorts)
 	if err != nil {
 		return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflow")
 	}
-	if len(sortQuery) > 0 {
-		sortStage := bson.D{bson.E{Key: "$sort", Value: sortQuery}}
-		stages = append(stages, sortStage)
+	sorting, err := sortStages(pagination, sort)
+	if err != nil {
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflow")
 	}
+	stages = append(stages, sorting...)
 
 	// Reflect the Metadata type to create a (subset) projection for the db query
-	proj := ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{})))
+	proj := append(ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{}))), bson.E{Key: cursorField, Value: 1})
 	projStage := bson.D{bson.E{Key: "$project", Value: proj}}
 	stages = append(stages, projStage)
 
-	facet := bson.D{bson.E{Key: "$facet", Value: bson.D{
-		bson.E{Key: "pageInfo", Value: bson.A{
-			bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
-			bson.D{bson.E{Key: "$addFields", Value: bson.D{
-				bson.E{Key: "skip", Value: pagination.Skip},
-				bson.E{Key: "limit", Value: pagination.Limit},
-			}}},
-		}},
-		bson.E{Key: "items", Value: bson.A{
-			// order is important, skip before limit
-			bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
-			bson.D{bson.E{Key: "$limit", Value: pagination.Limit}},
-		}},
-	}}}
-	stages = append(stages, facet)
+	stages = append(stages, pageFacetStage(pagination))
 
 	coll := c.getWorkflowCollection()
 	cursor, err := coll.Aggregate(ctx, stages)
@@ -1122,25 +1031,11 @@ func (c *MongoStorageClient) ListWorkflowVersionsMetadata(ctx context.Context, i
 	}
 	defer cursor.Close(ctx)
 
-	if !cursor.Next(ctx) {
-		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding document versions from storage, empty aggregation result")
-	}
-
-	var result models.MetadataWorkspaceList
-	facets := struct {
-		PageInfo []models.PageInfo          `bson:"pageInfo"`
-		Items    []models.MetadataWorkspace `bson:"items"`
-	}{}
-	err = cursor.Decode(&facets)
+	items, pageInfo, err := decodePage[models.MetadataWorkspace](ctx, cursor, pagination, sort)
 	if err != nil {
-		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component version from storage")
-	}
-	if len(facets.PageInfo) == 0 {
-		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component version from storage")
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding workflow versions from storage")
 	}
-	result = models.MetadataWorkspaceList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
-
-	return result, nil
+	return models.MetadataWorkspaceList{Items: items, PageInfo: pageInfo}, nil
 }
 
 func (c *MongoStorageClient) PutWorkflow(ctx context.Context, node models.Workflow) error {
@@ -1195,7 +1090,7 @@ func (c *MongoStorageClient) GetJob(ctx context.Context, id models.ComponentRefe
 	}
 
 	if !CheckWorkspaceAccess(ctx, result.Workflow.Workspace) {
-		return models.Job{}, fmt.Errorf("user has no access to workspace (%s)", result.Workflow.Workspace)
+		return models.Job{}, errors.Wrapf(ErrNoAccess, "user has no access to workspace (%s)", result.Workflow.Workspace)
 	}
 
 	return result, nil
@@ -1208,7 +1103,7 @@ func (c *MongoStorageClient) CreateJob(ctx context.Context, node models.Job) err
 	}
 
 	coll := c.getJobCollection()
-	bzon, err := bson.Marshal(node)
+	bzon, err := versionedDocument(node)
 
 	if err != nil {
 		return errors.Wrap(err, "cannot marshal job for database")
@@ -1255,15 +1150,16 @@ func (c *MongoStorageClient) ListJobsMetadata(ctx context.Context, pagination Pa
 		}
 	}
 
+	sort, err := cursorSort(sorts)
+	if err != nil {
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflows")
+	}
 	{
-		sortQuery, err := sort_queries(sorts)
+		sorting, err := sortStages(pagination, sort)
 		if err != nil {
 			return models.MetadataWorkspaceList{}, errors.Wrap(err, "could not list metadata for workflows")
 		}
-		if len(sortQuery) > 0 {
-			sortStage := bson.D{bson.E{Key: "$sort", Value: sortQuery}}
-			stages = append(stages, sortStage)
-		}
+		stages = append(stages, sorting...)
 	}
 
 	{
@@ -1274,30 +1170,12 @@ func (c *MongoStorageClient) ListJobsMetadata(ctx context.Context, pagination Pa
 
 	{
 		// Reflect the Metadata type to create a (subset) projection for the db query
-		proj := ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{})))
+		proj := append(ProjectionFromBsonTags(flattenedFields(reflect.TypeOf(models.MetadataWorkspace{}))), bson.E{Key: cursorField, Value: 1})
 		projStage := bson.D{bson.E{Key: "$project", Value: proj}}
 		stages = append(stages, projStage)
 	}
 
-	{
-		// append facet/splitting stage
-		facet := bson.D{bson.E{Key: "$facet", Value: bson.D{
-			bson.E{Key: "pageInfo", Value: bson.A{
-				bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
-				bson.D{bson.E{Key: "$addFields", Value: bson.D{
-					bson.E{Key: "skip", Value: pagination.Skip},
-					bson.E{Key: "limit", Value: pagination.Limit},
-				}}},
-			}},
-			bson.E{Key: "items", Value: bson.A{
-				// order is important, skip before limit
-				bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
-				bson.D{bson.E{Key: "$limit", Value: pagination.Limit}},
-			}},
-		}}}
-
-		stages = append(stages, facet)
-	}
+	stages = append(stages, pageFacetStage(pagination))
 
 	coll := c.getJobCollection()
 	cur, err := coll.Aggregate(ctx, stages /*opts*/)
@@ -1306,45 +1184,47 @@ func (c *MongoStorageClient) ListJobsMetadata(ctx context.Context, pagination Pa
 	}
 	defer cur.Close(ctx)
 
-	// the facet-aggregation returns an array with a single entry: { items: [...], pageInfo: [{ total: ... }] }
-	if !cur.Next(ctx) {
-		return models.MetadataWorkspaceList{}, fmt.Errorf("Error decoding component from storage, empty aggregation result")
+	items, pageInfo, err := decodePage[models.MetadataWorkspace](ctx, cur, pagination, sort)
+	if err != nil {
+		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding jobs from storage")
 	}
+	return models.MetadataWorkspaceList{Items: items, PageInfo: pageInfo}, nil
+}
 
-	var result models.MetadataWorkspaceList
-	{
-		facets := struct {
-			PageInfo []models.PageInfo          `bson:"pageInfo"`
-			Items    []models.MetadataWorkspace `bson:"items"`
-		}{}
-		err := cur.Decode(&facets)
-		if err != nil {
-			return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		if len(facets.PageInfo) == 0 {
-			return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error decoding component from storage")
-		}
-		result = models.MetadataWorkspaceList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
+func (c *MongoStorageClient) AddJobEvents(ctx context.Context, id models.ComponentReference, events []models.JobEvent) error {
+	if len(events) == 0 {
+		return nil
 	}
 
-	if err := cur.Err(); err != nil {
-		return models.MetadataWorkspaceList{}, errors.Wrap(err, "Error getting components from storage")
+	docs := make([]interface{}, 0, len(events))
+	for _, e := range events {
+		e.JobUid = id
+		docs = append(docs, e)
 	}
+	coll := c.getJobEventCollection()
 
-	return result, nil
+	_, err := coll.InsertMany(ctx, docs)
+	if err != nil {
+		return errors.Wrapf(err, "cannot insert events for job %s", id)
+	}
+	return nil
 }
 
-func (c *MongoStorageClient) AddJobEvents(ctx context.Context, id models.ComponentReference, events []models.JobEvent) error {
+func (c *MongoStorageClient) ListJobEvents(ctx context.Context, id models.ComponentReference) ([]models.JobEvent, error) {
+	filter := bson.D{bson.E{Key: "jobUid", Value: id}}
+	// events with the same timestamp are kept in insertion order
+	opts := options.Find().SetSort(bson.D{bson.E{Key: "timestamp", Value: 1}, bson.E{Key: "_id", Value: 1}})
+	coll := c.getJobEventCollection()
 
-	filter := bson.D{
-		bson.E{Key: "uid", Value: id},
-	}
-	update := bson.D{
-		bson.E{Key: "$set", Value: bson.D{bson.E{Key: "events", Value: events}}},
+	cur, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get events for job %s", id)
 	}
-	coll := c.selectGetter(JobKind)()
-
-	_, err := coll.UpdateOne(ctx, filter, update)
+	defer cur.Close(ctx)
 
-	return err
+	events := []models.JobEvent{}
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode events for job %s", id)
+	}
+	return events, nil
 }
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Implements storage.ScheduleClient
+type MongoScheduleClientImpl struct {
+	client  *mongo.Client
+	db_name string
+}
+
+const (
+	scheduleCollection = "Schedules"
+)
+
+func NewMongoScheduleClientFromConfig(config DbConfig, client *mongo.Client) (ScheduleClient, error) {
+	// check that client is ok
+	if client == nil {
+		log.Info("Nil mongo client is passed so a new client will be created. It is good practice to share clients")
+		nclient, err := NewMongoClientFromConfig(config)
+		if err != nil {
+			log.Error("Cannot create new client")
+			return nil, errors.Wrap(err, "Could not create new mongo client")
+		}
+		client = nclient
+	}
+
+	if client.Ping(context.TODO(), nil) != nil {
+		log.Error("Cannot connect to database. Check configuration")
+		return &MongoScheduleClientImpl{}, fmt.Errorf("Cannot connect to database. Check configuration")
+	}
+
+	log.Infof("Connected to mongodb (%v), with db name %s", config, config.DbName)
+	return &MongoScheduleClientImpl{client: client, db_name: config.DbName}, nil
+}
+
+func (c *MongoScheduleClientImpl) getScheduleCollection() *mongo.Collection {
+	return c.client.Database(c.db_name).Collection(scheduleCollection)
+}
+
+func (c *MongoScheduleClientImpl) getJobCollection() *mongo.Collection {
+	return c.client.Database(c.db_name).Collection(jobCollection)
+}
+
+func (c *MongoScheduleClientImpl) ListSchedules(ctx context.Context, pagination Pagination, filterstrings []string, sortstrings []string) (models.ScheduleList, error) {
+	wss := getWorkspacesFromContext(ctx)
+
+	stages := mongo.Pipeline{}
+	{
+		filter := createWorkspaceFilter(wss, "workspace")
+		userFilters, err := filter_queries(filterstrings)
+		if err != nil {
+			return models.ScheduleList{}, errors.Wrap(err, "Error listing schedules")
+		}
+		if len(userFilters) > 0 {
+			filter = join_queries(append([]bson.D{filter}, userFilters...), AND)
+		}
+		stages = append(stages, bson.D{bson.E{Key: "$match", Value: filter}})
+	}
+
+	sort, err := cursorSort(sortstrings)
+	if err != nil {
+		return models.ScheduleList{}, errors.Wrap(err, "Error listing schedules")
+	}
+	{
+		sorting, err := sortStages(pagination, sort)
+		if err != nil {
+			return models.ScheduleList{}, errors.Wrap(err, "Error listing schedules")
+		}
+		stages = append(stages, sorting...)
+	}
+	stages = append(stages, pageFacetStage(pagination))
+
+	cur, err := c.getScheduleCollection().Aggregate(ctx, stages /*opts*/)
+	if err != nil {
+		return models.ScheduleList{}, errors.Wrap(err, "Error getting schedules from storage")
+	}
+	defer cur.Close(ctx)
+
+	items, pageInfo, err := decodePage[models.Schedule](ctx, cur, pagination, sort)
+	if err != nil {
+		return models.ScheduleList{}, errors.Wrap(err, "Error decoding schedules from storage")
+	}
+	if items == nil {
+		// no schedules in the accessible workspaces
+		items = []models.Schedule{}
+	}
+	return models.ScheduleList{Items: items, PageInfo: pageInfo}, nil
+}
+
+func (c *MongoScheduleClientImpl) getSchedule(ctx context.Context, id models.ComponentReference) (models.Schedule, error) {
+	var result models.Schedule
+	filter := bson.D{{Key: "uid", Value: id}}
+	err := c.getScheduleCollection().FindOne(ctx, filter).Decode(&result)
+
+	if err == mongo.ErrNoDocuments {
+		// an object that doesnt exist will always give a NotFound
+		return models.Schedule{}, ErrNotFound
+	} else if err != nil {
+		return models.Schedule{}, errors.Wrapf(err, "Error getting schedule %s from storage", id)
+	}
+
+	return result, nil
+}
+
+func (c *MongoScheduleClientImpl) GetSchedule(ctx context.Context, id models.ComponentReference) (models.Schedule, error) {
+	result, err := c.getSchedule(ctx, id)
+	if err != nil {
+		return models.Schedule{}, err
+	}
+
+	if !CheckWorkspaceAccess(ctx, result.Workspace) {
+		return models.Schedule{}, ErrNoAccess
+	}
+
+	return result, nil
+}
+
+func (c *MongoScheduleClientImpl) PutSchedule(ctx context.Context, schedule models.Schedule) error {
+	if schedule.Uid.IsZero() {
+		return fmt.Errorf("uid required")
+	}
+
+	if !CheckWorkspaceAccess(ctx, schedule.Workspace) {
+		return ErrNoAccess
+	}
+
+	bzon, err := bson.Marshal(schedule)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal schedule for database")
+	}
+
+	var create bool = false
+	if _, err := c.GetSchedule(ctx, schedule.Uid); err == ErrNotFound {
+		create = true
+	}
+
+	coll := c.getScheduleCollection()
+	switch create {
+	case true:
+		_, err = coll.InsertOne(ctx, bzon)
+	case false:
+		filter := bson.D{{Key: "uid", Value: schedule.Uid}}
+		_, err = coll.ReplaceOne(ctx, filter, bzon)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "could not put schedule %s", schedule.Uid.String())
+	}
+
+	return nil
+}
+
+func (c *MongoScheduleClientImpl) DeleteSchedule(ctx context.Context, id models.ComponentReference) error {
+	// check access rights by getting item first,
+	if _, err := c.GetSchedule(ctx, id); err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "uid", Value: id}}
+	res, err := c.getScheduleCollection().DeleteOne(ctx, filter)
+
+	if err != nil {
+		return errors.Wrapf(err, "error deleting schedule %s from storage", id)
+	}
+
+	// not mongo-error if deletedcount is 0, make it into flowify-error
+	if res.DeletedCount != 1 {
+		return fmt.Errorf("unexpected delete count %d, for %s", res.DeletedCount, id)
+	}
+
+	return nil
+}
+
+func (c *MongoScheduleClientImpl) CreateScheduledJob(ctx context.Context, scheduleId models.ComponentReference, jobId models.ComponentReference) (models.Job, error) {
+	schedule, err := c.getSchedule(ctx, scheduleId)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job := schedule.Job
+	job.Uid = jobId
+	// in order to be equal to mongo-roundtrip data we need to truncate timestamps
+	job.Timestamp = time.Now().In(time.UTC).Truncate(time.Millisecond)
+	job.Origin = &models.JobOrigin{Uid: scheduleId, Type: models.JobOriginSchedule}
+
+	// the job of a run is only inserted once, a run may be handled again after a restart
+	doc, err := versionedDocument(job)
+	if err != nil {
+		return models.Job{}, errors.Wrapf(err, "cannot marshal job %s of schedule %s", jobId, scheduleId)
+	}
+	filter := bson.D{{Key: "uid", Value: jobId}}
+	update := bson.D{{Key: "$setOnInsert", Value: doc}}
+	_, err = c.getJobCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return models.Job{}, errors.Wrapf(err, "cannot insert job %s of schedule %s", jobId, scheduleId)
+	}
+
+	var result models.Job
+	if err := c.getJobCollection().FindOne(ctx, filter).Decode(&result); err != nil {
+		return models.Job{}, errors.Wrapf(err, "cannot get job %s of schedule %s", jobId, scheduleId)
+	}
+	return result, nil
+}
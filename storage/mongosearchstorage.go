@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Implements storage.SearchClient with the text indexes of the component and workflow collections, created with the other required indexes
+type MongoSearchClientImpl struct {
+	client  *mongo.Client
+	db_name string
+}
+
+func NewMongoSearchClientFromConfig(config DbConfig, client *mongo.Client) (SearchClient, error) {
+	// check that client is ok
+	if client == nil {
+		log.Info("Nil mongo client is passed so a new client will be created. It is good practice to share clients")
+		nclient, err := NewMongoClientFromConfig(config)
+		if err != nil {
+			log.Error("Cannot create new client")
+			return nil, errors.Wrap(err, "Could not create new mongo client")
+		}
+		client = nclient
+	}
+
+	if client.Ping(context.TODO(), nil) != nil {
+		log.Error("Cannot connect to database. Check configuration")
+		return &MongoSearchClientImpl{}, fmt.Errorf("Cannot connect to database. Check configuration")
+	}
+
+	log.Infof("Connected to mongodb (%v), with db name %s", config, config.DbName)
+	return &MongoSearchClientImpl{client: client, db_name: config.DbName}, nil
+}
+
+// searches the latest versions of a collection, returns the best matches up to the end of the page and the number of matches
+func (c *MongoSearchClientImpl) searchCollection(ctx context.Context, collection string, filter bson.D, query string, pagination Pagination) ([]primitive.D, int, error) {
+	match := append(bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}},
+		{Key: "version.tags", Value: models.VersionTagLatest},
+	}, filter...)
+	stages := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.D{{Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "uid", Value: 1}}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "pageInfo", Value: bson.A{bson.D{{Key: "$count", Value: "totalNumber"}}}},
+			// the pages of the merged results are cut after merging
+			{Key: "items", Value: bson.A{bson.D{{Key: "$limit", Value: pagination.Skip + pagination.Limit + 1}}}},
+		}}},
+	}
+
+	cur, err := c.client.Database(c.db_name).Collection(collection).Aggregate(ctx, stages)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "cannot search %s", collection)
+	}
+	defer cur.Close(ctx)
+	if !cur.Next(ctx) {
+		return nil, 0, errors.Errorf("cannot search %s, empty aggregation result: %v", collection, cur.Err())
+	}
+
+	var facets struct {
+		PageInfo []models.PageInfo `bson:"pageInfo"`
+		Items    []primitive.D     `bson:"items"`
+	}
+	if err := cur.Decode(&facets); err != nil {
+		return nil, 0, errors.Wrapf(err, "cannot decode search results of %s", collection)
+	}
+	if len(facets.PageInfo) == 0 {
+		return nil, 0, nil
+	}
+	return facets.Items, facets.PageInfo[0].TotalNumber, nil
+}
+
+func (c *MongoSearchClientImpl) Search(ctx context.Context, query string, pagination Pagination) (models.SearchResultList, error) {
+	terms := searchTerms(query)
+	results := []models.SearchResult{}
+	total := 0
+
+	collect := func(docs []primitive.D, kind string, fields []searchField) error {
+		for _, doc := range docs {
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return errors.Wrapf(err, "cannot decode %s search result", kind)
+			}
+			var result struct {
+				models.MetadataWorkspace `bson:",inline"`
+				Score                    float64 `bson:"score"`
+			}
+			if err := bson.Unmarshal(raw, &result); err != nil {
+				return errors.Wrapf(err, "cannot decode %s search result", kind)
+			}
+			highlights, _ := highlightDocument(doc, fields, terms)
+			results = append(results, models.SearchResult{MetadataWorkspace: result.MetadataWorkspace, Kind: kind, Score: result.Score, Highlights: highlights})
+		}
+		return nil
+	}
+
+	components, n, err := c.searchCollection(ctx, componentCollection, bson.D{}, query, pagination)
+	if err != nil {
+		return models.SearchResultList{}, err
+	}
+	total += n
+	if err := collect(components, models.SearchKindComponent, componentSearchFields); err != nil {
+		return models.SearchResultList{}, err
+	}
+
+	if workspaces := accessibleWorkspaces(ctx); len(workspaces) > 0 {
+		filter := bson.D{{Key: "workspace", Value: bson.D{{Key: "$in", Value: workspaces}}}}
+		workflows, n, err := c.searchCollection(ctx, workflowCollection, filter, query, pagination)
+		if err != nil {
+			return models.SearchResultList{}, err
+		}
+		total += n
+		if err := collect(workflows, models.SearchKindWorkflow, workflowSearchFields()); err != nil {
+			return models.SearchResultList{}, err
+		}
+	}
+
+	return pageSearchResults(results, total, pagination), nil
+}
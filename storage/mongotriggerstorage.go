@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Implements storage.TriggerClient
+type MongoTriggerClientImpl struct {
+	client  *mongo.Client
+	db_name string
+}
+
+const (
+	triggerCollection = "Triggers"
+)
+
+func NewMongoTriggerClientFromConfig(config DbConfig, client *mongo.Client) (TriggerClient, error) {
+	// check that client is ok
+	if client == nil {
+		log.Info("Nil mongo client is passed so a new client will be created. It is good practice to share clients")
+		nclient, err := NewMongoClientFromConfig(config)
+		if err != nil {
+			log.Error("Cannot create new client")
+			return nil, errors.Wrap(err, "Could not create new mongo client")
+		}
+		client = nclient
+	}
+
+	if client.Ping(context.TODO(), nil) != nil {
+		log.Error("Cannot connect to database. Check configuration")
+		return &MongoTriggerClientImpl{}, fmt.Errorf("Cannot connect to database. Check configuration")
+	}
+
+	log.Infof("Connected to mongodb (%v), with db name %s", config, config.DbName)
+	return &MongoTriggerClientImpl{client: client, db_name: config.DbName}, nil
+}
+
+func (c *MongoTriggerClientImpl) getTriggerCollection() *mongo.Collection {
+	return c.client.Database(c.db_name).Collection(triggerCollection)
+}
+
+func (c *MongoTriggerClientImpl) ListTriggers(ctx context.Context, pagination Pagination, filterstrings []string, sortstrings []string) (models.TriggerList, error) {
+	wss := getWorkspacesFromContext(ctx)
+
+	stages := mongo.Pipeline{}
+	{
+		wsstage := bson.D{bson.E{Key: "$match", Value: createWorkspaceFilter(wss, "workspace")}}
+		stages = append(stages, wsstage)
+	}
+
+	{
+		userStages, err := makeFilterSortPipeline(pagination, filterstrings, sortstrings)
+		if err != nil {
+			return models.TriggerList{}, errors.Wrap(err, "Error listing triggers")
+		}
+
+		stages = append(stages, userStages...) // unpack user stages
+	}
+
+	cur, err := c.getTriggerCollection().Aggregate(ctx, stages /*opts*/)
+	if err != nil {
+		return models.TriggerList{}, errors.Wrap(err, "Error getting triggers from storage")
+	}
+
+	defer cur.Close(ctx)
+
+	// the facet-aggregation returns an array with a single entry: { items: [...], pageInfo: [{ total: ... }] }
+	if !cur.Next(ctx) {
+		return models.TriggerList{}, errors.Errorf("Error decoding trigger from storage, empty aggregation result: %v", cur.Err())
+	}
+
+	var result models.TriggerList
+	{
+		facets := struct {
+			PageInfo []models.PageInfo `bson:"pageInfo"`
+			Items    []models.Trigger  `bson:"items"`
+		}{}
+		err := cur.Decode(&facets)
+		if err != nil {
+			return models.TriggerList{}, errors.Wrap(err, "Error decoding trigger from storage")
+		}
+		if len(facets.PageInfo) == 0 {
+			// no triggers in the accessible workspaces
+			return models.TriggerList{Items: []models.Trigger{}, PageInfo: models.PageInfo{Skip: pagination.Skip, Limit: pagination.Limit}}, nil
+		}
+		result = models.TriggerList{Items: facets.Items, PageInfo: facets.PageInfo[0]}
+	}
+
+	if err := cur.Err(); err != nil {
+		return models.TriggerList{}, errors.Wrap(err, "Error getting triggers from storage")
+	}
+
+	return result, nil
+}
+
+func (c *MongoTriggerClientImpl) getTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error) {
+	var result models.Trigger
+	filter := bson.D{{Key: "uid", Value: id}}
+	err := c.getTriggerCollection().FindOne(ctx, filter).Decode(&result)
+
+	if err == mongo.ErrNoDocuments {
+		// an object that doesnt exist will always give a NotFound
+		return models.Trigger{}, ErrNotFound
+	} else if err != nil {
+		return models.Trigger{}, errors.Wrapf(err, "Error getting trigger %s from storage", id)
+	}
+
+	return result, nil
+}
+
+func (c *MongoTriggerClientImpl) GetTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error) {
+	result, err := c.getTrigger(ctx, id)
+	if err != nil {
+		return models.Trigger{}, err
+	}
+
+	if !CheckWorkspaceAccess(ctx, result.Workspace) {
+		return models.Trigger{}, ErrNoAccess
+	}
+
+	return result, nil
+}
+
+func (c *MongoTriggerClientImpl) PutTrigger(ctx context.Context, trigger models.Trigger) error {
+	if trigger.Uid.IsZero() {
+		return fmt.Errorf("uid required")
+	}
+
+	if !CheckWorkspaceAccess(ctx, trigger.Workspace) {
+		return ErrNoAccess
+	}
+
+	bzon, err := bson.Marshal(trigger)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal trigger for database")
+	}
+
+	var create bool = false
+	if _, err := c.GetTrigger(ctx, trigger.Uid); err == ErrNotFound {
+		create = true
+	}
+
+	coll := c.getTriggerCollection()
+	switch create {
+	case true:
+		_, err = coll.InsertOne(ctx, bzon)
+	case false:
+		filter := bson.D{{Key: "uid", Value: trigger.Uid}}
+		_, err = coll.ReplaceOne(ctx, filter, bzon)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "could not put trigger %s", trigger.Uid.String())
+	}
+
+	return nil
+}
+
+func (c *MongoTriggerClientImpl) DeleteTrigger(ctx context.Context, id models.ComponentReference) error {
+	// check access rights by getting item first,
+	if _, err := c.GetTrigger(ctx, id); err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "uid", Value: id}}
+	res, err := c.getTriggerCollection().DeleteOne(ctx, filter)
+
+	if err != nil {
+		return errors.Wrapf(err, "error deleting trigger %s from storage", id)
+	}
+
+	// not mongo-error if deletedcount is 0, make it into flowify-error
+	if res.DeletedCount != 1 {
+		return fmt.Errorf("unexpected delete count %d, for %s", res.DeletedCount, id)
+	}
+
+	return nil
+}
+
+func (c *MongoTriggerClientImpl) GetWebhookTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error) {
+	return c.getTrigger(ctx, id)
+}
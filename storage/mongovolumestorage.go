@@ -38,7 +38,7 @@ func NewMongoVolumeClientFromConfig(config DbConfig, client *mongo.Client) (Volu
 		return &MongoVolumeClientImpl{}, fmt.Errorf("Cannot connect to database. Check configuration")
 	}
 
-	log.Infof("Connected to mongodb (%s), with db name %s", config, config.DbName)
+	log.Infof("Connected to mongodb (%v), with db name %s", config, config.DbName)
 	return &MongoVolumeClientImpl{client: client, db_name: config.DbName}, nil
 }
 
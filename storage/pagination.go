@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Listings are paginated either by offset or by cursor. A cursor holds the sort values of the last item of a page,
+// the next page starts after it and is not shifted by documents inserted or deleted before it.
+
+// the field holding the sort values of a listed item
+const cursorField = "flowifyCursor"
+
+// appended to the requested sort to make the order total, the uid is shared by the versions of a document
+var cursorTiebreakers = bson.D{{Key: "uid", Value: int(ASC)}, {Key: "version.current", Value: int(DESC)}}
+
+type pageCursor struct {
+	Sort   bson.D `bson:"sort"`
+	Values bson.A `bson:"values"`
+}
+
+func encodeCursor(sort bson.D, values bson.A) (string, error) {
+	raw, err := bson.Marshal(pageCursor{Sort: sort, Values: values})
+	if err != nil {
+		return "", errors.Wrap(err, "cannot encode cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodes the sort values of a cursor, the cursor has to be issued for the same sort
+func decodeCursor(cursor string, sort bson.D) (bson.A, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidCursor, "cannot decode cursor: %v", err)
+	}
+	var decoded struct {
+		Sort   bson.Raw `bson:"sort"`
+		Values bson.A   `bson:"values"`
+	}
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		return nil, errors.Wrapf(ErrInvalidCursor, "cannot decode cursor: %v", err)
+	}
+	expected, err := bson.Marshal(sort)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode sort")
+	}
+	if !bytes.Equal(decoded.Sort, expected) || len(decoded.Values) != len(sort) {
+		return nil, errors.Wrap(ErrInvalidCursor, "the cursor was issued for another sort")
+	}
+	return decoded.Values, nil
+}
+
+// the requested sort followed by the tiebreakers it does not contain
+func cursorSort(sorts []string) (bson.D, error) {
+	sort, err := sort_queries(sorts)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, s := range sort {
+		keys[s.Key] = true
+	}
+	for _, t := range cursorTiebreakers {
+		if !keys[t.Key] {
+			sort = append(sort, t)
+		}
+	}
+	return sort, nil
+}
+
+// missing fields sort as null
+func sortValue(key string) bson.D {
+	return bson.D{{Key: "$ifNull", Value: bson.A{"$" + key, nil}}}
+}
+
+// a query selecting the items sorted after the cursor values. It only uses query operators on the sort keys
+// so it can be matched through their indexes. Missing fields and null sort first, sort keys hold a single type
+func afterCursor(sort bson.D, values bson.A) bson.D {
+	alternatives := make(bson.A, 0, len(sort))
+	for i, s := range sort {
+		after := bson.A{}
+		switch {
+		case s.Value == int(DESC) && values[i] == nil:
+			// nothing sorts before null
+			continue
+		case s.Value == int(DESC):
+			after = append(after, bson.D{{Key: s.Key, Value: bson.D{{Key: "$lt", Value: values[i]}}}}, bson.D{{Key: s.Key, Value: nil}})
+		case values[i] == nil:
+			after = append(after, bson.D{{Key: s.Key, Value: bson.D{{Key: "$ne", Value: nil}}}})
+		default:
+			after = append(after, bson.D{{Key: s.Key, Value: bson.D{{Key: "$gt", Value: values[i]}}}})
+		}
+
+		conditions := bson.A{}
+		for j := 0; j < i; j++ {
+			// an equality query on null matches missing fields too
+			conditions = append(conditions, bson.D{{Key: sort[j].Key, Value: bson.D{{Key: "$eq", Value: values[j]}}}})
+		}
+		if len(after) == 1 {
+			conditions = append(conditions, after[0])
+		} else {
+			conditions = append(conditions, bson.D{{Key: "$or", Value: after}})
+		}
+		alternatives = append(alternatives, bson.D{{Key: "$and", Value: conditions}})
+	}
+	if len(alternatives) == 0 {
+		// the cursor is at the end of the listing
+		return bson.D{{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+	}
+	return bson.D{{Key: "$or", Value: alternatives}}
+}
+
+// the stages starting a listing after the cursor and sorting it, they record the sort values of the items in the cursor field.
+// The cursor is matched before sorting so both can use the indexes of the sort keys
+func sortStages(pagination Pagination, sort bson.D) (mongo.Pipeline, error) {
+	stages := mongo.Pipeline{}
+	if pagination.Cursor != "" {
+		values, err := decodeCursor(pagination.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, bson.D{{Key: "$match", Value: afterCursor(sort, values)}})
+	}
+	stages = append(stages, bson.D{{Key: "$sort", Value: sort}})
+	values := make(bson.A, 0, len(sort))
+	for _, s := range sort {
+		values = append(values, sortValue(s.Key))
+	}
+	stages = append(stages, bson.D{{Key: "$addFields", Value: bson.D{{Key: cursorField, Value: values}}}})
+	return stages, nil
+}
+
+// the final stage of a listing, the page info counts the items and the items are limited to the page.
+// One more item is included to tell if there is a next page. Pages after a cursor are not counted,
+// it would scan the rest of the listing for every page
+func pageFacetStage(pagination Pagination) bson.D {
+	facets := bson.D{}
+	if pagination.Cursor == "" {
+		facets = append(facets, bson.E{Key: "pageInfo", Value: bson.A{
+			bson.D{bson.E{Key: "$count", Value: "totalNumber"}},
+			bson.D{bson.E{Key: "$addFields", Value: bson.D{
+				bson.E{Key: "skip", Value: pagination.Skip},
+				bson.E{Key: "limit", Value: pagination.Limit},
+			}}},
+		}})
+	}
+	facets = append(facets, bson.E{Key: "items", Value: bson.A{
+		// order is important, skip before limit
+		bson.D{bson.E{Key: "$skip", Value: pagination.Skip}},
+		bson.D{bson.E{Key: "$limit", Value: pagination.Limit + 1}},
+	}})
+	return bson.D{bson.E{Key: "$facet", Value: facets}}
+}
+
+// decodes the single document of the facet stage, the page info gets the cursor of the next page if there is one
+func decodePage[T any](ctx context.Context, cur *mongo.Cursor, pagination Pagination, sort bson.D) ([]T, models.PageInfo, error) {
+	if !cur.Next(ctx) {
+		return nil, models.PageInfo{}, errors.Errorf("empty aggregation result: %v", cur.Err())
+	}
+
+	var facets struct {
+		PageInfo []models.PageInfo `bson:"pageInfo"`
+		Items    []T               `bson:"items"`
+	}
+	if err := cur.Decode(&facets); err != nil {
+		return nil, models.PageInfo{}, errors.Wrap(err, "cannot decode page")
+	}
+	// nothing counted when empty or listed after a cursor
+	page := models.PageInfo{Skip: pagination.Skip, Limit: pagination.Limit}
+	if len(facets.PageInfo) > 0 {
+		page = facets.PageInfo[0]
+	}
+	items := facets.Items
+	if len(items) > pagination.Limit {
+		items = items[:pagination.Limit]
+		if pagination.Limit > 0 {
+			var cursors struct {
+				Items []struct {
+					Values bson.A `bson:"flowifyCursor"`
+				} `bson:"items"`
+			}
+			if err := cur.Decode(&cursors); err != nil {
+				return nil, models.PageInfo{}, errors.Wrap(err, "cannot decode page cursor")
+			}
+			next, err := encodeCursor(sort, cursors.Items[pagination.Limit-1].Values)
+			if err != nil {
+				return nil, models.PageInfo{}, err
+			}
+			page.NextCursor = next
+		}
+	}
+	return items, page, nil
+}
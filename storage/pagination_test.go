@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_CursorSort(t *testing.T) {
+	sort, err := cursorSort(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cursorTiebreakers, sort)
+
+	sort, err = cursorSort([]string{"-timestamp,+uid"})
+	require.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "timestamp", Value: int(DESC)}, {Key: "uid", Value: int(ASC)}, {Key: "version.current", Value: int(DESC)}}, sort)
+
+	_, err = cursorSort([]string{"timestamp"})
+	assert.Error(t, err)
+}
+
+func Test_CursorRoundtrip(t *testing.T) {
+	sort, err := cursorSort([]string{"-timestamp"})
+	require.NoError(t, err)
+	timestamp := primitive.NewDateTimeFromTime(time.Now().Truncate(time.Millisecond))
+
+	cursor, err := encodeCursor(sort, bson.A{timestamp, "a-uid", int32(2)})
+	require.NoError(t, err)
+	values, err := decodeCursor(cursor, sort)
+	require.NoError(t, err)
+	assert.Equal(t, bson.A{timestamp, "a-uid", int32(2)}, values)
+
+	other, err := cursorSort([]string{"+timestamp"})
+	require.NoError(t, err)
+	_, err = decodeCursor(cursor, other)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	_, err = decodeCursor("not a cursor", sort)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = decodeCursor("bm90IGJzb24", sort)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func Test_AfterCursor(t *testing.T) {
+	sort := bson.D{{Key: "name", Value: int(ASC)}, {Key: "uid", Value: int(DESC)}}
+	op := func(key string, op string, v interface{}) bson.D {
+		return bson.D{{Key: key, Value: bson.D{{Key: op, Value: v}}}}
+	}
+
+	// plain query operators on the sort keys, a value starting with $ is not a field path
+	assert.Equal(t, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "$and", Value: bson.A{
+			op("name", "$gt", "$b"),
+		}}},
+		bson.D{{Key: "$and", Value: bson.A{
+			op("name", "$eq", "$b"),
+			bson.D{{Key: "$or", Value: bson.A{op("uid", "$lt", "u"), bson.D{{Key: "uid", Value: nil}}}}},
+		}}},
+	}}}, afterCursor(sort, bson.A{"$b", "u"}))
+
+	// missing values sort first
+	assert.Equal(t, bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "$and", Value: bson.A{
+			op("name", "$ne", nil),
+		}}},
+	}}}, afterCursor(sort, bson.A{nil, nil}))
+}
+
+func Test_SortStages(t *testing.T) {
+	sort := bson.D{{Key: "timestamp", Value: int(DESC)}, {Key: "uid", Value: int(ASC)}}
+	cursor, err := encodeCursor(sort, bson.A{"t", "u"})
+	require.NoError(t, err)
+
+	stages, err := sortStages(Pagination{Cursor: cursor, Limit: 10}, sort)
+	require.NoError(t, err)
+	require.Len(t, stages, 3)
+	assert.Equal(t, "$match", stages[0][0].Key, "the cursor is matched before sorting")
+	assert.Equal(t, bson.D{{Key: "$sort", Value: sort}}, stages[1])
+
+	// pages after a cursor are not counted
+	facets := pageFacetStage(Pagination{Cursor: cursor, Limit: 10})[0].Value.(bson.D)
+	require.Len(t, facets, 1)
+	assert.Equal(t, "items", facets[0].Key)
+	facets = pageFacetStage(Pagination{Limit: 10})[0].Value.(bson.D)
+	assert.Equal(t, "pageInfo", facets[0].Key)
+}
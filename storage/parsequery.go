@@ -4,6 +4,7 @@ package storage
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -66,35 +67,94 @@ func sort_queries(sortstrings []string) (bson.D, error) {
 	return sorts, nil
 }
 
-func mongo_operator(op string, value interface{}) (bson.D, error) {
-	switch op {
+// FilterError points at the token of a filter query that could not be parsed
+type FilterError struct {
+	Query  string
+	Pos    int
+	Token  string
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("cannot parse filter query (%s) at end of query: %s", e.Query, e.Reason)
+	}
+	return fmt.Sprintf("cannot parse filter query (%s) at position %d (%s): %s", e.Query, e.Pos, e.Token, e.Reason)
+}
+
+// the nodes of a parsed filter query
+type filterExpr interface {
+	mongo() bson.D
+}
+
+type filterAnd []filterExpr
+type filterOr []filterExpr
+type filterNot struct {
+	expr filterExpr
+}
+type filterTerm struct {
+	attribute string
+	operator  string
+	value     interface{}
+}
+
+func (t filterTerm) mongo() bson.D {
+	var op bson.D
+	switch t.operator {
 	case "==":
 		// exact match
-		return bson.D{bson.E{Key: "$eq", Value: value}}, nil
+		op = bson.D{{Key: "$eq", Value: t.value}}
 	case "!=":
-		return bson.D{bson.E{Key: "$neq", Value: value}}, nil
+		op = bson.D{{Key: "$ne", Value: t.value}}
 	case ">=":
-		return bson.D{bson.E{Key: "$gte", Value: value}}, nil
+		op = bson.D{{Key: "$gte", Value: t.value}}
 	case "<=":
-		return bson.D{bson.E{Key: "$lte", Value: value}}, nil
+		op = bson.D{{Key: "$lte", Value: t.value}}
 	case ">":
-		return bson.D{bson.E{Key: "$gt", Value: value}}, nil
+		op = bson.D{{Key: "$gt", Value: t.value}}
 	case "<":
-		return bson.D{bson.E{Key: "$lt", Value: value}}, nil
+		op = bson.D{{Key: "$lt", Value: t.value}}
 	case "search":
 		// make regexp case insensitive
-		return bson.D{bson.E{Key: "$regex", Value: value}, bson.E{Key: "$options", Value: "i"}}, nil
-	default:
-		return bson.D{}, fmt.Errorf("no such filter operator (%s)", op)
+		op = bson.D{{Key: "$regex", Value: t.value}, {Key: "$options", Value: "i"}}
+	case "in":
+		op = bson.D{{Key: "$in", Value: t.value}}
+	case "exists":
+		op = bson.D{{Key: "$exists", Value: t.value}}
 	}
+	return bson.D{{Key: t.attribute, Value: op}}
 }
 
-func mongo_filter(attr string, ops string, value interface{}) (bson.E, error) {
-	op, err := mongo_operator(ops, value)
-	if err != nil {
-		return bson.E{}, errors.Wrap(err, "could not construct filter")
+func (a filterAnd) mongo() bson.D {
+	// terms on distinct attributes are kept in a single document, as in the original comma-joined syntax
+	flat := bson.D{}
+	attributes := map[string]bool{}
+	for _, e := range a {
+		t, ok := e.(filterTerm)
+		if !ok || attributes[t.attribute] {
+			return bson.D{{Key: "$and", Value: mongoArray(a)}}
+		}
+		attributes[t.attribute] = true
+		flat = append(flat, t.mongo()...)
 	}
-	return bson.E{Key: attr, Value: op}, nil
+	return flat
+}
+
+func (o filterOr) mongo() bson.D {
+	return bson.D{{Key: "$or", Value: mongoArray(o)}}
+}
+
+func (n filterNot) mongo() bson.D {
+	// $not only applies to operator expressions, $nor negates any query
+	return bson.D{{Key: "$nor", Value: bson.A{n.expr.mongo()}}}
+}
+
+func mongoArray(exprs []filterExpr) bson.A {
+	arr := make(bson.A, 0, len(exprs))
+	for _, e := range exprs {
+		arr = append(arr, e.mongo())
+	}
+	return arr
 }
 
 func filter_queries(filterstrings []string) ([]bson.D, error) {
@@ -110,47 +170,314 @@ func filter_queries(filterstrings []string) ([]bson.D, error) {
 	return filters, nil
 }
 
+// parses a filter query into a mongo query.
+// LHS brackets from https://www.moesif.com/blog/technical/api-design/REST-API-Design-Filtering-Sorting-and-Pagination/
+//
+//	?filter=modifiedBy[==]=flow@equinor.com
+//	?filter=!(type[in]=(brick,map)|description[exists]=false),version[>=]=2
+//
+// Terms are joined with ',' (and) and '|' (or), where and binds tighter, negated with '!' and grouped in parentheses.
+// Values are strings, except for the attributes stored as other types (see filterAttributeTypes) and the booleans of exists.
+// Unquoted values end at the next ',', '|' or unbalanced ')', the regular expressions of search only at the next ',' or
+// unbalanced ')' so they keep their alternatives. Quoted values ("...", with Go escapes) may contain any delimiter
 func parse_filter_query(filter string) (bson.D, error) {
-	// LHS brackets from https://www.moesif.com/blog/technical/api-design/REST-API-Design-Filtering-Sorting-and-Pagination/
-	// ?filter=modifiedBy[==]=flow@equinor.com
+	expr, err := parse_filter_expr(filter)
+	if err != nil {
+		return bson.D{}, err
+	}
+	return expr.mongo(), nil
+}
 
-	var validFilter = regexp.MustCompile(`^` + fieldDotField + `\[(==|>=|<=|search|>|<|!=)\]=(.*)$`)
+func parse_filter_expr(filter string) (filterExpr, error) {
+	p := filterParser{query: filter}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf(p.pos, "unexpected token")
+	}
+	return expr, nil
+}
 
-	parts := strings.Split(filter, ",")
+var (
+	filterAttribute = regexp.MustCompile(`^` + fieldDotField)
+	filterOperators = []string{"==", "!=", ">=", "<=", ">", "<", "search", "in", "exists"}
+)
 
-	// bson.D is required to keep the order (its a list of bson.E)
-	result := make([]bson.E, 0, len(parts))
-	for i, p := range parts {
-		matches := validFilter.FindStringSubmatch(p)
-		// the full match and the three subgroups should be returned for a valid query
-		if len(matches) != 4 {
-			return bson.D{}, fmt.Errorf("cannot parse filter (%d:%s) from query (%s)", i, p, filter)
-		}
-
-		opName := matches[2]
-		attributeName := matches[1]
-		var value interface{}
-		var err error
-		switch attributeName {
-		case "timestamp":
-			value, err = time.Parse(time.RFC3339, matches[3])
-			if err != nil {
-				return bson.D{}, errors.Wrapf(err, "cannot parse timestamp (%s) in (%d:%s) from query (%s)", matches[3], i, p, filter)
-			}
+type filterValueType string
+
+const (
+	filterDate filterValueType = "a date (RFC3339 or ISO date)"
+	filterInt  filterValueType = "an integer"
+	filterBool filterValueType = "true or false"
+)
+
+// the attributes not stored as strings, their values are parsed to compare with the stored type
+var filterAttributeTypes = map[string]filterValueType{
+	"timestamp":       filterDate,
+	"version.current": filterInt,
+	"suspended":       filterBool,
+}
+
+const filterDelimiters = ",|()"
 
-		default:
-			value = matches[3]
+type filterParser struct {
+	query string
+	pos   int
+}
+
+func (p *filterParser) done() bool {
+	return p.pos >= len(p.query)
+}
+
+func (p *filterParser) peek(c byte) bool {
+	return !p.done() && p.query[p.pos] == c
+}
+
+func (p *filterParser) expect(c byte) error {
+	if !p.peek(c) {
+		return p.errorf(p.pos, "expected '%c'", c)
+	}
+	p.pos++
+	return nil
+}
+
+// the token at pos, a delimiter or the text up to the next delimiter
+func (p *filterParser) token(pos int) string {
+	if pos >= len(p.query) {
+		return ""
+	}
+	if strings.IndexByte(filterDelimiters+"!", p.query[pos]) >= 0 {
+		return p.query[pos : pos+1]
+	}
+	end := strings.IndexAny(p.query[pos:], filterDelimiters)
+	if end < 0 {
+		return p.query[pos:]
+	}
+	return p.query[pos : pos+end]
+}
+
+func (p *filterParser) errorf(pos int, format string, args ...interface{}) error {
+	return &FilterError{Query: p.query, Pos: pos, Token: p.token(pos), Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	exprs := filterOr{}
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		if !p.peek('|') {
+			break
 		}
+		p.pos++
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
 
-		filter, err := mongo_filter(attributeName, opName, value)
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	exprs := filterAnd{}
+	for {
+		expr, err := p.parseUnary()
 		if err != nil {
-			return bson.D{}, errors.Wrapf(err, "cannot parse filter (%d:%s) from query (%s)", i, p, filter)
+			return nil, err
 		}
+		exprs = append(exprs, expr)
+		if !p.peek(',') {
+			break
+		}
+		p.pos++
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
 
-		result = append(result, filter)
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	switch {
+	case p.peek('!'):
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr: expr}, nil
+	case p.peek('('):
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	default:
+		return p.parseTerm()
 	}
+}
 
-	return result, nil
+func (p *filterParser) parseTerm() (filterExpr, error) {
+	attribute := filterAttribute.FindString(p.query[p.pos:])
+	if attribute == "" {
+		return nil, p.errorf(p.pos, "expected a filter of the form attribute[operator]=value")
+	}
+	p.pos += len(attribute)
+	if err := p.expect('['); err != nil {
+		return nil, err
+	}
+	opPos := p.pos
+	end := strings.IndexByte(p.query[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf(opPos, "expected an operator closed by ']'")
+	}
+	operator := p.query[p.pos : p.pos+end]
+	if !contains(filterOperators, operator) {
+		return nil, &FilterError{Query: p.query, Pos: opPos, Token: operator, Reason: fmt.Sprintf("no such filter operator, expected one of %s", strings.Join(filterOperators, " "))}
+	}
+	p.pos += end + 1
+	if err := p.expect('='); err != nil {
+		return nil, err
+	}
+
+	term := filterTerm{attribute: attribute, operator: operator}
+	switch operator {
+	case "in":
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		values := bson.A{}
+		for {
+			value, err := p.parseTypedValue(attribute, filterAttributeTypes[attribute])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.peek(',') {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		term.value = values
+	case "exists":
+		value, err := p.parseTypedValue(operator, filterBool)
+		if err != nil {
+			return nil, err
+		}
+		term.value = value
+	case "search":
+		value, err := p.parseValue(true)
+		if err != nil {
+			return nil, err
+		}
+		term.value = value
+	default:
+		value, err := p.parseTypedValue(attribute, filterAttributeTypes[attribute])
+		if err != nil {
+			return nil, err
+		}
+		term.value = value
+	}
+	return term, nil
+}
+
+// parses a value, quoted or up to the next delimiter. Parentheses in unquoted values have to be balanced,
+// a regular expression keeps its alternatives and escaped characters
+func (p *filterParser) parseValue(regex bool) (string, error) {
+	start := p.pos
+	if p.peek('"') {
+		// find the closing quote, skipping escaped characters
+		for i := start + 1; i < len(p.query); i++ {
+			switch p.query[i] {
+			case '\\':
+				i++
+			case '"':
+				str, err := strconv.Unquote(p.query[start : i+1])
+				if err != nil {
+					return "", p.errorf(start, "invalid quoted value: %v", err)
+				}
+				p.pos = i + 1
+				return str, nil
+			}
+		}
+		return "", p.errorf(start, "unterminated quoted value")
+	}
+
+	depth := 0
+	end := start
+scan:
+	for ; end < len(p.query); end++ {
+		switch p.query[end] {
+		case '\\':
+			if regex {
+				end++
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				break scan
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				break scan
+			}
+		case '|':
+			if depth == 0 && !regex {
+				break scan
+			}
+		}
+	}
+	if end > len(p.query) {
+		end = len(p.query)
+	}
+	if end == start {
+		return "", p.errorf(start, "expected a value")
+	}
+	p.pos = end
+	return p.query[start:end], nil
+}
+
+// parses a value of a type, values without a type are strings
+func (p *filterParser) parseTypedValue(name string, t filterValueType) (interface{}, error) {
+	start := p.pos
+	raw, err := p.parseValue(false)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case filterDate:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+	case filterInt:
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i, nil
+		}
+	case filterBool:
+		switch raw {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	default:
+		return raw, nil
+	}
+	return nil, p.errorf(start, "%s takes %s", name, t)
 }
 
 type JoinOp string
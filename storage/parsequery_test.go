@@ -50,12 +50,14 @@ func Test_SortParse(t *testing.T) {
 }
 
 func Test_FilterParse(t *testing.T) {
-	makeErr := func(query string, no int, part string) error {
-		// just copied from the internal function
-		return fmt.Errorf("cannot parse filter (%d:%s) from query (%s)", no, part, query)
+	makeErr := func(query string, pos int, token string, reason string) error {
+		return &FilterError{Query: query, Pos: pos, Token: token, Reason: reason}
 	}
-	emptyErr := makeErr("", 0, "")
+	emptyErr := makeErr("", 0, "", "expected a filter of the form attribute[operator]=value")
 	isoNow := time.Now().UTC() //.Truncate(time.Second) // RFC3339 has no subsecond precision
+	eq := func(attr string, value interface{}) bson.D {
+		return bson.D{{Key: attr, Value: bson.D{{Key: "$eq", Value: value}}}}
+	}
 
 	var testCases = []struct {
 		Name           string // name
@@ -74,6 +76,73 @@ func Test_FilterParse(t *testing.T) {
 				{Key: "timestamp", Value: bson.D{{Key: "$lte", Value: isoNow}}},
 				{Key: "modifiedBy", Value: bson.D{{Key: "$eq", Value: "flow@equinor.com"}}},
 			}},
+		{"Not equal", "type[!=]=brick", nil, bson.D{{Key: "type", Value: bson.D{{Key: "$ne", Value: "brick"}}}}},
+		{"Typed attributes", "version.current[>=]=2,suspended[==]=false,timestamp[>]=2022-02-22", nil,
+			bson.D{
+				{Key: "version.current", Value: bson.D{{Key: "$gte", Value: int64(2)}}},
+				{Key: "suspended", Value: bson.D{{Key: "$eq", Value: false}}},
+				{Key: "timestamp", Value: bson.D{{Key: "$gt", Value: time.Date(2022, 2, 22, 0, 0, 0, 0, time.UTC)}}},
+			}},
+		{"Values are strings", "name[==]=2,description[==]=true,ratio[<]=0.5,created[==]=2022-02-22", nil,
+			bson.D{
+				{Key: "name", Value: bson.D{{Key: "$eq", Value: "2"}}},
+				{Key: "description", Value: bson.D{{Key: "$eq", Value: "true"}}},
+				{Key: "ratio", Value: bson.D{{Key: "$lt", Value: "0.5"}}},
+				{Key: "created", Value: bson.D{{Key: "$eq", Value: "2022-02-22"}}},
+			}},
+		{"Quoted values", `name[==]="2",description[==]="a, (b|c) \"d\""`, nil,
+			append(eq("name", "2"), eq("description", `a, (b|c) "d"`)...)},
+		{"Parentheses in values", "name[==]=f(x),description[!=]=(none)", nil,
+			bson.D{
+				{Key: "name", Value: bson.D{{Key: "$eq", Value: "f(x)"}}},
+				{Key: "description", Value: bson.D{{Key: "$ne", Value: "(none)"}}},
+			}},
+		{"Search is never typed", "name[search]=1.5", nil,
+			bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "1.5"}, {Key: "$options", Value: "i"}}}}},
+		{"Search keeps alternatives", "name[search]=(foo|bar),description[search]=^a|b$", nil,
+			bson.D{
+				{Key: "name", Value: bson.D{{Key: "$regex", Value: "(foo|bar)"}, {Key: "$options", Value: "i"}}},
+				{Key: "description", Value: bson.D{{Key: "$regex", Value: "^a|b$"}, {Key: "$options", Value: "i"}}},
+			}},
+		{"Search keeps escapes", `name[search]=\(a\),type[==]=brick`, nil,
+			append(bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: `\(a\)`}, {Key: "$options", Value: "i"}}}}, eq("type", "brick")...)},
+		{"Grouped search", "(name[search]=a|b)|type[==]=map", nil,
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "a|b"}, {Key: "$options", Value: "i"}}}},
+				eq("type", "map"),
+			}}}},
+		{"Same attribute", "version.current[>]=1,version.current[<]=3", nil,
+			bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "version.current", Value: bson.D{{Key: "$gt", Value: int64(1)}}}},
+				bson.D{{Key: "version.current", Value: bson.D{{Key: "$lt", Value: int64(3)}}}},
+			}}}},
+		{"Or binds looser than and", "type[==]=brick,name[==]=a|type[==]=map", nil,
+			bson.D{{Key: "$or", Value: bson.A{
+				append(eq("type", "brick"), eq("name", "a")...),
+				eq("type", "map"),
+			}}}},
+		{"Grouping", "type[==]=brick,(name[==]=a|name[==]=b)", nil,
+			bson.D{{Key: "$and", Value: bson.A{
+				eq("type", "brick"),
+				bson.D{{Key: "$or", Value: bson.A{eq("name", "a"), eq("name", "b")}}},
+			}}}},
+		{"Negation", "!(type[in]=(brick,map,3)|description[exists]=false)", nil,
+			bson.D{{Key: "$nor", Value: bson.A{
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "type", Value: bson.D{{Key: "$in", Value: bson.A{"brick", "map", "3"}}}}},
+					bson.D{{Key: "description", Value: bson.D{{Key: "$exists", Value: false}}}},
+				}}},
+			}}}},
+		{"Unknown operator", "name[like]=a", makeErr("name[like]=a", 5, "like", "no such filter operator, expected one of == != >= <= > < search in exists"), bson.D{}},
+		{"Missing value", "name[==]=a,type[==]=", makeErr("name[==]=a,type[==]=", 20, "", "expected a value"), bson.D{}},
+		{"Trailing comma", "name[==]=a,", makeErr("name[==]=a,", 11, "", "expected a filter of the form attribute[operator]=value"), bson.D{}},
+		{"Unbalanced parentheses", "(name[==]=a", makeErr("(name[==]=a", 11, "", "expected ')'"), bson.D{}},
+		{"Unexpected token", "name[==]=a)", makeErr("name[==]=a)", 10, ")", "unexpected token"), bson.D{}},
+		{"Exists takes a boolean", "name[exists]=yes", makeErr("name[exists]=yes", 13, "yes", "exists takes true or false"), bson.D{}},
+		{"In takes a list", "name[in]=a", makeErr("name[in]=a", 9, "a", "expected '('"), bson.D{}},
+		{"Unterminated quote", `name[==]="a`, makeErr(`name[==]="a`, 9, `"a`, "unterminated quoted value"), bson.D{}},
+		{"Bad timestamp", "timestamp[>]=yesterday", makeErr("timestamp[>]=yesterday", 13, "yesterday", "timestamp takes a date (RFC3339 or ISO date)"), bson.D{}},
+		{"Bad version", "version.current[>]=two", makeErr("version.current[>]=two", 19, "two", "version.current takes an integer"), bson.D{}},
 	}
 
 	for _, test := range testCases {
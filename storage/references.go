@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/equinor/flowify-workflows-server/models"
 	"github.com/pkg/errors"
@@ -48,7 +50,85 @@ func drefNode(ctx context.Context, client ComponentClient, node *models.Node) (m
 	}
 }
 
-func traverseComponent(ctx context.Context, client ComponentClient, cmp models.Component) (models.Component, error) {
+// The default limit on how deep subcomponents are followed when dereferencing
+const DefaultMaxDereferenceDepth = 64
+
+// MaxDereferenceDepth is the nesting limit used by DereferenceComponent, set from the server config at startup
+var MaxDereferenceDepth = DefaultMaxDereferenceDepth
+
+type DereferenceErrorKind string
+
+const (
+	DereferenceErrorCycle    DereferenceErrorKind = "cycle"
+	DereferenceErrorMaxDepth DereferenceErrorKind = "maxdepth"
+)
+
+// ReferencePathElement identifies a component on the path from the root of a dereference
+type ReferencePathElement struct {
+	// the id of the node holding the component in its parent, empty for the root
+	NodeId  string                    `json:"nodeId,omitempty"`
+	Uid     models.ComponentReference `json:"uid"`
+	Version models.VersionNumber      `json:"version,omitempty"`
+}
+
+func (e ReferencePathElement) String() string {
+	nodeId := e.NodeId
+	if nodeId == "" {
+		nodeId = "root"
+	}
+	return fmt.Sprintf("%s (uid: %s, version: %d)", nodeId, e.Uid.String(), e.Version)
+}
+
+// DereferenceError is returned when the component tree cannot be resolved because of
+// a reference cycle or because it nests deeper than the allowed max depth
+type DereferenceError struct {
+	Kind DereferenceErrorKind `json:"kind"`
+	// for cycles the path starts and ends with the same component, otherwise it is the full path from the root
+	Path []ReferencePathElement `json:"path"`
+}
+
+func (e *DereferenceError) Error() string {
+	elements := make([]string, 0, len(e.Path))
+	for _, p := range e.Path {
+		elements = append(elements, p.String())
+	}
+	switch e.Kind {
+	case DereferenceErrorCycle:
+		return fmt.Sprintf("Reference cycle detected: %s", strings.Join(elements, " -> "))
+	case DereferenceErrorMaxDepth:
+		return fmt.Sprintf("Max dereference depth (%d) exceeded: %s", len(e.Path)-1, strings.Join(elements, " -> "))
+	default:
+		return fmt.Sprintf("Cannot dereference: %s", strings.Join(elements, " -> "))
+	}
+}
+
+// descend returns the path extended with the component, or an error if the component
+// is already on the path or the path grows beyond maxDepth
+func descend(path []ReferencePathElement, nodeId string, cmp models.Component, maxDepth int) ([]ReferencePathElement, error) {
+	elem := ReferencePathElement{NodeId: nodeId, Uid: cmp.Uid, Version: cmp.Version.Current}
+
+	// always copy, siblings share the parent path
+	newPath := make([]ReferencePathElement, 0, len(path)+1)
+	newPath = append(newPath, path...)
+	newPath = append(newPath, elem)
+
+	if !cmp.Uid.IsZero() {
+		// inline components without uid can never be part of a cycle
+		for i, p := range path {
+			if p.Uid == elem.Uid && p.Version == elem.Version {
+				return nil, &DereferenceError{Kind: DereferenceErrorCycle, Path: newPath[i:]}
+			}
+		}
+	}
+
+	if len(newPath)-1 > maxDepth {
+		return nil, &DereferenceError{Kind: DereferenceErrorMaxDepth, Path: newPath}
+	}
+
+	return newPath, nil
+}
+
+func traverseComponent(ctx context.Context, client ComponentClient, cmp models.Component, path []ReferencePathElement, maxDepth int) (models.Component, error) {
 
 	switch impCmp := cmp.Implementation.(type) {
 	case models.Graph:
@@ -60,7 +140,11 @@ func traverseComponent(ctx context.Context, client ComponentClient, cmp models.C
 			}
 			_, ok := sub.Node.(models.Component)
 			if ok {
-				nc, err := traverseComponent(ctx, client, sub.Node.(models.Component))
+				subPath, err := descend(path, sub.Id, sub.Node.(models.Component), maxDepth)
+				if err != nil {
+					return models.Component{}, err
+				}
+				nc, err := traverseComponent(ctx, client, sub.Node.(models.Component), subPath, maxDepth)
 				if err != nil {
 					return models.Component{}, err
 				}
@@ -75,13 +159,48 @@ func traverseComponent(ctx context.Context, client ComponentClient, cmp models.C
 		if err != nil {
 			return models.Component{}, err
 		}
-		nc, err := traverseComponent(ctx, client, newNode)
+		subPath, err := descend(path, "mapnode", newNode, maxDepth)
+		if err != nil {
+			return models.Component{}, err
+		}
+		nc, err := traverseComponent(ctx, client, newNode, subPath, maxDepth)
 		if err != nil {
 			return models.Component{}, err
 		}
 		newNode = nc
 		impCmp.Node = newNode
 		cmp.Implementation = impCmp
+	case models.Conditional:
+		nodeTrue, err := drefComponent(ctx, client, impCmp.NodeTrue)
+		if err != nil {
+			return models.Component{}, errors.Wrap(err, "Cannot dereference true node of conditional")
+		}
+		subPath, err := descend(path, "nodeTrue", nodeTrue, maxDepth)
+		if err != nil {
+			return models.Component{}, err
+		}
+		nodeTrue, err = traverseComponent(ctx, client, nodeTrue, subPath, maxDepth)
+		if err != nil {
+			return models.Component{}, err
+		}
+		impCmp.NodeTrue = nodeTrue
+
+		if impCmp.NodeFalse != nil {
+			nodeFalse, err := drefComponent(ctx, client, impCmp.NodeFalse)
+			if err != nil {
+				return models.Component{}, errors.Wrap(err, "Cannot dereference false node of conditional")
+			}
+			subPath, err := descend(path, "nodeFalse", nodeFalse, maxDepth)
+			if err != nil {
+				return models.Component{}, err
+			}
+			nodeFalse, err = traverseComponent(ctx, client, nodeFalse, subPath, maxDepth)
+			if err != nil {
+				return models.Component{}, err
+			}
+			impCmp.NodeFalse = nodeFalse
+		}
+		cmp.Implementation = impCmp
 	case models.Brick:
 		// no subcomponents for dereference
 	default:
@@ -90,12 +209,87 @@ func traverseComponent(ctx context.Context, client ComponentClient, cmp models.C
 	return cmp, nil
 }
 
+// DereferenceComponent resolves all component references in the tree, limited by MaxDereferenceDepth
 func DereferenceComponent(ctx context.Context, client ComponentClient, cmp interface{}) (models.Component, error) {
+	return DereferenceComponentWithMaxDepth(ctx, client, cmp, MaxDereferenceDepth)
+}
+
+// DereferenceComponentWithMaxDepth resolves all component references in the tree. Returns a *DereferenceError
+// if a reference cycle is found or the components are nested deeper than maxDepth
+func DereferenceComponentWithMaxDepth(ctx context.Context, client ComponentClient, cmp interface{}, maxDepth int) (models.Component, error) {
+	return dereferenceComponent(ctx, client, cmp, maxDepth)
+}
+
+// lockingClient resolves unversioned references to the versions pinned by a lockfile,
+// and records the versions picked for all unversioned references it resolves
+type lockingClient struct {
+	ComponentClient
+	pinned   map[models.ComponentReference]models.VersionNumber
+	lockfile []models.CRefVersion
+}
+
+func (c *lockingClient) GetComponent(ctx context.Context, id interface{}) (models.Component, error) {
+	var cref models.CRefVersion
+	switch v := id.(type) {
+	case models.ComponentReference:
+		cref = models.CRefVersion{Uid: v}
+	case models.CRefVersion:
+		cref = v
+	default:
+		return c.ComponentClient.GetComponent(ctx, id)
+	}
+
+	if cref.Version != models.VersionNumber(0) {
+		// explicitly versioned references are already pinned
+		return c.ComponentClient.GetComponent(ctx, cref)
+	}
+
+	if version, ok := c.pinned[cref.Uid]; ok {
+		cref.Version = version
+	}
+	cmp, err := c.ComponentClient.GetComponent(ctx, cref)
+	if err != nil {
+		return models.Component{}, err
+	}
+
+	if _, ok := c.pinned[cref.Uid]; !ok {
+		c.pinned[cref.Uid] = cmp.Version.Current
+	}
+	for _, l := range c.lockfile {
+		if l.Uid == cref.Uid {
+			return cmp, nil
+		}
+	}
+	c.lockfile = append(c.lockfile, models.CRefVersion{Uid: cref.Uid, Version: cmp.Version.Current})
+	return cmp, nil
+}
+
+// DereferenceComponentWithLockfile resolves all component references in the tree like DereferenceComponent.
+// Unversioned references found in the lockfile are resolved to the locked version instead of the latest.
+// Returns the resolved component and the lockfile of versions used for all unversioned references in the tree
+func DereferenceComponentWithLockfile(ctx context.Context, client ComponentClient, cmp interface{}, lockfile []models.CRefVersion) (models.Component, []models.CRefVersion, error) {
+	lclient := lockingClient{ComponentClient: client, pinned: make(map[models.ComponentReference]models.VersionNumber, len(lockfile))}
+	for _, l := range lockfile {
+		lclient.pinned[l.Uid] = l.Version
+	}
+
+	out, err := dereferenceComponent(ctx, &lclient, cmp, MaxDereferenceDepth)
+	if err != nil {
+		return models.Component{}, nil, err
+	}
+	return out, lclient.lockfile, nil
+}
+
+func dereferenceComponent(ctx context.Context, client ComponentClient, cmp interface{}, maxDepth int) (models.Component, error) {
 	out, err := drefComponent(ctx, client, cmp)
 	if err != nil {
 		return models.Component{}, err
 	}
-	out, err = traverseComponent(ctx, client, out)
+	path, err := descend([]ReferencePathElement{}, "", out, maxDepth)
+	if err != nil {
+		return models.Component{}, err
+	}
+	out, err = traverseComponent(ctx, client, out, path, maxDepth)
 	if err != nil {
 		return models.Component{}, err
 	}
@@ -295,6 +295,90 @@ const (
 	"edges": []
   }
 }`
+
+	conditionalJson = `
+{
+  "uid": "192161d7-e3f2-4991-adc0-a99c88c144d0",
+  "description": "Conditional with referenced branches",
+  "inputs": [],
+  "outputs": [],
+  "type": "component",
+  "implementation": {
+    "type": "conditional",
+	"expression": {
+	  "left": "a",
+	  "operator": "==",
+	  "right": "b"
+	},
+	"nodeTrue": "192161d7-e3f2-4991-adc0-a99c88c144b2",
+	"nodeFalse": {
+	  "version": 2,
+	  "uid": "192161d7-e3f2-4991-adc0-a88c99c144b1"
+	}
+  }
+}`
+
+	expectedConditionalJson = `
+{
+  "uid": "192161d7-e3f2-4991-adc0-a99c88c144d0",
+  "description": "Conditional with referenced branches",
+  "inputs": [],
+  "outputs": [],
+  "type": "component",
+  "implementation": {
+    "type": "conditional",
+	"expression": {
+	  "left": "a",
+	  "operator": "==",
+	  "right": "b"
+	},
+	"nodeTrue": {
+	  "uid": "192161d7-e3f2-4991-adc0-a99c88c144b2",
+	  "version": {
+		"current": 1,
+		"tags": ["latest"]
+	  },
+	  "description": "B2",
+	  "inputs": [],
+	  "outputs": [],
+	  "type": "component",
+	  "implementation": {
+		"type": "brick",
+		"container": {
+		  "name": "containername",
+		  "image": "docker/whalesay",
+		  "command": ["cowsay"],
+		  "args": ["Hello from B2"]
+		}
+	  }
+	},
+	"nodeFalse": {
+	  "uid": "192161d7-e3f2-4991-adc0-a88c99c144b1",
+	  "version": {
+		"current": 2,
+		"tags": ["latest"],
+		"previous": {
+		  "version": 1
+		}
+	  },
+	  "description": "MapNode2",
+	  "inputs": [],
+	  "outputs": [],
+	  "type": "component",
+	  "implementation": {
+		"type": "brick",
+		"container": {
+		  "name": "containername_n1_b1",
+		  "image": "alpine:0.2",
+		  "command": ["sh", "-c", "echo $0 | tee /tmp/prm"],
+		  "args": []
+		},
+		"args": [],
+		"results": []
+	  }
+	}
+  }
+}`
 )
 
 var brickCmp models.Component
@@ -304,6 +388,8 @@ var mapNodeCmp1 models.Component
 var mapNodeCmp2 models.Component
 var cmpRef models.ComponentReference
 var expectedGraphCmp models.Component
+var conditionalCmp models.Component
+var expectedConditionalCmp models.Component
 
 func init() {
 	err := json.Unmarshal([]byte(brickJson), &brickCmp)
@@ -334,6 +420,14 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = json.Unmarshal([]byte(conditionalJson), &conditionalCmp)
+	if err != nil {
+		panic(err)
+	}
+	err = json.Unmarshal([]byte(expectedConditionalJson), &expectedConditionalCmp)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func TestDereferenceComponent(t *testing.T) {
@@ -361,4 +455,146 @@ func TestDereferenceComponent(t *testing.T) {
 	cmpObj, err = storage.DereferenceComponent(context.TODO(), cstorage, graphCmp)
 	assert.Nil(t, err)
 	assert.Equal(t, expectedGraphCmp, cmpObj)
+
+	// Test dereference of Conditional with referenced true/false nodes
+	cmpObj, err = storage.DereferenceComponent(context.TODO(), cstorage, conditionalCmp)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedConditionalCmp, cmpObj)
+}
+
+// resolves components from memory, other storage methods are not implemented
+type memoryComponentClient struct {
+	storage.ComponentClient
+	cmps map[models.ComponentReference]models.Component
+	// older versions, by uid
+	versions map[models.ComponentReference][]models.Component
+}
+
+func (c memoryComponentClient) GetComponent(ctx context.Context, id interface{}) (models.Component, error) {
+	var cref models.CRefVersion
+	switch v := id.(type) {
+	case models.ComponentReference:
+		cref = models.CRefVersion{Uid: v}
+	case models.CRefVersion:
+		cref = v
+	}
+	cmp, ok := c.cmps[cref.Uid]
+	if !ok {
+		return models.Component{}, storage.ErrNotFound
+	}
+	if cref.Version == 0 || cref.Version == cmp.Version.Current {
+		return cmp, nil
+	}
+	for _, v := range c.versions[cref.Uid] {
+		if v.Version.Current == cref.Version {
+			return v, nil
+		}
+	}
+	return models.Component{}, storage.ErrNotFound
+}
+
+func makeGraphCmp(uid string, nodes ...models.Node) models.Component {
+	cmp := models.Component{
+		ComponentBase: models.ComponentBase{
+			Metadata: models.Metadata{Uid: models.NewReference(uid), Version: models.Version{Current: models.VersionInit}},
+			Type:     models.ComponentType("component"),
+		},
+		Implementation: models.Graph{ImplementationBase: models.ImplementationBase{Type: models.GraphType}, Nodes: nodes},
+	}
+	return cmp
+}
+
+func TestDereferenceComponentCycles(t *testing.T) {
+	const (
+		uidA = "0f8c1bb4-5e4f-4b6c-9d0a-6c1d6a2f0a01"
+		uidB = "0f8c1bb4-5e4f-4b6c-9d0a-6c1d6a2f0a02"
+		uidC = "0f8c1bb4-5e4f-4b6c-9d0a-6c1d6a2f0a03"
+	)
+	// A -> B -> C -> A, and B also holds the brick twice to check that siblings are not cycles
+	cmpA := makeGraphCmp(uidA, models.Node{Id: "nA", Node: models.NewReference(uidB)})
+	cmpB := makeGraphCmp(uidB,
+		models.Node{Id: "nB1", Node: brickCmp.Uid},
+		models.Node{Id: "nB2", Node: brickCmp.Uid},
+		models.Node{Id: "nB3", Node: models.CRefVersion{Uid: models.NewReference(uidC), Version: models.VersionInit}})
+	cmpC := makeGraphCmp(uidC, models.Node{Id: "nC", Node: models.NewReference(uidA)})
+
+	client := memoryComponentClient{cmps: map[models.ComponentReference]models.Component{
+		cmpA.Uid: cmpA, cmpB.Uid: cmpB, cmpC.Uid: cmpC, brickCmp.Uid: brickCmp,
+	}}
+
+	t.Run("cycle", func(t *testing.T) {
+		_, err := storage.DereferenceComponent(context.TODO(), client, cmpA.Uid)
+		require.Error(t, err)
+
+		var derefErr *storage.DereferenceError
+		require.ErrorAs(t, err, &derefErr)
+		assert.Equal(t, storage.DereferenceErrorCycle, derefErr.Kind)
+		assert.Equal(t, []storage.ReferencePathElement{
+			{NodeId: "", Uid: cmpA.Uid, Version: models.VersionInit},
+			{NodeId: "nA", Uid: cmpB.Uid, Version: models.VersionInit},
+			{NodeId: "nB3", Uid: cmpC.Uid, Version: models.VersionInit},
+			{NodeId: "nC", Uid: cmpA.Uid, Version: models.VersionInit},
+		}, derefErr.Path)
+		assert.Contains(t, err.Error(), "nB3 (uid: "+uidC)
+	})
+
+	t.Run("no cycle for repeated siblings", func(t *testing.T) {
+		acyclic := makeGraphCmp(uidB,
+			models.Node{Id: "nB1", Node: brickCmp.Uid},
+			models.Node{Id: "nB2", Node: brickCmp.Uid})
+		cmp, err := storage.DereferenceComponent(context.TODO(), client, acyclic)
+		require.NoError(t, err)
+		assert.Equal(t, brickCmp, cmp.Implementation.(models.Graph).Nodes[1].Node)
+	})
+
+	t.Run("max depth", func(t *testing.T) {
+		_, err := storage.DereferenceComponentWithMaxDepth(context.TODO(), client, cmpA.Uid, 1)
+		require.Error(t, err)
+
+		var derefErr *storage.DereferenceError
+		require.ErrorAs(t, err, &derefErr)
+		assert.Equal(t, storage.DereferenceErrorMaxDepth, derefErr.Kind)
+		assert.Len(t, derefErr.Path, 3)
+	})
+}
+
+func TestDereferenceComponentWithLockfile(t *testing.T) {
+	const uidG = "0f8c1bb4-5e4f-4b6c-9d0a-6c1d6a2f0a04"
+	graph := makeGraphCmp(uidG,
+		models.Node{Id: "N1", Node: mapNodeCmp1.Uid},
+		models.Node{Id: "N2", Node: brickCmp.Uid},
+		models.Node{Id: "N3", Node: mapNodeCmp1.Uid})
+
+	// version 2 of the map node is the latest
+	latest := mapNodeCmp2
+	latest.Version = models.Version{Current: 2, Tags: []string{models.VersionTagLatest}}
+	previous := mapNodeCmp1
+	previous.Version = models.Version{Current: 1}
+	client := memoryComponentClient{
+		cmps:     map[models.ComponentReference]models.Component{graph.Uid: graph, brickCmp.Uid: brickCmp, latest.Uid: latest},
+		versions: map[models.ComponentReference][]models.Component{previous.Uid: {previous}},
+	}
+
+	t.Run("record", func(t *testing.T) {
+		cmp, lockfile, err := storage.DereferenceComponentWithLockfile(context.TODO(), client, graph.Uid, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []models.CRefVersion{
+			{Uid: graph.Uid, Version: models.VersionInit},
+			{Uid: latest.Uid, Version: 2},
+			{Uid: brickCmp.Uid, Version: models.VersionInit},
+		}, lockfile)
+		assert.Equal(t, latest, cmp.Implementation.(models.Graph).Nodes[0].Node)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		pinned := []models.CRefVersion{{Uid: latest.Uid, Version: 1}}
+		cmp, lockfile, err := storage.DereferenceComponentWithLockfile(context.TODO(), client, graph, pinned)
+		require.NoError(t, err)
+		assert.Equal(t, []models.CRefVersion{
+			{Uid: latest.Uid, Version: 1},
+			{Uid: brickCmp.Uid, Version: models.VersionInit},
+		}, lockfile)
+		assert.Equal(t, previous, cmp.Implementation.(models.Graph).Nodes[0].Node)
+		assert.Equal(t, previous, cmp.Implementation.(models.Graph).Nodes[2].Node)
+	})
 }
@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedules(t *testing.T) {
+	c, err := storage.NewMongoScheduleClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	userAccessCtx := context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}})
+	ws := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}}
+	authCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, ws)
+
+	schedule := models.Schedule{
+		Metadata:  models.Metadata{Uid: models.NewComponentReference(), Name: "nightly", Timestamp: time.Now().UTC().Truncate(time.Millisecond)},
+		Type:      "schedule",
+		Workflow:  models.CRefVersion{Uid: models.NewComponentReference(), Version: models.VersionNumber(1)},
+		Workspace: "test",
+		Cron:      "@daily",
+	}
+
+	require.NoError(t, c.PutSchedule(authCtx, schedule))
+	assert.Equal(t, storage.ErrNoAccess, c.PutSchedule(userAccessCtx, schedule))
+
+	{
+		out, err := c.GetSchedule(authCtx, schedule.Uid)
+		require.NoError(t, err)
+		assert.Equal(t, schedule, out)
+
+		_, err = c.GetSchedule(userAccessCtx, schedule.Uid)
+		assert.Equal(t, storage.ErrNoAccess, err)
+	}
+
+	{
+		// suspend
+		schedule.Suspended = true
+		require.NoError(t, c.PutSchedule(authCtx, schedule))
+		list, err := c.ListSchedules(authCtx, storage.Pagination{Limit: 10}, []string{"uid[==]=" + schedule.Uid.String()}, nil)
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+		assert.True(t, list.Items[0].Suspended)
+
+		list, err = c.ListSchedules(userAccessCtx, storage.Pagination{Limit: 10}, []string{"uid[==]=" + schedule.Uid.String()}, nil)
+		require.NoError(t, err)
+		assert.NotNil(t, list.Items)
+		assert.Len(t, list.Items, 0)
+	}
+
+	assert.Equal(t, storage.ErrNoAccess, c.DeleteSchedule(userAccessCtx, schedule.Uid))
+	require.NoError(t, c.DeleteSchedule(authCtx, schedule.Uid))
+	_, err = c.GetSchedule(authCtx, schedule.Uid)
+	assert.Equal(t, storage.ErrNotFound, err)
+}
+
+func TestCreateScheduledJob(t *testing.T) {
+	c, err := storage.NewMongoScheduleClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	jobs, err := storage.NewMongoStorageClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	userAccessCtx := context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}})
+	ws := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}}
+	authCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, ws)
+
+	schedule := models.Schedule{
+		Metadata:  models.Metadata{Uid: models.NewComponentReference(), Name: "nightly"},
+		Type:      "schedule",
+		Workspace: "test",
+		Cron:      "@daily",
+		Job:       models.Job{Metadata: models.Metadata{Name: "nightly"}, Type: "job", Workflow: models.Workflow{Type: "workflow", Workspace: "test"}},
+	}
+	require.NoError(t, c.PutSchedule(authCtx, schedule))
+
+	// runs are not access checked
+	jobId := models.NewComponentReference()
+	job, err := c.CreateScheduledJob(context.TODO(), schedule.Uid, jobId)
+	require.NoError(t, err)
+	assert.Equal(t, jobId, job.Uid)
+	assert.Equal(t, "nightly", job.Name)
+	assert.Equal(t, &models.JobOrigin{Uid: schedule.Uid, Type: models.JobOriginSchedule}, job.Origin)
+
+	// the same run gives the same job
+	again, err := c.CreateScheduledJob(context.TODO(), schedule.Uid, jobId)
+	require.NoError(t, err)
+	assert.Equal(t, job, again)
+
+	stored, err := jobs.GetJob(authCtx, jobId)
+	require.NoError(t, err)
+	assert.Equal(t, job, stored)
+
+	_, err = c.CreateScheduledJob(context.TODO(), models.NewComponentReference(), models.NewComponentReference())
+	assert.Equal(t, storage.ErrNotFound, err)
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a field matched by a search, the weight ranks its matches against matches in other fields
+type searchField struct {
+	Path   string
+	Weight int
+}
+
+// the searched fields of a component, the bricks of graph, map and conditional nodes are searched one level down
+var componentSearchFields = []searchField{
+	{"name", 10},
+	{"description", 5},
+	{"inputs.name", 3},
+	{"outputs.name", 3},
+	{"implementation.container.image", 2},
+	{"implementation.nodes.node.implementation.container.image", 1},
+	{"implementation.node.implementation.container.image", 1},
+	{"implementation.nodeTrue.implementation.container.image", 1},
+	{"implementation.nodeFalse.implementation.container.image", 1},
+}
+
+// the searched fields of a workflow, its metadata and the fields of its component
+func workflowSearchFields() []searchField {
+	fields := []searchField{{"name", 10}, {"description", 5}}
+	for _, f := range componentSearchFields[2:] {
+		fields = append(fields, searchField{"component." + f.Path, f.Weight})
+	}
+	return fields
+}
+
+// the words of a text search, excluded words and the quotes of phrases are dropped
+func searchTerms(query string) []string {
+	terms := []string{}
+	for _, word := range strings.Fields(query) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		word = strings.TrimFunc(strings.ToLower(word), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if word != "" {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// matches the words starting with any of the terms
+func termsExpression(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)`)
+}
+
+// the string values at the dot separated path of a document, arrays along the path are flattened
+func documentValues(doc interface{}, path string) []string {
+	if path == "" {
+		if s, ok := doc.(string); ok {
+			return []string{s}
+		}
+		return nil
+	}
+	key, rest, _ := strings.Cut(path, ".")
+	switch d := doc.(type) {
+	case primitive.D:
+		for _, e := range d {
+			if e.Key == key {
+				return documentValues(e.Value, rest)
+			}
+		}
+	case primitive.M:
+		return documentValues(d[key], rest)
+	case primitive.A:
+		values := []string{}
+		for _, item := range d {
+			values = append(values, documentValues(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+const highlightContext = 60
+
+// the part of a value around its first match, with the matches in <em> tags
+func highlightFragment(value string, expr *regexp.Regexp) (string, int) {
+	matches := expr.FindAllStringIndex(value, -1)
+	if len(matches) == 0 {
+		return "", 0
+	}
+	start, end := 0, len(value)
+	if matches[0][0] > highlightContext {
+		start = matches[0][0] - highlightContext
+	}
+	if end-matches[0][1] > 2*highlightContext {
+		end = matches[0][1] + 2*highlightContext
+	}
+	for start > 0 && !utf8.RuneStart(value[start]) {
+		start--
+	}
+	for end < len(value) && !utf8.RuneStart(value[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(value[pos:m[0]]))
+		b.WriteString("<em>" + html.EscapeString(value[m[0]:m[1]]) + "</em>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(value[pos:end]))
+	if end < len(value) {
+		b.WriteString("…")
+	}
+	return b.String(), len(matches)
+}
+
+// the highlights of the matched fields of a document and the weighted number of matches
+func highlightDocument(doc primitive.D, fields []searchField, terms []string) ([]models.SearchHighlight, int) {
+	if len(terms) == 0 {
+		return nil, 0
+	}
+	expr := termsExpression(terms)
+	highlights := []models.SearchHighlight{}
+	score := 0
+	for _, f := range fields {
+		for _, value := range documentValues(doc, f.Path) {
+			fragment, n := highlightFragment(value, expr)
+			if n > 0 {
+				highlights = append(highlights, models.SearchHighlight{Field: f.Path, Fragment: fragment})
+				score += n * f.Weight
+			}
+		}
+	}
+	return highlights, score
+}
+
+func sortSearchResults(results []models.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Uid.String() < results[j].Uid.String()
+	})
+}
+
+func pageSearchResults(results []models.SearchResult, total int, pagination Pagination) models.SearchResultList {
+	sortSearchResults(results)
+	page := models.PageInfo{TotalNumber: total, Skip: pagination.Skip, Limit: pagination.Limit}
+	if pagination.Skip >= len(results) {
+		return models.SearchResultList{Items: []models.SearchResult{}, PageInfo: page}
+	}
+	results = results[pagination.Skip:]
+	if len(results) > pagination.Limit {
+		results = results[:pagination.Limit]
+	}
+	return models.SearchResultList{Items: results, PageInfo: page}
+}
+
+// the names of the workspaces the user of the context has access to
+func accessibleWorkspaces(ctx context.Context) []string {
+	usr := user.GetUser(ctx)
+	names := []string{}
+	for _, ws := range getWorkspacesFromContext(ctx) {
+		if ws.UserHasAccess(usr) {
+			names = append(names, ws.Name)
+		}
+	}
+	return names
+}
+
+// Implements storage.SearchClient in memory, ranking by the weighted number of matched words. Backs tests
+type LocalSearchClientImpl struct {
+	Components []models.Component
+	Workflows  []models.Workflow
+}
+
+func NewLocalSearchClient(components []models.Component, workflows []models.Workflow) *LocalSearchClientImpl {
+	return &LocalSearchClientImpl{Components: components, Workflows: workflows}
+}
+
+func (c *LocalSearchClientImpl) Search(ctx context.Context, query string, pagination Pagination) (models.SearchResultList, error) {
+	terms := searchTerms(query)
+	results := []models.SearchResult{}
+
+	// only the latest version of each document is searched
+	latest := map[models.ComponentReference]models.VersionNumber{}
+	for _, cmp := range c.Components {
+		if v, ok := latest[cmp.Uid]; !ok || cmp.Version.Current > v {
+			latest[cmp.Uid] = cmp.Version.Current
+		}
+	}
+	for _, wf := range c.Workflows {
+		if v, ok := latest[wf.Uid]; !ok || wf.Version.Current > v {
+			latest[wf.Uid] = wf.Version.Current
+		}
+	}
+
+	add := func(document interface{}, meta models.MetadataWorkspace, kind string, fields []searchField) error {
+		var doc primitive.D
+		raw, err := bson.Marshal(document)
+		if err == nil {
+			err = bson.Unmarshal(raw, &doc)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot search %s %s", kind, meta.Uid)
+		}
+		highlights, score := highlightDocument(doc, fields, terms)
+		if score > 0 {
+			results = append(results, models.SearchResult{MetadataWorkspace: meta, Kind: kind, Score: float64(score), Highlights: highlights})
+		}
+		return nil
+	}
+	for _, cmp := range c.Components {
+		if latest[cmp.Uid] != cmp.Version.Current {
+			continue
+		}
+		if err := add(cmp, models.MetadataWorkspace{Metadata: cmp.Metadata}, models.SearchKindComponent, componentSearchFields); err != nil {
+			return models.SearchResultList{}, err
+		}
+	}
+	workspaces := accessibleWorkspaces(ctx)
+	for _, wf := range c.Workflows {
+		if latest[wf.Uid] != wf.Version.Current || !contains(workspaces, wf.Workspace) {
+			continue
+		}
+		if err := add(wf, models.MetadataWorkspace{Metadata: wf.Metadata, Workspace: wf.Workspace}, models.SearchKindWorkflow, workflowSearchFields()); err != nil {
+			return models.SearchResultList{}, err
+		}
+	}
+
+	return pageSearchResults(results, len(results), pagination), nil
+}
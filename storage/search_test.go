@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_SearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"whale", "say", "c"}, searchTerms(`"Whale say" -cow c++`))
+	assert.Equal(t, []string{}, searchTerms(" -- "))
+}
+
+func Test_HighlightFragment(t *testing.T) {
+	expr := termsExpression([]string{"whale"})
+
+	fragment, n := highlightFragment("Whales & whalesay, not a narwhal", expr)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "<em>Whale</em>s &amp; <em>whale</em>say, not a narwhal", fragment)
+
+	_, n = highlightFragment("a narwhal", expr)
+	assert.Equal(t, 0, n)
+
+	long := "the first words of a long description before the interesting part of it, a whale, followed by more words than fit into the context of a highlight"
+	fragment, n = highlightFragment(long, expr)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "… of a long description before the interesting part of it, a <em>whale</em>, followed by more words than fit into the context of a highlight", fragment)
+}
+
+func makeSearchComponent(name string, description string, image string, version models.VersionNumber) models.Component {
+	return models.Component{
+		ComponentBase: models.ComponentBase{Type: "component",
+			Metadata: models.Metadata{Uid: models.NewComponentReference(), Name: name, Description: description, Version: models.Version{Current: version}},
+			Inputs:   []models.Data{{Name: "message", Type: "parameter"}}},
+		Implementation: models.Brick{ImplementationBase: models.ImplementationBase{Type: models.BrickType},
+			Container: &corev1.Container{Name: "containername", Image: image}}}
+}
+
+func Test_LocalSearchClient(t *testing.T) {
+	cowsay := makeSearchComponent("cowsay", "Says it with a cow", "docker/whalesay", 1)
+	whalesay := makeSearchComponent("whalesay", "Says it with a whale", "docker/whalesay", 1)
+	oldWhalesay := whalesay
+	oldWhalesay.Version.Current = 0
+	oldWhalesay.Description = "Says it with a whale, an old whale"
+	echo := makeSearchComponent("echo", "Prints the message", "alpine:latest", 1)
+
+	visible := models.Workflow{Metadata: models.Metadata{Uid: models.NewComponentReference(), Name: "whale-workflow", Version: models.Version{Current: 1}},
+		Component: cowsay, Type: "workflow", Workspace: "test"}
+	hidden := models.Workflow{Metadata: models.Metadata{Uid: models.NewComponentReference(), Name: "hidden-whale-workflow", Version: models.Version{Current: 1}},
+		Component: cowsay, Type: "workflow", Workspace: "hidden"}
+
+	client := NewLocalSearchClient([]models.Component{cowsay, oldWhalesay, whalesay, echo}, []models.Workflow{visible, hidden})
+
+	userCtx := context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}})
+	ctx := context.WithValue(userCtx, workspace.WorkspaceKey, []workspace.Workspace{
+		{Name: "test", Roles: [][]user.Role{{"tester"}}},
+		{Name: "hidden", Roles: [][]user.Role{{"admin"}}}})
+
+	t.Run("ranked", func(t *testing.T) {
+		list, err := client.Search(ctx, "whale", Pagination{Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, list.Items, 3)
+		assert.Equal(t, 3, list.PageInfo.TotalNumber)
+
+		// the name, the description and the image
+		assert.Equal(t, whalesay.Uid, list.Items[0].Uid)
+		assert.Equal(t, models.SearchKindComponent, list.Items[0].Kind)
+		assert.Equal(t, 17.0, list.Items[0].Score)
+		assert.Equal(t, []models.SearchHighlight{
+			{Field: "name", Fragment: "<em>whale</em>say"},
+			{Field: "description", Fragment: "Says it with a <em>whale</em>"},
+			{Field: "implementation.container.image", Fragment: "docker/<em>whale</em>say"},
+		}, list.Items[0].Highlights)
+
+		// the name and the image of its component
+		assert.Equal(t, visible.Uid, list.Items[1].Uid)
+		assert.Equal(t, models.SearchKindWorkflow, list.Items[1].Kind)
+		assert.Equal(t, "test", list.Items[1].Workspace)
+		assert.Equal(t, 12.0, list.Items[1].Score)
+
+		// only the image
+		assert.Equal(t, cowsay.Uid, list.Items[2].Uid)
+		assert.Equal(t, 2.0, list.Items[2].Score)
+	})
+
+	t.Run("paged", func(t *testing.T) {
+		list, err := client.Search(ctx, "whale", Pagination{Limit: 1, Skip: 1})
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+		assert.Equal(t, visible.Uid, list.Items[0].Uid)
+		assert.Equal(t, 3, list.PageInfo.TotalNumber)
+	})
+
+	t.Run("no access", func(t *testing.T) {
+		list, err := client.Search(userCtx, "workflow", Pagination{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, list.Items)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		list, err := client.Search(ctx, "narwhal", Pagination{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, list.Items)
+		assert.Equal(t, 0, list.PageInfo.TotalNumber)
+	})
+}
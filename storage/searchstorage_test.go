@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	mclient.Database(test_db_name).Drop(context.TODO())
+	// the unique indexes are not left to the other tests
+	defer mclient.Database(test_db_name).Drop(context.TODO())
+
+	indexes, err := storage.NewMongoIndexClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	_, err = indexes.EnsureIndexes(context.TODO())
+	require.NoError(t, err, "the search requires the text indexes")
+	cstorage, err := storage.NewMongoStorageClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	c, err := storage.NewMongoSearchClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+
+	userAccessCtx := context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}})
+	authCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, []workspace.Workspace{
+		{Name: "test", Roles: [][]user.Role{{"tester"}}},
+		{Name: "hidden", Roles: [][]user.Role{{"admin"}}}})
+
+	named := makeComponent(&models.Metadata{Uid: models.NewComponentReference(), Name: "whale", Description: "Says it with a whale"})
+	described := makeComponent(&models.Metadata{Uid: models.NewComponentReference(), Name: "cowsay", Description: "Says it like a whale would"})
+	unrelated := makeComponent(nil)
+	visible := makeWorkflow(&models.Metadata{Uid: models.NewComponentReference(), Name: "whale-workflow"}, "test")
+	hidden := makeWorkflow(&models.Metadata{Uid: models.NewComponentReference(), Name: "whale-workflow"}, "hidden")
+	for _, cmp := range []models.Component{named, described, unrelated} {
+		require.NoError(t, cstorage.CreateComponent(context.TODO(), cmp))
+	}
+	require.NoError(t, cstorage.CreateWorkflow(authCtx, visible))
+	hiddenCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, []workspace.Workspace{{Name: "hidden", Roles: [][]user.Role{{"tester"}}}})
+	require.NoError(t, cstorage.CreateWorkflow(hiddenCtx, hidden))
+
+	list, err := c.Search(authCtx, "whale", storage.Pagination{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 3)
+	assert.Equal(t, 3, list.PageInfo.TotalNumber)
+	assert.Equal(t, named.Uid, list.Items[0].Uid, "the name outranks the description")
+	assert.Equal(t, []models.SearchHighlight{
+		{Field: "name", Fragment: "<em>whale</em>"},
+		{Field: "description", Fragment: "Says it with a <em>whale</em>"},
+	}, list.Items[0].Highlights)
+	uids := []models.ComponentReference{list.Items[1].Uid, list.Items[2].Uid}
+	assert.ElementsMatch(t, []models.ComponentReference{visible.Uid, described.Uid}, uids, "the workflow of the hidden workspace is not found")
+
+	list, err = c.Search(authCtx, "whale", storage.Pagination{Limit: 1, Skip: 2})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, uids[1], list.Items[0].Uid)
+
+	list, err = c.Search(userAccessCtx, "workflow", storage.Pagination{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
@@ -11,6 +11,8 @@ import (
 type Pagination struct {
 	Limit int
 	Skip  int
+	// continues a listing after the last item of a previous page, see PageInfo.NextCursor
+	Cursor string
 }
 
 type ComponentClient interface {
@@ -34,18 +36,54 @@ type ComponentClient interface {
 
 	DeleteDocument(ctx context.Context, kind DocumentKind, id models.CRefVersion) (models.CRefVersion, error)
 
+	// the events of a job are not access checked, the job itself is
 	AddJobEvents(ctx context.Context, id models.ComponentReference, events []models.JobEvent) error
+	ListJobEvents(ctx context.Context, id models.ComponentReference) ([]models.JobEvent, error)
 }
 
 var (
 	ErrNotFound            = fmt.Errorf("not found")
 	ErrNoAccess            = fmt.Errorf("no access")
 	ErrNewerDocumentExists = fmt.Errorf("newer document exists")
+	ErrInvalidCursor       = fmt.Errorf("invalid pagination cursor")
 )
 
+// Searches the latest versions of the components and the workflows of the accessible workspaces
+type SearchClient interface {
+	Search(ctx context.Context, query string, pagination Pagination) (models.SearchResultList, error)
+}
+
+// Checks the indexes required by the queries of the storage clients
+type IndexClient interface {
+	// creates the missing indexes, reports the indexes still differing from the required ones
+	EnsureIndexes(ctx context.Context) (models.IndexReport, error)
+	IndexReport(ctx context.Context) (models.IndexReport, error)
+}
+
 type VolumeClient interface {
 	ListVolumes(ctx context.Context, pagination Pagination, filters []string, sorts []string) (models.FlowifyVolumeList, error)
 	GetVolume(ctx context.Context, id models.ComponentReference) (models.FlowifyVolume, error)
 	PutVolume(ctx context.Context, vol models.FlowifyVolume) error
 	DeleteVolume(ctx context.Context, id models.ComponentReference) error
 }
+
+type ScheduleClient interface {
+	ListSchedules(ctx context.Context, pagination Pagination, filters []string, sorts []string) (models.ScheduleList, error)
+	GetSchedule(ctx context.Context, id models.ComponentReference) (models.Schedule, error)
+	PutSchedule(ctx context.Context, schedule models.Schedule) error
+	DeleteSchedule(ctx context.Context, id models.ComponentReference) error
+
+	// CreateScheduledJob stores the job of a scheduled run from the job of the schedule. Runs are started
+	// without a user and are not access checked, creating the same job again returns the stored job
+	CreateScheduledJob(ctx context.Context, scheduleId models.ComponentReference, jobId models.ComponentReference) (models.Job, error)
+}
+
+type TriggerClient interface {
+	ListTriggers(ctx context.Context, pagination Pagination, filters []string, sorts []string) (models.TriggerList, error)
+	GetTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error)
+	PutTrigger(ctx context.Context, trigger models.Trigger) error
+	DeleteTrigger(ctx context.Context, id models.ComponentReference) error
+
+	// GetWebhookTrigger is not access checked, webhook calls are authorized by the signature of the url
+	GetWebhookTrigger(ctx context.Context, id models.ComponentReference) (models.Trigger, error)
+}
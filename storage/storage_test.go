@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/equinor/flowify-workflows-server/models"
 	"github.com/equinor/flowify-workflows-server/pkg/workspace"
 	"github.com/equinor/flowify-workflows-server/storage"
@@ -17,6 +18,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -449,6 +451,35 @@ func TestListComponents(t *testing.T) {
 		})
 	}
 
+	t.Run("cursor", func(t *testing.T) {
+		// the authors are shared, pages break within equal sort values
+		sorts := []string{"+modifiedBy.email", "-timestamp"}
+		seen := map[models.ComponentReference]bool{}
+		pagination := storage.Pagination{Limit: 4}
+		for page := 0; ; page++ {
+			list, err := cstorage.ListComponentsMetadata(context.TODO(), pagination, nil, sorts)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(list.Items), 4)
+			for _, item := range list.Items {
+				assert.False(t, seen[item.Uid], "listed twice: %s", item.Name)
+				seen[item.Uid] = true
+			}
+			if page == 0 {
+				// an insert before the cursor does not shift the following pages
+				require.NoError(t, cstorage.CreateComponent(context.TODO(),
+					makeComponent(&models.Metadata{Name: "test-inserted", Uid: models.NewComponentReference(),
+						ModifiedBy: models.ModifiedBy{Oid: "3", Email: "a@flowify.io"}, Timestamp: time.Now().UTC().Truncate(time.Second)})))
+			}
+			if list.PageInfo.NextCursor == "" {
+				break
+			}
+			pagination.Cursor = list.PageInfo.NextCursor
+		}
+		assert.Len(t, seen, 15)
+
+		_, err := cstorage.ListComponentsMetadata(context.TODO(), pagination, nil, []string{"+timestamp"})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	})
 }
 
 func TestPatchComponent(t *testing.T) {
@@ -481,6 +512,12 @@ func TestPatchComponent(t *testing.T) {
 		ExpectedError   bool
 	}
 
+	// stored by an earlier version of the server
+	_, err = mclient.Database(test_db_name).Collection("Components").UpdateOne(context.TODO(),
+		bson.D{bson.E{Key: "uid", Value: cmpV2.Uid}, bson.E{Key: "version.current", Value: cmpV2.Version.Current}},
+		bson.D{bson.E{Key: "$unset", Value: bson.D{bson.E{Key: "schemaVersion", Value: ""}}}})
+	require.NoError(t, err)
+
 	cmpV3 := cmpV2
 	cmpV3.Metadata.Timestamp = time.Now().In(time.UTC).Truncate(time.Millisecond)
 	cmpV3.Description = "new description"
@@ -522,6 +559,13 @@ func TestPatchComponent(t *testing.T) {
 		})
 	}
 
+	// the patched document is stamped with the current schema version
+	var stored struct {
+		SchemaVersion int `bson:"schemaVersion"`
+	}
+	require.NoError(t, mclient.Database(test_db_name).Collection("Components").FindOne(context.TODO(),
+		bson.D{bson.E{Key: "uid", Value: cmpV3.Uid}, bson.E{Key: "version.current", Value: cmpV3.Version.Current}}).Decode(&stored))
+	assert.Equal(t, storage.CurrentSchemaVersion, stored.SchemaVersion)
 }
 
 // Workflows
@@ -809,7 +853,7 @@ func TestListWorkflows(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 			authzCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, test.WorkspaceAccess)
-			list, err := cstorage.ListWorkflowsMetadata(authzCtx, storage.Pagination{20, 0}, test.Filters, test.Sorting)
+			list, err := cstorage.ListWorkflowsMetadata(authzCtx, storage.Pagination{Limit: 20, Skip: 0}, test.Filters, test.Sorting)
 			assert.Equal(t, err, test.ExpectedError)
 			assert.Equal(t, test.ExpectedSize, len(list.Items))
 			if len(list.Items) > 0 && test.Sorting != nil {
@@ -978,49 +1022,49 @@ func TestListJobs(t *testing.T) {
 		{Name: "No authz context",
 			Filters:         nil,
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 0},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 0},
 			WorkspaceAccess: nil,
 			ExpectedError:   nil,
 			ExpectedSize:    0},
 		{Name: "Good authz context",
 			Filters:         nil,
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 0},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 0},
 			WorkspaceAccess: []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    5},
 		{Name: "All authz contexts",
 			Filters:         nil,
 			Sorting:         nil,
-			Pagination:      storage.Pagination{20, 0},
+			Pagination:      storage.Pagination{Limit: 20, Skip: 0},
 			WorkspaceAccess: []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}, {Name: "test-2", Roles: [][]user.Role{{"tester"}}}, {Name: "test-3", Roles: [][]user.Role{{"tester"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    15},
 		{Name: "All authz contexts, offset",
 			Filters:         nil,
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 5},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 5},
 			WorkspaceAccess: []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}, {Name: "test-2", Roles: [][]user.Role{{"tester"}}}, {Name: "test-3", Roles: [][]user.Role{{"tester"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    10},
 		{Name: "Good authz context and filter",
 			Filters:         []string{"workflow.workspace[==]=test-2"},
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 0},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 0},
 			WorkspaceAccess: []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}, {Name: "test-2", Roles: [][]user.Role{{"tester"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    5},
 		{Name: "Authz context with name but no access",
 			Filters:         nil,
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 0},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 0},
 			WorkspaceAccess: []workspace.Workspace{{Name: "test-2", Roles: [][]user.Role{{"dummy"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    0},
 		{Name: "Authz context with similar name/access",
 			Filters:         []string{},
 			Sorting:         nil,
-			Pagination:      storage.Pagination{10, 0},
+			Pagination:      storage.Pagination{Limit: 10, Skip: 0},
 			WorkspaceAccess: []workspace.Workspace{{Name: "tes", Roles: [][]user.Role{{"tester"}}}},
 			ExpectedError:   nil,
 			ExpectedSize:    0,
@@ -1041,3 +1085,96 @@ func TestListJobs(t *testing.T) {
 		})
 	}
 }
+
+func TestJobEvents(t *testing.T) {
+	cstorage, err := storage.NewMongoStorageClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+
+	id := models.NewComponentReference()
+	t0 := time.Now().In(time.UTC).Truncate(time.Millisecond)
+	events := []models.JobEvent{
+		{Phase: "Running", Timestamp: t0},
+		{NodeId: "a", Name: "A", Phase: "Succeeded", Timestamp: t0.Add(time.Second)},
+	}
+	require.NoError(t, cstorage.AddJobEvents(context.TODO(), id, events[1:]))
+	require.NoError(t, cstorage.AddJobEvents(context.TODO(), id, events[:1]))
+	require.NoError(t, cstorage.AddJobEvents(context.TODO(), models.NewComponentReference(), events))
+
+	stored, err := cstorage.ListJobEvents(context.TODO(), id)
+	require.NoError(t, err)
+	for i := range events {
+		events[i].JobUid = id
+	}
+	assert.Equal(t, events, stored, "events of the job ordered by time")
+}
+
+func TestMigrateJobEvents(t *testing.T) {
+	cstorage, err := storage.NewMongoStorageClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	ws := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}}
+	authzCtx := context.WithValue(context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}}), workspace.WorkspaceKey, ws)
+
+	job := makeJob(nil, "test")
+	require.NoError(t, cstorage.CreateJob(authzCtx, job))
+
+	// the full workflow was stored for each event by earlier versions
+	started := time.Now().In(time.UTC).Truncate(time.Millisecond)
+	running := wfv1.Workflow{}
+	running.Status.Phase = wfv1.WorkflowRunning
+	running.Status.StartedAt.Time = started
+	succeeded := running
+	succeeded.Status.Phase = wfv1.WorkflowSucceeded
+	succeeded.Status.FinishedAt.Time = started.Add(time.Minute)
+	_, err = mclient.Database(test_db_name).Collection("Jobs").UpdateOne(context.TODO(),
+		bson.D{bson.E{Key: "uid", Value: job.Uid}},
+		bson.D{
+			bson.E{Key: "$set", Value: bson.D{bson.E{Key: "events", Value: []wfv1.Workflow{running, running, succeeded}}}},
+			bson.E{Key: "$unset", Value: bson.D{bson.E{Key: "schemaVersion", Value: ""}}},
+		})
+	require.NoError(t, err)
+
+	migratedJobs := func(reports []storage.MigrationReport) int {
+		for _, r := range reports {
+			if r.Collection == "Jobs" {
+				assert.Empty(t, r.Failed)
+				return r.Migrated
+			}
+		}
+		t.Fatal("no report of the jobs")
+		return 0
+	}
+
+	// a dry run leaves the documents as they are
+	reports, err := storage.Migrate(context.TODO(), mclient, test_db_name, storage.MigrationOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, migratedJobs(reports), 1)
+	outdated, err := storage.CountOutdatedDocuments(context.TODO(), mclient, test_db_name)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, outdated, 1)
+
+	reports, err = storage.Migrate(context.TODO(), mclient, test_db_name, storage.MigrationOptions{BatchSize: 1})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, migratedJobs(reports), 1)
+
+	events, err := cstorage.ListJobEvents(context.TODO(), job.Uid)
+	require.NoError(t, err)
+	assert.Equal(t, []models.JobEvent{
+		{JobUid: job.Uid, Phase: "Running", Timestamp: started},
+		{JobUid: job.Uid, Phase: "Succeeded", Timestamp: started.Add(time.Minute)},
+	}, events)
+
+	count, err := mclient.Database(test_db_name).Collection("Jobs").CountDocuments(context.TODO(), bson.D{bson.E{Key: "events", Value: bson.D{bson.E{Key: "$exists", Value: true}}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count, "events removed from the jobs")
+
+	reports, err = storage.Migrate(context.TODO(), mclient, test_db_name, storage.MigrationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, migratedJobs(reports))
+	outdated, err = storage.CountOutdatedDocuments(context.TODO(), mclient, test_db_name)
+	require.NoError(t, err)
+	assert.Equal(t, 0, outdated)
+
+	job_out, err := cstorage.GetJob(authzCtx, job.Uid)
+	require.NoError(t, err)
+	assert.Equal(t, job, job_out)
+}
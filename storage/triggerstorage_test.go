@@ -0,0 +1,54 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/equinor/flowify-workflows-server/storage"
+	"github.com/equinor/flowify-workflows-server/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriggers(t *testing.T) {
+	c, err := storage.NewMongoTriggerClientFromConfig(cfg, mclient)
+	require.NoError(t, err)
+	userAccessCtx := context.WithValue(context.TODO(), user.UserKey, user.MockUser{Uid: "0", Email: "test@author.com", Roles: []user.Role{"tester"}})
+	ws := []workspace.Workspace{{Name: "test", Roles: [][]user.Role{{"tester"}}}}
+	authCtx := context.WithValue(userAccessCtx, workspace.WorkspaceKey, ws)
+
+	trigger := models.Trigger{
+		Metadata:  models.Metadata{Uid: models.NewComponentReference(), Name: "landing", Timestamp: time.Now().UTC().Truncate(time.Millisecond)},
+		Type:      "trigger",
+		Workspace: "test",
+		Workflow:  models.CRefVersion{Uid: models.NewComponentReference()},
+		Mappings:  []models.TriggerMapping{{Target: "blob", Path: "data.url"}},
+		Secret:    "secret",
+	}
+
+	require.NoError(t, c.PutTrigger(authCtx, trigger))
+	assert.Equal(t, storage.ErrNoAccess, c.PutTrigger(userAccessCtx, trigger))
+
+	out, err := c.GetTrigger(authCtx, trigger.Uid)
+	require.NoError(t, err)
+	assert.Equal(t, trigger, out, "the secret is stored")
+
+	_, err = c.GetTrigger(userAccessCtx, trigger.Uid)
+	assert.Equal(t, storage.ErrNoAccess, err)
+
+	// webhook calls are not access checked
+	out, err = c.GetWebhookTrigger(context.TODO(), trigger.Uid)
+	require.NoError(t, err)
+	assert.Equal(t, trigger, out)
+
+	list, err := c.ListTriggers(authCtx, storage.Pagination{Limit: 10}, []string{"uid[==]=" + trigger.Uid.String()}, nil)
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+
+	require.NoError(t, c.DeleteTrigger(authCtx, trigger.Uid))
+	_, err = c.GetWebhookTrigger(context.TODO(), trigger.Uid)
+	assert.Equal(t, storage.ErrNotFound, err)
+}
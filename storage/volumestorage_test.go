@@ -304,7 +304,7 @@ func TestListVolumes(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 			authzCtx := context.WithValue(context.TODO(), workspace.WorkspaceKey, test.WorkspaceAccess)
-			list, err := c.ListVolumes(authzCtx, storage.Pagination{20, 0}, test.Filters, test.Sorting)
+			list, err := c.ListVolumes(authzCtx, storage.Pagination{Limit: 20, Skip: 0}, test.Filters, test.Sorting)
 			assert.Equal(t, err, test.ExpectedError)
 			assert.Equal(t, test.ExpectedSize, len(list.Items))
 			assert.Equal(t, test.ExpectedSize, list.PageInfo.TotalNumber)
@@ -1,7 +1,16 @@
 package transpiler
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/google/uuid"
+
 	// corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -12,6 +21,52 @@ const (
 	k8s     BrickType = "k8s"
 )
 
+// The template running the exit handler of a workflow
+const ExitHandlerName = "exit-handler"
+
+// The task names given to the subnodes of maps and conditionals
+const (
+	MapNodeName              = "mapnode"
+	ConditionalTrueNodeName  = "nodeTrue"
+	ConditionalFalseNodeName = "nodeFalse"
+	// lists the files iterated over by a map in directory mode
+	MapListNodeName = "listnode"
+	// bundles the artifacts of the iterations of a map
+	MapCollectNodeName = "collectnode"
+)
+
+// The default image of the helper pods, pinned so generated workflows do not change with the registry
+const DefaultHelperImage = "alpine:3.16"
+
+// The image of the helper pods of maps, listing the files of a directory and collecting the artifacts of the iterations.
+// Set from the server config at startup
+var HelperImage = DefaultHelperImage
+
+const (
+	listDirectoryArtifact = "directory"
+	listDirectoryPath     = "/tmp/flowify/directory"
+	collectPath           = "/tmp/flowify/collect"
+)
+
+// The prefix of the input parameters passing the status of a node to a conditional checking it
+const StatusParameterPrefix = "flowify-status-"
+
+// The input parameter passing the key the maps of a component collect the artifacts of their iterations under.
+// Each task invoking a component collecting artifacts extends the key by its name
+const CollectKeyParameter = "flowify-collect-key"
+
+// The input parameter passing the index of an iteration to the copy of the brick of a map collecting artifacts,
+// the artifacts of the iteration are stored under it. The items of the map carry the index in a field of the same name
+const CollectIndexParameter = "flowify-collect-index"
+
+// The default kind of the artifact repository of the workflows
+const DefaultArtifactRepository = "s3"
+
+// The kind of the artifact repository the iterations of maps store their collected artifacts in. The artifacts are
+// located by key only, argo fills in the rest of the location from the artifact repository of the workflow.
+// Set from the server config at startup
+var ArtifactRepository = DefaultArtifactRepository
+
 // --- Argo Brick---------------------------------------------------------------
 type BrickType string
 
@@ -38,3 +93,197 @@ func RemoveDuplicatedTemplates(templates []wfv1.Template) []wfv1.Template {
 	}
 	return utemplates
 }
+
+// argo names the expanded tasks of a withParam loop '<task>(<index>:<item>)'
+var iterationSuffix = regexp.MustCompile(`^\((\d+):(.*)\)$`)
+
+// GetJobNodeStatuses maps the node statuses of a workflow generated by the transpiler back to the flowify nodes.
+// Task names are the flowify node ids and template names are the component uids.
+// Retry attempts are folded into the retried node, map iterations are listed as separate nodes.
+func GetJobNodeStatuses(wf *wfv1.Workflow) []models.JobNodeStatus {
+	nodes := wf.Status.Nodes
+
+	// the children of task groups (map iterations) and retry nodes (attempts) are expansions of the same flowify node
+	expandedFrom := make(map[string]string)
+	for id, n := range nodes {
+		if n.Type == wfv1.NodeTypeTaskGroup || n.Type == wfv1.NodeTypeRetry {
+			for _, c := range n.Children {
+				expandedFrom[c] = id
+			}
+		}
+	}
+
+	// owner returns the id of the node reported for an argo node, attempts are reported by their retry node
+	owner := func(id string) string {
+		for {
+			parent, ok := expandedFrom[id]
+			if !ok || nodes[parent].Type != wfv1.NodeTypeRetry {
+				return id
+			}
+			id = parent
+		}
+	}
+
+	paths := make(map[string]string)
+	nodeIds := make(map[string]string)
+	var resolve func(id string) (string, string)
+	resolve = func(id string) (string, string) {
+		if p, ok := paths[id]; ok {
+			return p, nodeIds[id]
+		}
+		n := nodes[id]
+		var path, nodeId string
+		if parent, ok := expandedFrom[id]; ok {
+			path, nodeId = resolve(parent)
+			if nodes[parent].Type == wfv1.NodeTypeTaskGroup {
+				if index, _, ok := parseIteration(nodes[parent], n); ok {
+					path = fmt.Sprintf("%s[%d]", path, index)
+				}
+			}
+		} else if _, ok := nodes[n.BoundaryID]; ok {
+			path, _ = resolve(n.BoundaryID)
+			nodeId = n.DisplayName
+			if path != "" {
+				path = path + "."
+			}
+			path = path + nodeId
+		} else if id != wf.Name {
+			// nodes outside the entrypoint, e.g. exit handlers
+			nodeId = strings.TrimPrefix(n.Name, wf.Name+".")
+			path = nodeId
+		}
+		paths[id] = path
+		nodeIds[id] = nodeId
+		return path, nodeId
+	}
+
+	statuses := make(map[string]*models.JobNodeStatus)
+	for id, n := range nodes {
+		if owner(id) != id {
+			continue
+		}
+		path, nodeId := resolve(id)
+		status := models.JobNodeStatus{
+			Id:      id,
+			NodeId:  nodeId,
+			Path:    path,
+			Phase:   n.Phase,
+			Message: n.Message,
+			Outputs: n.Outputs,
+		}
+		if cref, ok := templateComponent(n.TemplateName); ok {
+			status.ComponentUid = cref
+		}
+		if !n.StartedAt.IsZero() {
+			t := n.StartedAt
+			status.StartedAt = &t
+		}
+		if !n.FinishedAt.IsZero() {
+			t := n.FinishedAt
+			status.FinishedAt = &t
+		}
+		if n.Type == wfv1.NodeTypeRetry && len(n.Children) > 1 {
+			status.Retries = len(n.Children) - 1
+		}
+		if parent, ok := expandedFrom[id]; ok && nodes[parent].Type == wfv1.NodeTypeTaskGroup {
+			if index, item, ok := parseIteration(nodes[parent], n); ok {
+				status.Iteration = &index
+				status.Item = item
+			}
+		}
+		statuses[id] = &status
+	}
+
+	// mark the branch taken on the conditionals
+	for id, status := range statuses {
+		if status.NodeId != ConditionalTrueNodeName && status.NodeId != ConditionalFalseNodeName {
+			continue
+		}
+		n := nodes[id]
+		if n.Type == wfv1.NodeTypeSkipped || n.Phase == wfv1.NodeSkipped || n.Phase == wfv1.NodeOmitted {
+			continue
+		}
+		if conditional, ok := statuses[owner(n.BoundaryID)]; ok {
+			conditional.Branch = status.NodeId
+		}
+	}
+
+	out := make([]models.JobNodeStatus, 0, len(statuses))
+	for _, s := range statuses {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Id < out[j].Id
+	})
+	return out
+}
+
+// parseIteration returns the index and item of an expanded withParam task
+func parseIteration(group wfv1.NodeStatus, n wfv1.NodeStatus) (int, string, bool) {
+	m := iterationSuffix.FindStringSubmatch(strings.TrimPrefix(n.DisplayName, group.DisplayName))
+	if m == nil {
+		return 0, "", false
+	}
+	index, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, "", false
+	}
+	return index, m[2], true
+}
+
+// templateComponent returns the component a template was generated from. Templates are named after the component uid,
+// the copies of the bricks of maps collecting artifacts are named '<brick uid>-<map uid>'
+func templateComponent(name string) (models.ComponentReference, bool) {
+	if copied := strings.SplitN(name, "-", 6); len(copied) == 6 {
+		if _, err := uuid.Parse(copied[5]); err == nil {
+			name = strings.TrimSuffix(name, "-"+copied[5])
+		}
+	}
+	cref, err := uuid.Parse(name)
+	if err != nil {
+		return models.ComponentReference{}, false
+	}
+	return models.ComponentReference(cref), true
+}
+
+// GetJobOutputArtifact returns the artifact produced for an output of the workflow component, nil until produced
+func GetJobOutputArtifact(wf *wfv1.Workflow, name string) *wfv1.Artifact {
+	// the workflow component is the entrypoint, its node is named after the workflow
+	root, ok := wf.Status.Nodes[wf.Name]
+	if !ok || root.Outputs == nil {
+		return nil
+	}
+	return root.Outputs.GetArtifactByName(name)
+}
+
+// GetJobOutputs returns the declared outputs of the workflow component with the values and artifacts produced.
+// Artifacts have the location recorded by argo, which may hold a key only
+func GetJobOutputs(outputs []models.Data, wf *wfv1.Workflow) []models.JobOutput {
+	root := wf.Status.Nodes[wf.Name]
+
+	result := make([]models.JobOutput, 0, len(outputs))
+	for _, output := range outputs {
+		jobOutput := models.JobOutput{Name: output.Name, Type: output.Type}
+		switch output.Type {
+		case models.FlowifyArtifactType:
+			if art := GetJobOutputArtifact(wf, output.Name); art != nil {
+				jobOutput.Artifact = art.ArtifactLocation.DeepCopy()
+			}
+		case models.FlowifyParameterType, models.FlowifyParameterArrayType:
+			if root.Outputs == nil {
+				break
+			}
+			for _, param := range root.Outputs.Parameters {
+				if param.Name == output.Name && param.Value != nil {
+					value := param.Value.String()
+					jobOutput.Value = &value
+				}
+			}
+		}
+		result = append(result, jobOutput)
+	}
+	return result
+}
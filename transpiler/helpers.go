@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"path"
 	"reflect"
+	"strconv"
+	"strings"
 
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/equinor/flowify-workflows-server/models"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -46,6 +49,16 @@ func findKeyFor[K string, V comparable](m map[K]V, value V) (key K, ok bool) {
 	return
 }
 
+// true if the value is in the slice
+func contains[T comparable](slice []T, value T) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // return the subset of secrets that are connected (via inputmappings) to the target node
 func getNodeSecretMap(targetNodeId string, cmpSecrets secretMap, cmpInputs []models.Data, inputsMap []models.Edge) secretMap {
 	nSecrets := mapCopy(cmpSecrets)
@@ -271,6 +284,44 @@ func getDependencies(edges []models.Edge, nodeId string) []string {
 	return deps
 }
 
+// getDepends formulates the argo depends expression of a node from its data and control edges
+func getDepends(edges []models.Edge, controlEdges []models.ControlEdge, nodeId string) (string, error) {
+	terms := []string{}
+	dataSources := make(map[string]bool)
+	for _, source := range getDependencies(edges, nodeId) {
+		if !dataSources[source] {
+			dataSources[source] = true
+			terms = append(terms, source)
+		}
+	}
+
+	for _, e := range controlEdges {
+		if e.Target != nodeId {
+			continue
+		}
+		var term string
+		switch e.Condition {
+		case "", models.ControlConditionSucceeded:
+			// as for data edges, also run when the source is skipped
+			if dataSources[e.Source] {
+				continue
+			}
+			term = e.Source
+		case models.ControlConditionFailed:
+			term = fmt.Sprintf("(%[1]s.Failed || %[1]s.Errored)", e.Source)
+		case models.ControlConditionAny:
+			term = fmt.Sprintf("(%[1]s.Succeeded || %[1]s.Skipped || %[1]s.Failed || %[1]s.Errored)", e.Source)
+		default:
+			return "", errors.Errorf("unknown condition '%s' of control edge %s -> %s", e.Condition, e.Source, e.Target)
+		}
+		if dataSources[e.Source] {
+			return "", errors.Errorf("node %s takes data from %s and cannot depend on it with condition '%s'", nodeId, e.Source, e.Condition)
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " && "), nil
+}
+
 func getContainerArgs(args []models.Argument) ([]string, error) {
 	argsStr := []string{}
 	for _, a := range args {
@@ -332,28 +383,328 @@ func volumeFromConfig(config string) (corev1.Volume, error) {
 	return vconf, nil
 }
 
-func expressionToString(expression models.Expression) (string, error) {
-	var expressionLeftValue string
-	switch expression.Left.(type) {
+// compileOperand formulates an operand as an argo (expr) expression, inputs are referenced by name
+func compileOperand(operand interface{}, compare models.ComparisonType) (string, error) {
+	switch v := operand.(type) {
 	case string:
-		expressionLeftValue = expression.Left.(string)
+		if compare == models.NumberComparison {
+			number, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", errors.Wrapf(err, "Incorrect number literal")
+			}
+			return strconv.FormatFloat(number, 'g', -1, 64), nil
+		}
+		return strconv.Quote(v), nil
+	case float64:
+		if compare == models.NumberComparison {
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+		return strconv.Quote(strconv.FormatFloat(v, 'g', -1, 64)), nil
 	case models.Data:
-		d := expression.Left.(models.Data)
-		expressionLeftValue = fmt.Sprintf("{{inputs.parameters.%s}}", d.Name)
+		ref := fmt.Sprintf("inputs.parameters[%s]", strconv.Quote(v.Name))
+		if compare == models.NumberComparison {
+			return fmt.Sprintf("asFloat(%s)", ref), nil
+		}
+		return ref, nil
+	case models.NodeStatus:
+		return fmt.Sprintf("inputs.parameters[%s]", strconv.Quote(StatusParameterPrefix+v.Node)), nil
 	default:
-		return "", errors.Errorf("Incorrect left value.")
+		return "", errors.Errorf("Incorrect operand type %T", operand)
 	}
-	var expressionRightValue string
-	switch expression.Right.(type) {
-	case string:
-		expressionRightValue = expression.Right.(string)
-	case models.Data:
-		d := expression.Right.(models.Data)
-		expressionRightValue = fmt.Sprintf("{{inputs.parameters.%s}}", d.Name)
+}
+
+// compileExpression formulates an expression as an argo (expr) expression, the expression should be checked beforehand
+func compileExpression(expression models.Expression) (string, error) {
+	for _, c := range []struct {
+		op   string
+		subs []models.Expression
+	}{{"&&", expression.And}, {"||", expression.Or}} {
+		if len(c.subs) == 0 {
+			continue
+		}
+		terms := []string{}
+		for _, sub := range c.subs {
+			term, err := compileExpression(sub)
+			if err != nil {
+				return "", err
+			}
+			terms = append(terms, fmt.Sprintf("(%s)", term))
+		}
+		return strings.Join(terms, " "+c.op+" "), nil
+	}
+	if expression.Not != nil {
+		term, err := compileExpression(*expression.Not)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("!(%s)", term), nil
+	}
+
+	compare := expression.ComparisonType()
+	left, err := compileOperand(expression.Left, compare)
+	if err != nil {
+		return "", errors.Wrap(err, "Incorrect left value")
+	}
+	right, err := compileOperand(expression.Right, compare)
+	if err != nil {
+		return "", errors.Wrap(err, "Incorrect right value")
+	}
+	return fmt.Sprintf("%s %s %s", left, expression.Operator, right), nil
+}
+
+// arrayRef decodes a parameter array input in an argo (expr) expression
+func arrayRef(name string) string {
+	return fmt.Sprintf("sprig.fromJson(inputs.parameters[%s])", strconv.Quote(name))
+}
+
+// indexField is the field of an item holding the index of the iteration, the objects of indexed items carry it
+func indexField(indexed bool) []string {
+	if !indexed {
+		return []string{}
+	}
+	return []string{fmt.Sprintf("%s: #", strconv.Quote(CollectIndexParameter))}
+}
+
+// zipExpression formulates the items of a zipping map: an object per index of the shortest array, keyed by the input names
+func zipExpression(names []string, indexed bool) string {
+	fields := indexField(indexed)
+	bounds := []string{}
+	for i, n := range names {
+		fields = append(fields, fmt.Sprintf("%s: %s[#]", strconv.Quote(n), arrayRef(n)))
+		if i > 0 {
+			bounds = append(bounds, fmt.Sprintf("# < len(%s)", arrayRef(n)))
+		}
+	}
+	indices := fmt.Sprintf("sprig.until(len(%s))", arrayRef(names[0]))
+	if len(bounds) > 0 {
+		indices = fmt.Sprintf("filter(%s, {%s})", indices, strings.Join(bounds, " && "))
+	}
+	// the spaces keep the braces of the closure and the object from closing the template tag
+	return fmt.Sprintf("{{=toJson(map(%s, { {%s} }))}}", indices, strings.Join(fields, ", "))
+}
+
+// productExpression formulates the items of a map over all combinations of the arrays, the last array varies fastest
+func productExpression(names []string, indexed bool) string {
+	lens := make([]string, len(names))
+	for i, n := range names {
+		lens[i] = fmt.Sprintf("len(%s)", arrayRef(n))
+	}
+	fields := indexField(indexed)
+	for i, n := range names {
+		index := "#"
+		if i < len(names)-1 {
+			index = fmt.Sprintf("asInt(# / (%s))", strings.Join(lens[i+1:], " * "))
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s[%s %% %s]", strconv.Quote(n), arrayRef(n), index, lens[i]))
+	}
+	return fmt.Sprintf("{{=toJson(map(sprig.until(%s), { {%s} }))}}", strings.Join(lens, " * "), strings.Join(fields, ", "))
+}
+
+// listDirectoryTemplate prints the relative paths of the files in a directory artifact as a json array.
+// With a field the paths are printed as objects holding the path in the field and the index of the file
+func listDirectoryTemplate(name string, field string) wfv1.Template {
+	item := `"\"%s\"", $0`
+	if field != "" {
+		// input names are plain words, they need no quoting in the script
+		item = fmt.Sprintf(`"{\"%s\": \"%%s\", \"%s\": %%d}", $0, NR - 1`, field, CollectIndexParameter)
+	}
+	return wfv1.Template{
+		Name:   name,
+		Inputs: wfv1.Inputs{Artifacts: []wfv1.Artifact{{Name: listDirectoryArtifact, Path: listDirectoryPath}}},
+		Script: &wfv1.ScriptTemplate{
+			Container: corev1.Container{Image: HelperImage, Command: []string{"sh"}},
+			Source: fmt.Sprintf(`cd %s && find . -type f | sed -e 's|^\./||' -e 's|\\|\\\\|g' -e 's|"|\\"|g' | sort | `+
+				`awk 'BEGIN { printf "[" } NR > 1 { printf "," } { printf %s } END { print "]" }'`, listDirectoryPath, item),
+		},
+	}
+}
+
+// The collect keys of the workflow entrypoint and the exit handler, the roots of the keys of their tasks
+const (
+	entrypointCollectKey  = "{{workflow.name}}/entrypoint"
+	exitHandlerCollectKey = "{{workflow.name}}/" + ExitHandlerName
+)
+
+// collectKey extends the collect key passed to a component by the name of a task or an output port
+func collectKey(name string) string {
+	return fmt.Sprintf("{{inputs.parameters.%s}}/%s", CollectKeyParameter, name)
+}
+
+// collectKeyInput is the input of the collect key of the templates of components collecting artifacts,
+// the entrypoint is not invoked by a task and takes the default
+func collectKeyInput() wfv1.Parameter {
+	return wfv1.Parameter{Name: CollectKeyParameter, Default: wfv1.AnyStringPtr(entrypointCollectKey)}
+}
+
+// collectKeyArguments adds the collect key to the arguments of a task invoking a component collecting artifacts,
+// extended by the name of the task so no two instances of a map store their iterations under the same key
+func collectKeyArguments(args wfv1.Arguments, task string, node models.Component) wfv1.Arguments {
+	if !collectsArtifacts(node) {
+		return args
+	}
+	params := append([]wfv1.Parameter{}, args.Parameters...)
+	params = append(params, wfv1.Parameter{Name: CollectKeyParameter, Value: wfv1.AnyStringPtr(collectKey(task))})
+	return wfv1.Arguments{Parameters: params, Artifacts: args.Artifacts}
+}
+
+// keyLocation returns the location of an artifact by key only in a kind of artifact repository.
+// Argo relocates it onto the artifact repository of the workflow, keeping the key
+func keyLocation(kind string, key string) (wfv1.ArtifactLocation, error) {
+	switch kind {
+	case "s3":
+		return wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: key}}, nil
+	case "gcs":
+		return wfv1.ArtifactLocation{GCS: &wfv1.GCSArtifact{Key: key}}, nil
+	case "oss":
+		return wfv1.ArtifactLocation{OSS: &wfv1.OSSArtifact{Key: key}}, nil
+	case "azure":
+		return wfv1.ArtifactLocation{Azure: &wfv1.AzureArtifact{Blob: key}}, nil
 	default:
-		return "", errors.Errorf("Incorrect right value.")
+		return wfv1.ArtifactLocation{}, errors.Errorf("Cannot store artifacts by key in a '%s' artifact repository", kind)
+	}
+}
+
+// ValidateArtifactRepository checks that artifacts can be stored by key in a kind of artifact repository
+func ValidateArtifactRepository(kind string) error {
+	_, err := keyLocation(kind, "")
+	return err
+}
+
+func collectTemplateName(mapName string) string {
+	return fmt.Sprintf("%s-collect", mapName)
+}
+
+// collectNodeTemplateName is the name of the copy of the brick of a map storing the artifacts of the iterations
+func collectNodeTemplateName(mapName string, node string) string {
+	return fmt.Sprintf("%s-%s", node, mapName)
+}
+
+// collectIterations checks that the outputs of an iterating map can hold the collected outputs of the iterations,
+// and adds the copy of the brick of the map storing the artifact outputs of the iterations under the collect key of the map
+// and the index of the iteration.
+// The brick template itself is shared with the other uses of the brick and left as is.
+// Only the outputs of bricks are stored in the artifact repository, so only they can be collected
+func collectIterations(mapName string, cmp models.Component, node models.Component, templates *[]wfv1.Template) error {
+	impl, ok := cmp.Implementation.(models.Map)
+	if !ok {
+		return errors.Errorf("Component %s is not a map", mapName)
+	}
+	iterating := false
+	ports := []string{}
+	for _, t := range *templates {
+		switch {
+		case t.Name == mapName && t.DAG != nil:
+			for _, task := range t.DAG.Tasks {
+				iterating = iterating || (task.Name == MapNodeName && task.WithParam != "")
+			}
+		case t.Name == collectTemplateName(mapName):
+			for _, a := range t.Outputs.Artifacts {
+				ports = append(ports, a.Name)
+			}
+		}
+	}
+	if !iterating {
+		return nil
 	}
 
-	expressionStr := fmt.Sprintf("%s %s %s", expressionLeftValue, expression.Operator, expressionRightValue)
-	return expressionStr, nil
+	for _, m := range impl.OutputMappings {
+		st, _ := checkInputType(node.Outputs, m.Source.Port)
+		tt, _ := checkInputType(cmp.Outputs, m.Target.Port)
+		expected := models.FlowifyParameterArrayType
+		if st == models.FlowifyArtifactType {
+			expected = models.FlowifyArtifactType
+		}
+		if tt != expected {
+			return errors.Errorf("Output '%s' of map '%s' collects the %s '%s' of the iterations, it has to be a %s", m.Target.Port, mapName, st, m.Source.Port, expected)
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil
+	}
+	for _, t := range *templates {
+		if t.Name != node.Uid.String() {
+			continue
+		}
+		if t.Container == nil {
+			return errors.Errorf("Cannot collect the artifacts of map '%s', only the outputs of a brick can be collected", mapName)
+		}
+		copied := t.DeepCopy()
+		copied.Name = collectNodeTemplateName(mapName, node.Uid.String())
+		copied.Inputs.Parameters = append(copied.Inputs.Parameters, collectKeyInput(), wfv1.Parameter{Name: CollectIndexParameter})
+		for j := range copied.Outputs.Artifacts {
+			a := &copied.Outputs.Artifacts[j]
+			if contains(ports, a.Name) {
+				// stored as is under the index of the iteration, a retried iteration replaces the artifact of its failed attempt
+				location, err := keyLocation(ArtifactRepository, fmt.Sprintf("%s/{{inputs.parameters.%s}}", collectKey(a.Name), CollectIndexParameter))
+				if err != nil {
+					return errors.Wrapf(err, "Cannot collect the artifacts of map '%s'", mapName)
+				}
+				a.ArtifactLocation = location
+				a.Archive = &wfv1.ArchiveStrategy{None: &wfv1.NoneStrategy{}}
+			}
+		}
+		*templates = append(*templates, *copied)
+		return nil
+	}
+	return errors.Errorf("Cannot collect the artifacts of map '%s', no template for its node", mapName)
+}
+
+// collectTemplate bundles the artifacts stored by the iterations of a map into a directory artifact per output,
+// with an entry per iteration named by its index. Without iterations the directories are empty
+func collectTemplate(name string, ports []string) (wfv1.Template, error) {
+	inputs := []wfv1.Artifact{}
+	outputs := []wfv1.Artifact{}
+	dirs := []string{}
+	for _, port := range ports {
+		dir := path.Join(collectPath, port)
+		location, err := keyLocation(ArtifactRepository, collectKey(port))
+		if err != nil {
+			return wfv1.Template{}, err
+		}
+		inputs = append(inputs, wfv1.Artifact{
+			Name:             port,
+			Path:             dir,
+			Optional:         true,
+			ArtifactLocation: location,
+		})
+		outputs = append(outputs, wfv1.Artifact{Name: port, Path: dir})
+		dirs = append(dirs, dir)
+	}
+	return wfv1.Template{
+		Name:      name,
+		Inputs:    wfv1.Inputs{Parameters: []wfv1.Parameter{collectKeyInput()}, Artifacts: inputs},
+		Outputs:   wfv1.Outputs{Artifacts: outputs},
+		Container: &corev1.Container{Image: HelperImage, Command: []string{"mkdir", "-p"}, Args: dirs},
+	}, nil
+}
+
+// collectsArtifacts returns true if the component tree contains a map collecting artifacts
+func collectsArtifacts(cmp models.Component) bool {
+	switch impl := cmp.Implementation.(type) {
+	case models.Map:
+		node, ok := impl.Node.(models.Component)
+		if !ok {
+			return false
+		}
+		for _, m := range impl.OutputMappings {
+			if t, _ := checkInputType(node.Outputs, m.Source.Port); t == models.FlowifyArtifactType {
+				return true
+			}
+		}
+		return collectsArtifacts(node)
+	case models.Graph:
+		for _, n := range impl.Nodes {
+			if c, ok := n.Node.(models.Component); ok && collectsArtifacts(c) {
+				return true
+			}
+		}
+	case models.Conditional:
+		for _, branch := range []interface{}{impl.NodeTrue, impl.NodeFalse} {
+			if c, ok := branch.(models.Component); ok && collectsArtifacts(c) {
+				return true
+			}
+		}
+	}
+	return false
 }
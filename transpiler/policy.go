@@ -0,0 +1,110 @@
+package transpiler
+
+import (
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/equinor/flowify-workflows-server/models"
+	"github.com/equinor/flowify-workflows-server/pkg/workspace"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func retryStrategy(retry *models.RetryPolicy) *wfv1.RetryStrategy {
+	if retry == nil {
+		return nil
+	}
+	limit := intstr.FromInt(int(retry.Limit))
+	strategy := wfv1.RetryStrategy{Limit: &limit, RetryPolicy: wfv1.RetryPolicy(retry.RetryPolicy)}
+	if b := retry.Backoff; b != nil {
+		strategy.Backoff = &wfv1.Backoff{Duration: b.Duration, MaxDuration: b.MaxDuration}
+		if b.Factor != nil {
+			factor := intstr.FromInt(int(*b.Factor))
+			strategy.Backoff.Factor = &factor
+		}
+	}
+	return &strategy
+}
+
+// applyBrickPolicy sets the execution policy of a brick on its container template
+func applyBrickPolicy(t *wfv1.Template, policy *models.ExecutionPolicy) {
+	if policy == nil {
+		return
+	}
+	t.RetryStrategy = retryStrategy(policy.Retry)
+	if policy.ActiveDeadlineSeconds != nil {
+		deadline := intstr.FromInt(int(*policy.ActiveDeadlineSeconds))
+		t.ActiveDeadlineSeconds = &deadline
+	}
+	t.Timeout = policy.Timeout
+	t.PriorityClassName = policy.PriorityClassName
+}
+
+// applyWorkflowPolicy sets the execution policy of a workflow on the generated argo workflow.
+// The retry and timeout are defaults for the container templates, retrying the dag templates would rerun whole graphs
+func applyWorkflowPolicy(awf *wfv1.Workflow, policy *models.WorkflowPolicy) {
+	if policy == nil {
+		return
+	}
+	for i, t := range awf.Spec.Templates {
+		if t.Container == nil {
+			continue
+		}
+		if t.RetryStrategy == nil {
+			awf.Spec.Templates[i].RetryStrategy = retryStrategy(policy.Retry)
+		}
+		if t.Timeout == "" {
+			awf.Spec.Templates[i].Timeout = policy.Timeout
+		}
+	}
+
+	awf.Spec.ActiveDeadlineSeconds = policy.ActiveDeadlineSeconds
+	awf.Spec.PodPriorityClassName = policy.PriorityClassName
+	if ttl := policy.TTL; ttl != nil {
+		awf.Spec.TTLStrategy = &wfv1.TTLStrategy{
+			SecondsAfterCompletion: ttl.SecondsAfterCompletion,
+			SecondsAfterSuccess:    ttl.SecondsAfterSuccess,
+			SecondsAfterFailure:    ttl.SecondsAfterFailure,
+		}
+	}
+	if policy.PodGC != "" {
+		awf.Spec.PodGC = &wfv1.PodGC{Strategy: wfv1.PodGCStrategy(policy.PodGC)}
+	}
+}
+
+// ApplySchedulingPolicy merges the scheduling policy of the workspace into the container and script templates.
+// Returns a *workspace.ResourceLimitError if a container exceeds the allowed resources
+func ApplySchedulingPolicy(awf *wfv1.Workflow, policy workspace.SchedulingPolicy) error {
+	for i := range awf.Spec.Templates {
+		t := &awf.Spec.Templates[i]
+		container := t.Container
+		if t.Script != nil {
+			// e.g. listing the files a map iterates over
+			container = &t.Script.Container
+		}
+		if container == nil {
+			continue
+		}
+
+		resources, err := policy.Resources(container.Resources)
+		if err != nil {
+			return errors.Wrapf(err, "container of brick %s", t.Name)
+		}
+		container.Resources = resources
+
+		if len(policy.NodeSelector) > 0 {
+			selector := make(map[string]string, len(t.NodeSelector)+len(policy.NodeSelector))
+			for k, v := range t.NodeSelector {
+				selector[k] = v
+			}
+			// the workspace takes precedence
+			for k, v := range policy.NodeSelector {
+				selector[k] = v
+			}
+			t.NodeSelector = selector
+		}
+		t.Tolerations = append(t.Tolerations, policy.Tolerations...)
+		if policy.ServiceAccountName != "" {
+			t.ServiceAccountName = policy.ServiceAccountName
+		}
+	}
+	return nil
+}
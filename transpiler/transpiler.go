@@ -3,6 +3,7 @@ package transpiler
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
@@ -84,12 +85,20 @@ func AddBrick(name string, cmp *models.Brick, outputs wfv1.Outputs, inputs wfv1.
 		Inputs:    inputs,
 		Outputs:   outputs,
 	}
+	applyBrickPolicy(&t, cmp.Policy)
 	*templates = append(*templates, t)
 	return nil
 }
 
 func AddGraph(name string, cmp *models.Graph, outputs wfv1.Outputs, inputs wfv1.Inputs, templates *[]wfv1.Template) error {
 	tasks := []wfv1.DAGTask{}
+	for _, e := range cmp.ControlEdges {
+		for _, id := range []string{e.Source, e.Target} {
+			if _, err := getNode(id, cmp.Nodes); err != nil {
+				return errors.Wrapf(err, "Cannot add control edge %s -> %s", e.Source, e.Target)
+			}
+		}
+	}
 	for _, node := range cmp.Nodes {
 		tmpCmp, ok := node.Node.(models.Component)
 		if !ok {
@@ -162,14 +171,30 @@ func AddGraph(name string, cmp *models.Graph, outputs wfv1.Outputs, inputs wfv1.
 			}
 		}
 
+		if c, ok := tmpCmp.Implementation.(models.Conditional); ok {
+			for _, s := range c.Expression.StatusNodes() {
+				val := fmt.Sprintf("{{tasks.%s.status}}", s)
+				argParams = append(argParams, wfv1.Parameter{Name: StatusParameterPrefix + s, Value: wfv1.AnyStringPtr(val)})
+			}
+		}
+
 		args := wfv1.Arguments{Parameters: argParams, Artifacts: argArtifacts}
 		t := wfv1.DAGTask{
 			Template:     template,
 			Name:         n,
 			Dependencies: deps,
-			Arguments:    args,
+			Arguments:    collectKeyArguments(args, n, tmpCmp),
 			WithParam:    withParam,
 		}
+		// argo does not allow dependencies and depends in the same dag
+		if len(cmp.ControlEdges) > 0 {
+			depends, err := getDepends(cmp.Edges, cmp.ControlEdges, n)
+			if err != nil {
+				return err
+			}
+			t.Dependencies = nil
+			t.Depends = depends
+		}
 		tasks = append(tasks, t)
 	}
 
@@ -204,9 +229,29 @@ func AddMap(name string, cmp *models.Map, outputs wfv1.Outputs, inputs wfv1.Inpu
 		t := reflect.TypeOf(cmp.Node)
 		return errors.Errorf("Cannot unmarshal map subnode %s. Object type: %s", name, t)
 	}
+	mode := cmp.Mode
+	if mode == "" {
+		mode = models.IterationModeSingle
+	}
+	over := func(port string) bool {
+		if len(cmp.Over) == 0 {
+			return true
+		}
+		return contains(cmp.Over, port)
+	}
+
+	// the items of a map collecting artifacts are objects keyed by the iterated inputs, carrying the index of the iteration
+	collecting := false
+	for _, m := range cmp.OutputMappings {
+		st, _ := checkInputType(tmpCmp.Outputs, m.Source.Port)
+		collecting = collecting || (st == models.FlowifyArtifactType && outputs.GetArtifactByName(m.Target.Port) != nil)
+	}
+
 	argParams := []wfv1.Parameter{}
 	argArtifacts := []wfv1.Artifact{}
-	withParam := ""
+	// the inputs of the map iterated over
+	iteratedParams := []string{}
+	iteratedArtifacts := []string{}
 	// Get params and artifacts from input mapping
 	for _, m := range cmp.InputMappings {
 		p1 := inputs.GetParameterByName(m.Source.Port)
@@ -216,11 +261,28 @@ func AddMap(name string, cmp *models.Map, outputs wfv1.Outputs, inputs wfv1.Inpu
 			switch it {
 			case models.FlowifyArtifactType:
 				val := fmt.Sprintf("{{inputs.artifacts.%s}}", m.Source.Port)
-				argArtifacts = append(argArtifacts, wfv1.Artifact{Name: m.Target.Port, From: val})
+				artifact := wfv1.Artifact{Name: m.Target.Port, From: val}
+				if mode == models.IterationModeDirectory && over(m.Source.Port) {
+					artifact.SubPath = "{{item}}"
+					if collecting {
+						artifact.SubPath = fmt.Sprintf("{{item.%s}}", m.Source.Port)
+					}
+					if !contains(iteratedArtifacts, m.Source.Port) {
+						iteratedArtifacts = append(iteratedArtifacts, m.Source.Port)
+					}
+				}
+				argArtifacts = append(argArtifacts, artifact)
 			case models.FlowifyParameterType:
-				if p1.Value != nil { // if value is set it means that value comes from parameter array
-					withParam = fmt.Sprintf("{{inputs.parameters.%s}}", m.Source.Port)
-					argParams = append(argParams, wfv1.Parameter{Name: m.Target.Port, Value: wfv1.AnyStringPtr("{{item}}")})
+				// if value is set it means that value comes from parameter array
+				if p1 != nil && p1.Value != nil && mode != models.IterationModeDirectory && over(m.Source.Port) {
+					item := "{{item}}"
+					if collecting || mode == models.IterationModeZip || mode == models.IterationModeProduct {
+						item = fmt.Sprintf("{{item.%s}}", m.Source.Port)
+					}
+					argParams = append(argParams, wfv1.Parameter{Name: m.Target.Port, Value: wfv1.AnyStringPtr(item)})
+					if !contains(iteratedParams, m.Source.Port) {
+						iteratedParams = append(iteratedParams, m.Source.Port)
+					}
 				} else {
 					val := fmt.Sprintf("{{inputs.parameters.%s}}", m.Source.Port)
 					argParams = append(argParams, wfv1.Parameter{Name: m.Target.Port, Value: wfv1.AnyStringPtr(val)})
@@ -233,37 +295,127 @@ func AddMap(name string, cmp *models.Map, outputs wfv1.Outputs, inputs wfv1.Inpu
 			}
 		}
 	}
+	for _, port := range cmp.Over {
+		if !contains(iteratedParams, port) && !contains(iteratedArtifacts, port) {
+			return errors.Errorf("Cannot iterate over '%s' in %s mode, it is not mapped onto the node of map '%s'", port, mode, name)
+		}
+	}
+
 	args := wfv1.Arguments{Parameters: argParams, Artifacts: argArtifacts}
-	nodeName := "mapnode"
-	tasks := []wfv1.DAGTask{
-		{
-			Template:  tmpCmp.Uid.String(),
-			Name:      nodeName,
-			Arguments: args,
-			WithParam: withParam,
-		},
+	nodeName := MapNodeName
+	task := wfv1.DAGTask{
+		Template:  tmpCmp.Uid.String(),
+		Name:      nodeName,
+		Arguments: collectKeyArguments(args, nodeName, tmpCmp),
+	}
+	tasks := []wfv1.DAGTask{}
+	switch mode {
+	case models.IterationModeSingle:
+		if len(iteratedParams) > 1 {
+			return errors.Errorf("Map '%s' iterates over a single parameter array, use zip or product mode to iterate over %s", name, strings.Join(iteratedParams, ", "))
+		}
+		if len(iteratedParams) == 1 {
+			task.WithParam = fmt.Sprintf("{{inputs.parameters.%s}}", iteratedParams[0])
+			if collecting {
+				task.WithParam = zipExpression(iteratedParams, true)
+			}
+		}
+	case models.IterationModeZip, models.IterationModeProduct:
+		if len(iteratedParams) == 0 {
+			return errors.Errorf("Map '%s' has no parameter arrays to iterate over in %s mode", name, mode)
+		}
+		if mode == models.IterationModeZip {
+			task.WithParam = zipExpression(iteratedParams, collecting)
+		} else {
+			task.WithParam = productExpression(iteratedParams, collecting)
+		}
+	case models.IterationModeDirectory:
+		if len(iteratedArtifacts) != 1 {
+			return errors.Errorf("Map '%s' iterates over the files of exactly one artifact in directory mode, found %d", name, len(iteratedArtifacts))
+		}
+		listName := fmt.Sprintf("%s-list", name)
+		field := ""
+		if collecting {
+			field = iteratedArtifacts[0]
+		}
+		*templates = append(*templates, listDirectoryTemplate(listName, field))
+		tasks = append(tasks, wfv1.DAGTask{
+			Template: listName,
+			Name:     MapListNodeName,
+			Arguments: wfv1.Arguments{Artifacts: []wfv1.Artifact{
+				{Name: listDirectoryArtifact, From: fmt.Sprintf("{{inputs.artifacts.%s}}", iteratedArtifacts[0])},
+			}},
+		})
+		task.Dependencies = []string{MapListNodeName}
+		task.WithParam = fmt.Sprintf("{{tasks.%s.outputs.result}}", MapListNodeName)
+	default:
+		return errors.Errorf("Unrecognized iteration mode '%s' of map '%s'", mode, name)
+	}
+	nodeTask := len(tasks)
+	tasks = append(tasks, task)
+	iterating := task.WithParam != ""
+	if iterating && collectsArtifacts(tmpCmp) {
+		// the iterations would store their artifacts under the same keys
+		return errors.Errorf("Map '%s' cannot iterate over a map collecting artifacts", name)
 	}
 
-	// Get output params and artifacts from output mapping
+	// Get output params and artifacts from output mapping.
+	// The outputs of the iterations are collected: parameters into a parameter array, parameter arrays into an array of arrays
+	// and artifacts into a directory with an entry per iteration named by its index
+	collected := []string{}
 	for _, m := range cmp.OutputMappings {
+		st, _ := checkInputType(tmpCmp.Outputs, m.Source.Port)
 		for paramCtr, param := range outputs.Parameters {
 			if m.Target.Port == param.Name {
-				outputs.Parameters[paramCtr].ValueFrom = &wfv1.ValueFrom{Parameter: fmt.Sprintf("{{tasks.%s.outputs.parameters.%s}}", nodeName, m.Source.Port)}
+				if iterating && st == models.FlowifyParameterArrayType {
+					// argo collects the arrays as json strings
+					expression := fmt.Sprintf("toJson(map(sprig.fromJson(tasks.%s.outputs.parameters[%s]), {sprig.fromJson(#)}))", nodeName, strconv.Quote(m.Source.Port))
+					outputs.Parameters[paramCtr].ValueFrom = &wfv1.ValueFrom{Expression: expression}
+				} else {
+					outputs.Parameters[paramCtr].ValueFrom = &wfv1.ValueFrom{Parameter: fmt.Sprintf("{{tasks.%s.outputs.parameters.%s}}", nodeName, m.Source.Port)}
+				}
 			}
 		}
 		for artifactCtr, artifact := range outputs.Artifacts {
 			if m.Target.Port == artifact.Name {
-				outputs.Artifacts[artifactCtr].From = fmt.Sprintf("{{tasks.%s.outputs.artifacts.%s}}", nodeName, m.Source.Port)
+				if iterating {
+					if !contains(collected, m.Source.Port) {
+						collected = append(collected, m.Source.Port)
+					}
+					outputs.Artifacts[artifactCtr].From = fmt.Sprintf("{{tasks.%s.outputs.artifacts.%s}}", MapCollectNodeName, m.Source.Port)
+				} else {
+					outputs.Artifacts[artifactCtr].From = fmt.Sprintf("{{tasks.%s.outputs.artifacts.%s}}", nodeName, m.Source.Port)
+				}
 			}
 		}
 	}
+	if len(collected) > 0 {
+		// the iterations run a copy of the brick storing the collected artifacts under the collect key of the map and their index
+		key := wfv1.Parameter{Name: CollectKeyParameter, Value: wfv1.AnyStringPtr(fmt.Sprintf("{{inputs.parameters.%s}}", CollectKeyParameter))}
+		tasks[nodeTask].Template = collectNodeTemplateName(name, tmpCmp.Uid.String())
+		index := wfv1.Parameter{Name: CollectIndexParameter, Value: wfv1.AnyStringPtr(fmt.Sprintf("{{item.%s}}", CollectIndexParameter))}
+		tasks[nodeTask].Arguments.Parameters = append(tasks[nodeTask].Arguments.Parameters, key, index)
+		collectName := collectTemplateName(name)
+		collect, err := collectTemplate(collectName, collected)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot collect the artifacts of map '%s'", name)
+		}
+		*templates = append(*templates, collect)
+		tasks = append(tasks, wfv1.DAGTask{
+			Template:     collectName,
+			Name:         MapCollectNodeName,
+			Dependencies: []string{nodeName},
+			Arguments:    wfv1.Arguments{Parameters: []wfv1.Parameter{key}},
+		})
+	}
 
 	dag := wfv1.DAGTemplate{Tasks: tasks}
 	template := wfv1.Template{
-		Name:    name,
-		DAG:     &dag,
-		Inputs:  inputs,
-		Outputs: outputs,
+		Name:        name,
+		DAG:         &dag,
+		Inputs:      inputs,
+		Outputs:     outputs,
+		Parallelism: cmp.Parallelism,
 	}
 	*templates = append(*templates, template)
 	return nil
@@ -327,27 +479,31 @@ func AddConditional(name string, cmp *models.Conditional, outputs wfv1.Outputs,
 			}
 		}
 	}
-	expressionStr, err := expressionToString(cmp.Expression)
+	expressionStr, err := compileExpression(cmp.Expression)
 	if err != nil {
 		return errors.Wrapf(err, "Cannot convert expression to string in conditional node '%s'", name)
 	}
+	// the statuses of the checked nodes are passed in by the enclosing graph
+	for _, node := range cmp.Expression.StatusNodes() {
+		inputs.Parameters = append(inputs.Parameters, wfv1.Parameter{Name: StatusParameterPrefix + node})
+	}
 	args := wfv1.Arguments{Parameters: argParams, Artifacts: argArtifacts}
 	tasks := []wfv1.DAGTask{
 		{
 			Template:  tmpCmpTrue.Uid.String(),
-			Name:      "nodeTrue",
-			Arguments: args,
+			Name:      ConditionalTrueNodeName,
+			Arguments: collectKeyArguments(args, ConditionalTrueNodeName, tmpCmpTrue),
 			WithParam: withParam,
-			When:      expressionStr,
+			When:      fmt.Sprintf("{{=%s}}", expressionStr),
 		},
 	}
 	if cmp.NodeFalse != nil {
 		falseNodeTask := wfv1.DAGTask{
 			Template:  tmpCmpFalse.Uid.String(),
-			Name:      "nodeFalse",
-			Arguments: args,
+			Name:      ConditionalFalseNodeName,
+			Arguments: collectKeyArguments(args, ConditionalFalseNodeName, tmpCmpFalse),
 			WithParam: withParam,
-			When:      fmt.Sprintf("!(%s)", expressionStr),
+			When:      fmt.Sprintf("{{=!(%s)}}", expressionStr),
 		}
 		tasks = append(tasks, falseNodeTask)
 	}
@@ -360,7 +516,7 @@ func AddConditional(name string, cmp *models.Conditional, outputs wfv1.Outputs,
 				if cmp.NodeFalse != nil {
 					nodeFalseOutput = fmt.Sprintf("tasks.nodeFalse.outputs.parameters.%s", m.Source.Port)
 				}
-				valueFrom := fmt.Sprintf("%s ? tasks.nodeTrue.outputs.parameters.%s : %s", expressionStr, m.Source.Port, nodeFalseOutput)
+				valueFrom := fmt.Sprintf("(%s) ? tasks.nodeTrue.outputs.parameters.%s : %s", expressionStr, m.Source.Port, nodeFalseOutput)
 				outputs.Parameters[paramCtr].ValueFrom = &wfv1.ValueFrom{Expression: valueFrom}
 			}
 		}
@@ -370,7 +526,7 @@ func AddConditional(name string, cmp *models.Conditional, outputs wfv1.Outputs,
 				if cmp.NodeFalse != nil {
 					nodeFalseOutput = fmt.Sprintf("tasks.nodeFalse.outputs.artifacts.%s", m.Source.Port)
 				}
-				valueFrom := fmt.Sprintf("%s ? tasks.nodeTrue.outputs.artifacts.%s : %s", expressionStr, m.Source.Port, nodeFalseOutput)
+				valueFrom := fmt.Sprintf("(%s) ? tasks.nodeTrue.outputs.artifacts.%s : %s", expressionStr, m.Source.Port, nodeFalseOutput)
 				outputs.Artifacts[artifactCtr].FromExpression = valueFrom
 			}
 		}
@@ -434,6 +590,9 @@ func TraverseComponent(cmp *models.Component, templates *[]wfv1.Template, tasks
 			return nil, fmt.Errorf("cannot append input data (name: %s, type %s) at node %s", i.Name, i.Type, cmpName)
 		}
 	}
+	if collectsArtifacts(*cmp) {
+		inParams = append(inParams, collectKeyInput())
+	}
 	inputs := wfv1.Inputs{
 		Parameters: inParams,
 		Artifacts:  inArtifacts,
@@ -519,6 +678,9 @@ func TraverseComponent(cmp *models.Component, templates *[]wfv1.Template, tasks
 		if err != nil {
 			return nil, err
 		}
+		if err := collectIterations(cmpName, *cmp, tc, templates); err != nil {
+			return nil, err
+		}
 	case models.Graph:
 		err := AddGraph(cmpName, &impCmp, outputs, inputs, templates)
 		if err != nil {
@@ -578,11 +740,59 @@ func TraverseComponent(cmp *models.Component, templates *[]wfv1.Template, tasks
 	return nil, nil
 }
 
+// AddExitHandler adds the templates of the exit handler of a workflow. The handler is wrapped in a dag
+// passing the outcome of the job and the workflow inputs on to it
+func AddExitHandler(wf models.Workflow, templates *[]wfv1.Template, secrets secretMap, volumes volumeMap) error {
+	if err := wf.ValidateOnExit(); err != nil {
+		return err
+	}
+	cmp, ok := wf.OnExit.Node.(models.Component)
+	if !ok {
+		return errors.Errorf("Cannot unmarshal exit handler node id: %s", wf.OnExit.Id)
+	}
+	_, err := TraverseComponent(&cmp, templates, &[]wfv1.DAGTask{}, secrets, volumes)
+	if err != nil {
+		return err
+	}
+
+	params := []wfv1.Parameter{}
+	for _, in := range cmp.Inputs {
+		if in.Type != models.FlowifyParameterType {
+			// secrets and volumes are passed by name
+			continue
+		}
+		var val string
+		switch in.Name {
+		case models.ExitStatusInput:
+			val = "{{workflow.status}}"
+		case models.ExitFailuresInput:
+			val = "{{workflow.failures}}"
+		default:
+			val = fmt.Sprintf("{{workflow.parameters.%s}}", in.Name)
+		}
+		params = append(params, wfv1.Parameter{Name: in.Name, Value: wfv1.AnyStringPtr(val)})
+	}
+	if collectsArtifacts(cmp) {
+		params = append(params, wfv1.Parameter{Name: CollectKeyParameter, Value: wfv1.AnyStringPtr(exitHandlerCollectKey)})
+	}
+
+	task := wfv1.DAGTask{
+		Name:      wf.OnExit.Id,
+		Template:  cmp.Uid.String(),
+		Arguments: wfv1.Arguments{Parameters: params},
+	}
+	*templates = append(*templates, wfv1.Template{Name: ExitHandlerName, DAG: &wfv1.DAGTemplate{Tasks: []wfv1.DAGTask{task}}})
+	return nil
+}
+
 func ParseComponentTree(wf models.Workflow, secrets secretMap, volumes volumeMap, labels map[string]string, annotations map[string]string) (*wfv1.Workflow, error) {
 	argoWF := GenerateArgo(wf.Metadata.Name, wf.Workspace, labels, annotations)
 	templates := make([]wfv1.Template, 0)
 	tasks := []wfv1.DAGTask{}
 
+	if err := wf.ValidateExpressions(); err != nil {
+		return nil, err
+	}
 	_, err := TraverseComponent(&wf.Component, &templates, &tasks, secrets, volumes)
 	if err != nil {
 		return nil, err
@@ -590,8 +800,15 @@ func ParseComponentTree(wf models.Workflow, secrets secretMap, volumes volumeMap
 
 	argoWF.Spec = wfv1.WorkflowSpec{
 		Entrypoint: wf.Component.Uid.String(),
-		Templates:  templates,
 	}
+	if wf.OnExit != nil {
+		if err := AddExitHandler(wf, &templates, secrets, volumes); err != nil {
+			return nil, errors.Wrap(err, "Cannot add exit handler into argo workflow")
+		}
+		argoWF.Spec.OnExit = ExitHandlerName
+	}
+	argoWF.Spec.Templates = templates
+	applyWorkflowPolicy(argoWF, wf.Policy)
 
 	return argoWF, nil
 }
@@ -9,12 +9,14 @@ import (
 	// "log"
 	"testing"
 
-	// wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/equinor/flowify-workflows-server/models"
 	"github.com/equinor/flowify-workflows-server/pkg/secret"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -492,6 +494,15 @@ func ignore[T any](arg1 T) {}
 
 func first[T1 any, T2 any](arg1 T1, arg2 T2) T1 { return arg1 }
 
+func parameterByName(outputs wfv1.Outputs, name string) *wfv1.Parameter {
+	for _, p := range outputs.Parameters {
+		if p.Name == name {
+			return &p
+		}
+	}
+	return nil
+}
+
 func Test_TranspileIfStatement(t *testing.T) {
 
 	raw, err := os.ReadFile("../models/examples/if-statement.json")
@@ -508,8 +519,9 @@ func Test_TranspileIfStatement(t *testing.T) {
 	assert.Equal(t, 4, len(argoWF.Spec.Templates), "we expect 4 templates, cf. example file")
 	template := argoWF.Spec.Templates[2] // template containing if statement
 	assert.Equal(t, 1, len(template.DAG.Tasks), "we expect one node in conditional DAG")
-	assert.NotEqual(t, "", template.DAG.Tasks[0].When)
-	assert.NotEqual(t, "", template.Outputs.Parameters[0].ValueFrom.Expression)
+	assert.Equal(t, `{{=asFloat(inputs.parameters["valFromParam"]) >= 5}}`, template.DAG.Tasks[0].When)
+	assert.Equal(t, `(asFloat(inputs.parameters["valFromParam"]) >= 5) ? tasks.nodeTrue.outputs.parameters.out : ""`,
+		template.Outputs.Parameters[0].ValueFrom.Expression)
 }
 
 func Test_TranspileIfElseStatement(t *testing.T) {
@@ -528,7 +540,540 @@ func Test_TranspileIfElseStatement(t *testing.T) {
 	assert.Equal(t, 5, len(argoWF.Spec.Templates), "we expect 4 templates, cf. example file")
 	template := argoWF.Spec.Templates[2] // template containing if statement
 	assert.Equal(t, 2, len(template.DAG.Tasks), "we expect two nodes in conditional DAG")
-	assert.NotEqual(t, "", template.DAG.Tasks[0].When)
-	assert.NotEqual(t, "", template.DAG.Tasks[1].When)
+	assert.Equal(t, `{{=asFloat(inputs.parameters["valFromParam"]) >= 5}}`, template.DAG.Tasks[0].When)
+	assert.Equal(t, `{{=!(asFloat(inputs.parameters["valFromParam"]) >= 5)}}`, template.DAG.Tasks[1].When)
 	assert.NotEqual(t, "", template.Outputs.Parameters[0].ValueFrom.Expression)
 }
+
+func Test_TranspilePolicy(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/policy-workflow.json")
+	assert.Nil(t, err)
+	var wf models.Workflow
+	err = json.Unmarshal(raw, &wf)
+	assert.Nil(t, err)
+
+	argoWF, err := GetArgoWorkflow(models.Job{Type: "job", Workflow: wf})
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(86400), *argoWF.Spec.ActiveDeadlineSeconds)
+	assert.Equal(t, "high-priority", argoWF.Spec.PodPriorityClassName)
+	assert.Equal(t, int32(3600), *argoWF.Spec.TTLStrategy.SecondsAfterSuccess)
+	assert.Nil(t, argoWF.Spec.TTLStrategy.SecondsAfterCompletion)
+	assert.Equal(t, wfv1.PodGCOnPodSuccess, argoWF.Spec.PodGC.Strategy)
+	assert.Nil(t, argoWF.Spec.RetryStrategy, "the workflow retry is not applied to the dag templates")
+
+	assert.Equal(t, 3, len(argoWF.Spec.Templates))
+	for _, template := range argoWF.Spec.Templates {
+		switch template.Name {
+		case "4e2b5c2a-9d0b-4c1e-8f3a-6b1d7e9a0c10":
+			assert.Nil(t, template.RetryStrategy)
+			assert.Equal(t, "", template.Timeout)
+		case "4e2b5c2a-9d0b-4c1e-8f3a-6b1d7e9a0c11":
+			// the workflow defaults
+			assert.Equal(t, "2", template.RetryStrategy.Limit.String())
+			assert.Nil(t, template.RetryStrategy.Backoff)
+			assert.Equal(t, "30m", template.Timeout)
+			assert.Nil(t, template.ActiveDeadlineSeconds)
+			assert.Equal(t, "", template.PriorityClassName)
+		case "4e2b5c2a-9d0b-4c1e-8f3a-6b1d7e9a0c12":
+			assert.Equal(t, "5", template.RetryStrategy.Limit.String())
+			assert.Equal(t, wfv1.RetryPolicyOnError, template.RetryStrategy.RetryPolicy)
+			assert.Equal(t, wfv1.Backoff{Duration: "30s", Factor: &intstr.IntOrString{IntVal: 2}, MaxDuration: "10m"}, *template.RetryStrategy.Backoff)
+			assert.Equal(t, "2h", template.Timeout)
+			assert.Equal(t, "3600", template.ActiveDeadlineSeconds.String())
+			assert.Equal(t, "low-priority", template.PriorityClassName)
+		default:
+			t.Errorf("Unexpected template %s.", template.Name)
+		}
+	}
+}
+
+func Test_TranspileControlEdges(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/control-edges-graph-component.json")
+	require.NoError(t, err)
+	var cmp models.Component
+	require.NoError(t, json.Unmarshal(raw, &cmp))
+	wf := models.Workflow{Metadata: models.Metadata{Name: "control-edges"}, Type: "workflow", Workspace: "test", Component: cmp}
+
+	argoWF, err := GetArgoWorkflow(models.Job{Type: "job", Workflow: wf})
+	require.NoError(t, err)
+
+	var dag *wfv1.DAGTemplate
+	for _, template := range argoWF.Spec.Templates {
+		if template.DAG != nil {
+			dag = template.DAG
+		}
+	}
+	require.NotNil(t, dag)
+	depends := map[string]string{}
+	for _, task := range dag.Tasks {
+		assert.Empty(t, task.Dependencies, "argo does not allow dependencies and depends in the same dag")
+		depends[task.Name] = task.Depends
+	}
+	assert.Equal(t, map[string]string{
+		"loader":  "",
+		"cleanup": "(loader.Succeeded || loader.Skipped || loader.Failed || loader.Errored)",
+		"alert":   "(loader.Failed || loader.Errored)",
+		"report":  "loader && cleanup",
+	}, depends)
+
+	t.Run("unknown node", func(t *testing.T) {
+		graph := cmp.Implementation.(models.Graph)
+		graph.ControlEdges = []models.ControlEdge{{Source: "loader", Target: "nobody"}}
+		bad := wf
+		bad.Component.Implementation = graph
+		_, err := GetArgoWorkflow(models.Job{Type: "job", Workflow: bad})
+		assert.Error(t, err)
+	})
+}
+
+func Test_TranspileStatusConditional(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/status-conditional-graph-component.json")
+	require.NoError(t, err)
+	var cmp models.Component
+	require.NoError(t, json.Unmarshal(raw, &cmp))
+	wf := models.Workflow{Metadata: models.Metadata{Name: "status-conditional"}, Type: "workflow", Workspace: "test", Component: cmp}
+
+	argoWF, err := GetArgoWorkflow(models.Job{Type: "job", Workflow: wf, InputValues: []models.Value{{Target: "threshold", Value: "3"}, {Target: "mode", Value: "full"}}})
+	require.NoError(t, err)
+
+	graph := cmp.Implementation.(models.Graph)
+	check := graph.Nodes[1].Node.(models.Component)
+	templates := map[string]wfv1.Template{}
+	for _, template := range argoWF.Spec.Templates {
+		templates[template.Name] = template
+	}
+
+	// the graph passes the status of the loader on to the conditional
+	task := templates[cmp.Uid.String()].DAG.Tasks[1]
+	assert.Equal(t, "check", task.Name)
+	assert.Equal(t, "(loader.Succeeded || loader.Skipped || loader.Failed || loader.Errored)", task.Depends)
+	status := task.Arguments.GetParameterByName(StatusParameterPrefix + "loader")
+	require.NotNil(t, status)
+	assert.Equal(t, "{{tasks.loader.status}}", status.Value.String())
+
+	conditional := templates[check.Uid.String()]
+	assert.NotNil(t, conditional.Inputs.GetParameterByName(StatusParameterPrefix+"loader"))
+	assert.Equal(t, `{{=((inputs.parameters["flowify-status-loader"] == "Failed") || (inputs.parameters["flowify-status-loader"] == "Error")) && `+
+		`(asFloat(inputs.parameters["threshold"]) > 2) && (!(inputs.parameters["mode"] matches "^dry-?run$"))}}`,
+		conditional.DAG.Tasks[0].When)
+
+	t.Run("malformed expression", func(t *testing.T) {
+		bad := check
+		c := bad.Implementation.(models.Conditional)
+		c.Expression = models.Expression{Left: models.Data{Name: "size", Type: models.FlowifyParameterType}, Operator: "==", Right: "5"}
+		bad.Implementation = c
+		_, err := GetArgoWorkflow(models.Job{Type: "job", Workflow: models.Workflow{Component: bad}})
+		var exprErr *models.ExpressionError
+		assert.ErrorAs(t, err, &exprErr)
+	})
+}
+
+func Test_TranspileMapIteration(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/zip-map-component.json")
+	require.NoError(t, err)
+	var cmp models.Component
+	require.NoError(t, json.Unmarshal(raw, &cmp))
+
+	withIteration := func(mode models.IterationMode, over ...string) models.Workflow {
+		m := cmp.Implementation.(models.Map)
+		m.Mode = mode
+		m.Over = over
+		c := cmp
+		c.Implementation = m
+		return models.Workflow{Metadata: models.Metadata{Name: "map-iteration"}, Type: "workflow", Workspace: "test", Component: c}
+	}
+
+	testCases := []struct {
+		Name              string
+		Workflow          models.Workflow
+		ExpectedWithParam string
+		ExpectedArgs      map[string]string // parameter values and artifact subpaths of the iterations
+		ExpectedError     bool
+	}{
+		{"zip", withIteration(models.IterationModeZip), zipExpression([]string{"names", "sizes"}, false),
+			map[string]string{"name": "{{item.names}}", "size": "{{item.sizes}}", "image": ""}, false},
+		{"product", withIteration(models.IterationModeProduct), productExpression([]string{"names", "sizes"}, false),
+			map[string]string{"name": "{{item.names}}", "size": "{{item.sizes}}", "image": ""}, false},
+		{"single", withIteration(models.IterationModeSingle, "sizes"), "{{inputs.parameters.sizes}}",
+			map[string]string{"name": "{{inputs.parameters.names}}", "size": "{{item}}", "image": ""}, false},
+		{"directory", withIteration(models.IterationModeDirectory), "{{tasks.listnode.outputs.result}}",
+			map[string]string{"name": "{{inputs.parameters.names}}", "size": "{{inputs.parameters.sizes}}", "image": "{{item}}"}, false},
+		{"single over several arrays", withIteration(""), "", nil, true},
+		{"zip over artifact", withIteration(models.IterationModeZip, "names", "images"), "", nil, true},
+		{"directory over parameter", withIteration(models.IterationModeDirectory, "names"), "", nil, true},
+		{"unknown mode", withIteration("random"), "", nil, true},
+	}
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			argoWF, err := ParseComponentTree(test.Workflow, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var template wfv1.Template
+			for _, tmpl := range argoWF.Spec.Templates {
+				if tmpl.Name == cmp.Uid.String() {
+					template = tmpl
+				}
+			}
+			require.NotNil(t, template.DAG)
+			assert.Equal(t, int64(2), *template.Parallelism)
+			task := template.DAG.Tasks[len(template.DAG.Tasks)-1]
+			assert.Equal(t, MapNodeName, task.Name)
+			assert.Equal(t, test.ExpectedWithParam, task.WithParam)
+
+			args := map[string]string{}
+			for _, p := range task.Arguments.Parameters {
+				args[p.Name] = p.Value.String()
+			}
+			for _, a := range task.Arguments.Artifacts {
+				assert.Equal(t, "{{inputs.artifacts.images}}", a.From)
+				args[a.Name] = a.SubPath
+			}
+			assert.Equal(t, test.ExpectedArgs, args)
+
+			if test.Workflow.Component.Implementation.(models.Map).Mode == models.IterationModeDirectory {
+				// the files are listed before the iterations
+				require.Len(t, template.DAG.Tasks, 2)
+				list := template.DAG.Tasks[0]
+				assert.Equal(t, MapListNodeName, list.Name)
+				assert.Equal(t, []string{MapListNodeName}, task.Dependencies)
+				assert.Equal(t, "{{inputs.artifacts.images}}", list.Arguments.Artifacts[0].From)
+				assert.Len(t, argoWF.Spec.Templates, 3)
+			}
+		})
+	}
+}
+
+func Test_TranspileMapOutputs(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/collect-map-component.json")
+	require.NoError(t, err)
+	var cmp models.Component
+	require.NoError(t, json.Unmarshal(raw, &cmp))
+	mapName := cmp.Uid.String()
+	node := cmp.Implementation.(models.Map).Node.(models.Component)
+
+	argoWF, err := ParseComponentTree(models.Workflow{Metadata: models.Metadata{Name: "collect"}, Component: cmp}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+	require.NoError(t, err)
+	templates := map[string]wfv1.Template{}
+	for _, template := range argoWF.Spec.Templates {
+		templates[template.Name] = template
+	}
+	require.Len(t, templates, 4)
+
+	// parameters are collected by argo, the arrays of the iterations are decoded
+	outputs := templates[mapName].Outputs
+	assert.Equal(t, "{{tasks.mapnode.outputs.parameters.size}}", parameterByName(outputs, "sizes").ValueFrom.Parameter)
+	assert.Equal(t, `toJson(map(sprig.fromJson(tasks.mapnode.outputs.parameters["tags"]), {sprig.fromJson(#)}))`,
+		parameterByName(outputs, "tags").ValueFrom.Expression)
+
+	// the iterations store their artifacts under the collect key of the map, bundled by the collect task
+	assert.Equal(t, "{{tasks.collectnode.outputs.artifacts.thumbnail}}", outputs.GetArtifactByName("thumbnails").From)
+	mapInputs := templates[mapName].Inputs
+	assert.Equal(t, entrypointCollectKey, mapInputs.GetParameterByName(CollectKeyParameter).Default.String())
+	tasks := templates[mapName].DAG.Tasks
+	require.Len(t, tasks, 2)
+	assert.Equal(t, MapCollectNodeName, tasks[1].Name)
+	assert.Equal(t, []string{MapNodeName}, tasks[1].Dependencies)
+	key := "{{inputs.parameters.flowify-collect-key}}"
+	for _, task := range tasks {
+		assert.Equal(t, key, task.Arguments.GetParameterByName(CollectKeyParameter).Value.String())
+	}
+
+	// the items carry the index of the iteration, it is passed to the copy of the brick
+	assert.Equal(t, zipExpression([]string{"names"}, true), tasks[0].WithParam)
+	assert.Equal(t, "{{item.names}}", tasks[0].Arguments.GetParameterByName("name").Value.String())
+	assert.Equal(t, "{{item.flowify-collect-index}}", tasks[0].Arguments.GetParameterByName(CollectIndexParameter).Value.String())
+
+	// the iterations run a copy of the brick, the brick template is shared with its other uses
+	copyName := collectNodeTemplateName(mapName, node.Uid.String())
+	assert.Equal(t, copyName, tasks[0].Template)
+	brick := templates[node.Uid.String()]
+	assert.Nil(t, brick.Outputs.GetArtifactByName("thumbnail").S3)
+	assert.Len(t, brick.Inputs.Parameters, 1)
+	copied := templates[copyName]
+	assert.NotNil(t, copied.Inputs.GetParameterByName(CollectIndexParameter))
+	thumbnail := copied.Outputs.GetArtifactByName("thumbnail")
+	assert.Equal(t, key+"/thumbnail/{{inputs.parameters.flowify-collect-index}}", thumbnail.S3.Key)
+	assert.NotNil(t, thumbnail.Archive.None)
+	assert.Equal(t, brick.Container, copied.Container)
+	collect := templates[collectTemplateName(mapName)]
+	assert.Equal(t, key+"/thumbnail", collect.Inputs.Artifacts[0].S3.Key)
+	assert.Equal(t, collect.Inputs.Artifacts[0].Path, collect.Outputs.GetArtifactByName("thumbnail").Path)
+
+	t.Run("instances", func(t *testing.T) {
+		// the same map twice and its brick on its own in a graph
+		graph := models.Component{
+			ComponentBase: models.ComponentBase{
+				Metadata: models.Metadata{Uid: models.NewComponentReference()},
+				Type:     "component",
+				Inputs:   []models.Data{{Name: "names", Type: models.FlowifyParameterArrayType}, {Name: "name", Type: models.FlowifyParameterType}},
+			},
+			Implementation: models.Graph{
+				ImplementationBase: models.ImplementationBase{Type: "graph"},
+				Nodes:              []models.Node{{Id: "first", Node: cmp}, {Id: "second", Node: cmp}, {Id: "single", Node: node}},
+				InputMappings: []models.Edge{
+					{Source: models.PortAddress{Port: "names"}, Target: models.PortAddress{Node: "first", Port: "names"}},
+					{Source: models.PortAddress{Port: "names"}, Target: models.PortAddress{Node: "second", Port: "names"}},
+					{Source: models.PortAddress{Port: "name"}, Target: models.PortAddress{Node: "single", Port: "name"}},
+				},
+			},
+		}
+		argoWF, err := ParseComponentTree(models.Workflow{Component: graph}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		require.NoError(t, err)
+		templates := map[string]wfv1.Template{}
+		for _, template := range RemoveDuplicatedTemplates(argoWF.Spec.Templates) {
+			templates[template.Name] = template
+		}
+		assert.Len(t, templates, 5)
+		brick := templates[node.Uid.String()]
+		assert.Nil(t, brick.Outputs.GetArtifactByName("thumbnail").S3)
+
+		tasks := templates[graph.Uid.String()].DAG.Tasks
+		require.Len(t, tasks, 3)
+		for _, task := range tasks {
+			param := task.Arguments.GetParameterByName(CollectKeyParameter)
+			if task.Name == "single" {
+				assert.Nil(t, param)
+				assert.Equal(t, node.Uid.String(), task.Template)
+				continue
+			}
+			assert.Equal(t, key+"/"+task.Name, param.Value.String())
+		}
+	})
+
+	t.Run("exit handler", func(t *testing.T) {
+		// exit handlers take no parameter arrays, the map runs once
+		once := cmp
+		once.Inputs = []models.Data{{Name: "names", Type: models.FlowifyParameterType}}
+		once.Outputs = []models.Data{{Name: "thumbnails", Type: models.FlowifyArtifactType}}
+		argoWF, err := ParseComponentTree(models.Workflow{Component: once, OnExit: &models.Node{Id: "cleanup", Node: once}}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		require.NoError(t, err)
+		for _, template := range argoWF.Spec.Templates {
+			if template.Name == ExitHandlerName {
+				assert.Equal(t, exitHandlerCollectKey, template.DAG.Tasks[0].Arguments.GetParameterByName(CollectKeyParameter).Value.String())
+			}
+		}
+	})
+
+	t.Run("artifact repository", func(t *testing.T) {
+		defer func() { ArtifactRepository = DefaultArtifactRepository }()
+		ArtifactRepository = "gcs"
+		argoWF, err := ParseComponentTree(models.Workflow{Component: cmp}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		require.NoError(t, err)
+		for _, template := range argoWF.Spec.Templates {
+			if template.Name == collectTemplateName(mapName) {
+				assert.Nil(t, template.Inputs.Artifacts[0].S3)
+				assert.Equal(t, key+"/thumbnail", template.Inputs.Artifacts[0].GCS.Key)
+			}
+		}
+
+		ArtifactRepository = "hdfs"
+		_, err = ParseComponentTree(models.Workflow{Component: cmp}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		assert.Error(t, err)
+		assert.Error(t, ValidateArtifactRepository("hdfs"))
+		assert.NoError(t, ValidateArtifactRepository("azure"))
+	})
+
+	t.Run("not iterating", func(t *testing.T) {
+		once := cmp
+		once.Inputs = []models.Data{{Name: "names", Type: models.FlowifyParameterType}}
+		once.Outputs = []models.Data{{Name: "sizes", Type: models.FlowifyParameterType}, {Name: "tags", Type: models.FlowifyParameterArrayType}, {Name: "thumbnails", Type: models.FlowifyArtifactType}}
+		argoWF, err := ParseComponentTree(models.Workflow{Component: once}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		require.NoError(t, err)
+		outputs := argoWF.Spec.Templates[0].Outputs
+		assert.Equal(t, "{{tasks.mapnode.outputs.parameters.tags}}", parameterByName(outputs, "tags").ValueFrom.Parameter)
+		assert.Equal(t, "{{tasks.mapnode.outputs.artifacts.thumbnail}}", outputs.GetArtifactByName("thumbnails").From)
+		assert.Len(t, argoWF.Spec.Templates, 2)
+	})
+
+	t.Run("parameter output", func(t *testing.T) {
+		bad := cmp
+		bad.Outputs = []models.Data{{Name: "sizes", Type: models.FlowifyParameterType}, {Name: "tags", Type: models.FlowifyParameterArrayType}, {Name: "thumbnails", Type: models.FlowifyArtifactType}}
+		_, err := ParseComponentTree(models.Workflow{Component: bad}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		assert.Error(t, err)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		inner := cmp
+		inner.Inputs = append([]models.Data{{Name: "group", Type: models.FlowifyParameterType}}, cmp.Inputs...)
+		outer := models.Component{
+			ComponentBase: models.ComponentBase{
+				Metadata: models.Metadata{Uid: models.NewComponentReference()},
+				Type:     "component",
+				Inputs:   []models.Data{{Name: "groups", Type: models.FlowifyParameterArrayType}, {Name: "names", Type: models.FlowifyParameterArrayType}},
+			},
+			Implementation: models.Map{
+				ImplementationBase: models.ImplementationBase{Type: "map"},
+				Node:               inner,
+				InputMappings: []models.Edge{
+					{Source: models.PortAddress{Port: "groups"}, Target: models.PortAddress{Port: "group"}},
+					{Source: models.PortAddress{Port: "names"}, Target: models.PortAddress{Port: "names"}},
+				},
+			},
+		}
+		_, err := ParseComponentTree(models.Workflow{Component: outer}, secretMap{}, volumeMap{}, map[string]string{}, map[string]string{})
+		assert.ErrorContains(t, err, "cannot iterate over a map collecting artifacts")
+	})
+}
+
+func Test_GetDepends(t *testing.T) {
+	edges := []models.Edge{
+		{Source: models.PortAddress{Node: "a", Port: "out"}, Target: models.PortAddress{Node: "c", Port: "in1"}},
+		{Source: models.PortAddress{Node: "a", Port: "out2"}, Target: models.PortAddress{Node: "c", Port: "in2"}},
+	}
+
+	testCases := []struct {
+		Name          string
+		ControlEdges  []models.ControlEdge
+		Expected      string
+		ExpectedError bool
+	}{
+		{"data edges only", nil, "a", false},
+		{"control edge on data source", []models.ControlEdge{{Source: "a", Target: "c"}}, "a", false},
+		{"failure handler", []models.ControlEdge{{Source: "b", Target: "c", Condition: models.ControlConditionFailed}}, "a && (b.Failed || b.Errored)", false},
+		{"failure of data source", []models.ControlEdge{{Source: "a", Target: "c", Condition: models.ControlConditionAny}}, "", true},
+		{"unknown condition", []models.ControlEdge{{Source: "b", Target: "c", Condition: "maybe"}}, "", true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			depends, err := getDepends(edges, test.ControlEdges, "c")
+			if test.ExpectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, depends)
+		})
+	}
+}
+
+func Test_TranspileExitHandler(t *testing.T) {
+	raw, err := os.ReadFile("../models/examples/exit-handler-workflow.json")
+	require.NoError(t, err)
+	var wf models.Workflow
+	require.NoError(t, json.Unmarshal(raw, &wf))
+
+	job := models.Job{Type: "job", Workflow: wf, InputValues: []models.Value{{Target: "channel", Value: "#ops"}, {Target: "token", Value: "secret-token"}}}
+	argoWF, err := GetArgoWorkflow(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, ExitHandlerName, argoWF.Spec.OnExit)
+	templates := make(map[string]wfv1.Template)
+	for _, template := range argoWF.Spec.Templates {
+		templates[template.Name] = template
+	}
+	require.Len(t, templates, 3)
+
+	handler := templates[ExitHandlerName]
+	require.NotNil(t, handler.DAG)
+	require.Len(t, handler.DAG.Tasks, 1)
+	task := handler.DAG.Tasks[0]
+	assert.Equal(t, "notify", task.Name)
+	assert.Equal(t, "2c8a4d6e-1f3b-4a5c-9d7e-0b1a2c3d4e01", task.Template)
+	params := make(map[string]string)
+	for _, p := range task.Arguments.Parameters {
+		params[p.Name] = p.Value.String()
+	}
+	assert.Equal(t, map[string]string{
+		"status":   "{{workflow.status}}",
+		"failures": "{{workflow.failures}}",
+		"channel":  "{{workflow.parameters.channel}}",
+	}, params)
+
+	// the secret is passed to the exit brick by name
+	notify := templates["2c8a4d6e-1f3b-4a5c-9d7e-0b1a2c3d4e01"]
+	require.NotNil(t, notify.Container)
+	require.Len(t, notify.Container.Env, 1)
+	assert.Equal(t, "token", notify.Container.Env[0].Name)
+}
+
+func Test_GetJobNodeStatuses(t *testing.T) {
+	const (
+		name    = "w"
+		uidRoot = "192161d7-e3f2-4991-adc0-a99c88c144c0"
+		uidA    = "192161d7-e3f2-4991-adc0-a99c88c144c1"
+		uidMap  = "192161d7-e3f2-4991-adc0-a99c88c144c2"
+		uidIter = "192161d7-e3f2-4991-adc0-a99c88c144c3"
+		uidCond = "192161d7-e3f2-4991-adc0-a99c88c144c4"
+		uidF    = "192161d7-e3f2-4991-adc0-a99c88c144c5"
+	)
+	wf := &wfv1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	wf.Status.Nodes = wfv1.Nodes{}
+	addNode := func(nodeName string, boundary string, displayName string, nodeType wfv1.NodeType, template string, phase wfv1.NodePhase, children ...string) {
+		n := wfv1.NodeStatus{ID: wf.NodeID(nodeName), Name: nodeName, DisplayName: displayName, Type: nodeType,
+			TemplateName: template, Phase: phase}
+		if boundary != "" {
+			n.BoundaryID = wf.NodeID(boundary)
+		}
+		for _, c := range children {
+			n.Children = append(n.Children, wf.NodeID(c))
+		}
+		wf.Status.Nodes[n.ID] = n
+	}
+	started := metav1.Now()
+
+	addNode(name, "", name, wfv1.NodeTypeDAG, uidRoot, wfv1.NodeSucceeded, "w.a", "w.m", "w.c")
+	// retried brick
+	addNode("w.a", name, "a", wfv1.NodeTypeRetry, uidA, wfv1.NodeSucceeded, "w.a(0)", "w.a(1)")
+	addNode("w.a(0)", name, "a(0)", wfv1.NodeTypePod, uidA, wfv1.NodeFailed)
+	addNode("w.a(1)", name, "a(1)", wfv1.NodeTypePod, uidA, wfv1.NodeSucceeded)
+	// map with two iterations
+	addNode("w.m", name, "m", wfv1.NodeTypeDAG, uidMap, wfv1.NodeSucceeded, "w.m.mapnode")
+	addNode("w.m.mapnode", "w.m", MapNodeName, wfv1.NodeTypeTaskGroup, uidIter, wfv1.NodeSucceeded, "w.m.mapnode(0:x)", "w.m.mapnode(1:y)")
+	addNode("w.m.mapnode(0:x)", "w.m", "mapnode(0:x)", wfv1.NodeTypePod, uidIter, wfv1.NodeSucceeded)
+	// the copy of the brick of a map collecting artifacts
+	addNode("w.m.mapnode(1:y)", "w.m", "mapnode(1:y)", wfv1.NodeTypePod, collectNodeTemplateName(uidMap, uidIter), wfv1.NodeFailed)
+	// conditional taking the false branch
+	addNode("w.c", name, "c", wfv1.NodeTypeDAG, uidCond, wfv1.NodeSucceeded, "w.c.nodeTrue", "w.c.nodeFalse")
+	addNode("w.c.nodeTrue", "w.c", ConditionalTrueNodeName, wfv1.NodeTypeSkipped, uidF, wfv1.NodeSkipped)
+	addNode("w.c.nodeFalse", "w.c", ConditionalFalseNodeName, wfv1.NodeTypePod, uidF, wfv1.NodeSucceeded)
+
+	a := wf.Status.Nodes[wf.NodeID("w.a")]
+	a.StartedAt = started
+	a.Outputs = &wfv1.Outputs{Parameters: []wfv1.Parameter{{Name: "out", Value: wfv1.AnyStringPtr("1")}}}
+	wf.Status.Nodes[a.ID] = a
+
+	statuses := GetJobNodeStatuses(wf)
+	byPath := make(map[string]models.JobNodeStatus)
+	paths := []string{}
+	for _, s := range statuses {
+		byPath[s.Path] = s
+		paths = append(paths, s.Path)
+	}
+	// attempts are folded into the retried node
+	require.ElementsMatch(t, []string{"", "a", "m", "m.mapnode", "m.mapnode[0]", "m.mapnode[1]", "c", "c.nodeTrue", "c.nodeFalse"}, paths)
+
+	root := byPath[""]
+	assert.Equal(t, "", root.NodeId)
+	assert.Equal(t, models.NewReference(uidRoot), root.ComponentUid)
+
+	nodeA := byPath["a"]
+	assert.Equal(t, "a", nodeA.NodeId)
+	assert.Equal(t, wf.NodeID("w.a"), nodeA.Id)
+	assert.Equal(t, models.NewReference(uidA), nodeA.ComponentUid)
+	assert.Equal(t, wfv1.NodeSucceeded, nodeA.Phase)
+	assert.Equal(t, 1, nodeA.Retries)
+	require.NotNil(t, nodeA.StartedAt)
+	assert.Equal(t, started, *nodeA.StartedAt)
+	assert.Nil(t, nodeA.FinishedAt)
+	require.NotNil(t, nodeA.Outputs)
+	assert.Equal(t, "1", nodeA.Outputs.Parameters[0].Value.String())
+
+	iteration := byPath["m.mapnode[1]"]
+	assert.Equal(t, MapNodeName, iteration.NodeId)
+	assert.Equal(t, models.NewReference(uidIter), iteration.ComponentUid)
+	assert.Equal(t, wfv1.NodeFailed, iteration.Phase)
+	require.NotNil(t, iteration.Iteration)
+	assert.Equal(t, 1, *iteration.Iteration)
+	assert.Equal(t, "y", iteration.Item)
+	assert.Nil(t, byPath["m.mapnode"].Iteration)
+
+	assert.Equal(t, ConditionalFalseNodeName, byPath["c"].Branch)
+	assert.Equal(t, wfv1.NodeSkipped, byPath["c.nodeTrue"].Phase)
+	assert.Equal(t, "", byPath["m"].Branch)
+}
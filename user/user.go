@@ -17,6 +17,7 @@ type Role string
 
 var ListGroupsCanCreateWorkspaces = [3]string{"admin", "developer-admin", "sandbox-developer"}
 var ListGroupsCanSeeCustomRoles = [3]string{"admin", "developer-admin", "sandbox-developer"}
+var ListGroupsCanManageStorage = [2]string{"admin", "developer-admin"}
 
 // tightly modelled on JWT: http://jwt.io
 type User interface {
@@ -37,6 +38,17 @@ func CanCreateWorkspaces(user User) bool {
 	return false
 }
 
+func CanManageStorage(user User) bool {
+	for _, role := range user.GetRoles() {
+		for _, r := range ListGroupsCanManageStorage {
+			if string(role) == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func GetUser(ctx context.Context) User {
 	val := ctx.Value(UserKey)
 